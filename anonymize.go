@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/percona/pt-mongodb-summary/db"
+	"github.com/pkg/errors"
+)
+
+// The names of the databases created by MongoDB, which are kept as they are
+var systemDatabases = map[string]bool{"admin": true, "config": true, "local": true}
+
+// hostFields are the fields with hosts or lists of them, where any IP
+// address is anonymized
+var hostFields = map[string]bool{
+	"answeredBy": true, "arbiters": true, "argv": true, "bindIp": true, "client": true,
+	"deploymentId": true, "host": true, "hostName": true, "hostname": true, "hosts": true,
+	"me": true, "passives": true, "primary": true, "seeds": true, "syncSourceHost": true,
+	"syncingTo": true,
+}
+
+// nameFields are the fields with names or messages that can have them. Value
+// is the value of the commands run, like {"collStats": "orders"}.
+var nameFields = map[string]bool{
+	"authUser": true, "collStats": true, "collection": true, "commandLine": true, "db": true,
+	"error": true, "errmsg": true, "message": true, "ns": true, "user": true, "userName": true,
+	"Value": true,
+}
+
+// namedParents are the fields with lists of databases, collections or
+// replica set members, whose name is anonymized
+var namedParents = map[string]bool{
+	"collections": true, "databases": true, "members": true, "replicaMembers": true,
+}
+
+var (
+	ipv4Address = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	// ipv6Address matches more than IPv6 addresses, like times. They are
+	// checked with net.ParseIP.
+	ipv6Address = regexp.MustCompile(`[0-9A-Fa-f]*:[0-9A-Fa-f:]*:[0-9A-Fa-f.]*`)
+	// jsonString matches the strings of a valid JSON document
+	jsonString = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+)
+
+// anonymizer replaces the names of hosts, databases, collections and users,
+// and the IP addresses, by hashes for --anonymize. A name has the same hash
+// everywhere in the report and the bundle of a run. The hashes are keyed, so
+// they cannot be reversed by hashing guessed names. Only the fields with
+// names are anonymized, so a database named like a node type or a member
+// state doesn't change the report.
+type anonymizer struct {
+	key []byte
+	// names are the anonymized names by name
+	names map[string]string
+	// known matches the names, longest first
+	known *regexp.Regexp
+}
+
+// newAnonymizer returns an anonymizer hashing with key, or with a random key
+// if key is nil
+func newAnonymizer(key []byte) (*anonymizer, error) {
+	if key == nil {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, errors.Wrap(err, "cannot create the anonymization key")
+		}
+	}
+	return &anonymizer{key: key, names: make(map[string]string)}, nil
+}
+
+func (a *anonymizer) hash(kind, name string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(name))
+	return kind + "-" + hex.EncodeToString(mac.Sum(nil))[:8]
+}
+
+// add makes name be anonymized as a name of kind. A name added with several
+// kinds keeps the first one.
+func (a *anonymizer) add(kind, name string) {
+	if name == "" || a.names[name] != "" {
+		return
+	}
+	a.names[name] = a.hash(kind, name)
+	a.known = nil
+}
+
+// addHosts adds the hosts of a host:port, a seed list or a shard like
+// rs1/db1:27017,db2:27017. The IP addresses of hosts are added too, so they
+// are anonymized in the messages, not only in the host fields.
+func (a *anonymizer) addHosts(s string) {
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if i := strings.Index(part, "/"); i >= 0 {
+			part = part[i+1:]
+		}
+		host, _, err := net.SplitHostPort(part)
+		if err != nil {
+			host = strings.Trim(part, "[]")
+		}
+		if host == "localhost" {
+			continue
+		}
+		if net.ParseIP(host) != nil {
+			if hash := a.ip(host); hash != host && a.names[host] == "" {
+				a.names[host] = hash
+				a.known = nil
+			}
+			continue
+		}
+		a.add("host", host)
+	}
+}
+
+// learn adds the names found in the reports and the hosts contacted
+func (a *anonymizer) learn(res results, managers []*db.ConnectionManager) {
+	for _, cm := range managers {
+		for _, host := range cm.Hosts() {
+			a.addHosts(host)
+		}
+	}
+	if res.Run != nil {
+		for _, s := range res.Run.Seeds {
+			a.addHosts(s)
+		}
+	}
+	for _, f := range res.Findings {
+		a.addHosts(f.Host)
+	}
+
+	for _, td := range res.Reports {
+		a.addHosts(td.AnsweredBy)
+		a.addHosts(td.ServerStatus.Host)
+		for _, s := range td.Seeds {
+			a.addHosts(s)
+		}
+		for _, m := range td.ReplicaMembers {
+			a.addHosts(m.Name)
+		}
+		for _, m := range td.ReplicaSetStatus.Members {
+			a.addHosts(m.Name)
+		}
+		if repl := td.ServerStatus.Repl; repl != nil {
+			a.addHosts(repl.Me)
+			a.addHosts(strings.Join(repl.Hosts, ","))
+			a.addHosts(strings.Join(repl.Passives, ","))
+		}
+		if td.HostInfo.System != nil {
+			a.addHosts(td.HostInfo.System.Hostname)
+		}
+		if td.Oplog != nil {
+			a.addHosts(td.Oplog.Hostname)
+		}
+		for _, f := range td.Findings {
+			a.addHosts(f.Host)
+		}
+
+		if td.Security != nil {
+			a.add("user", td.Security.AuthUser)
+		}
+		a.add("user", td.ProcInfo.UserName)
+
+		for _, database := range td.Databases {
+			if systemDatabases[database.Name] {
+				continue
+			}
+			a.add("db", database.Name)
+			for _, coll := range database.Collections {
+				if !strings.HasPrefix(coll.Name, "system.") {
+					a.add("coll", coll.Name)
+				}
+			}
+		}
+	}
+}
+
+// isNameChar tells if c can be part of a name, so a name followed or
+// preceded by c is part of a longer word. Dots separate the parts of
+// namespaces.
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '$'
+}
+
+// String returns s with the names added replaced by their hashes. Names are
+// only replaced as whole words, so the database shop is replaced in
+// shop.orders but not in shopping.
+func (a *anonymizer) String(s string) string {
+	if len(a.names) == 0 {
+		return s
+	}
+
+	if a.known == nil {
+		names := make([]string, 0, len(a.names))
+		for name := range a.names {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if len(names[i]) != len(names[j]) {
+				return len(names[i]) > len(names[j])
+			}
+			return names[i] < names[j]
+		})
+		for i, name := range names {
+			names[i] = regexp.QuoteMeta(name)
+		}
+		a.known = regexp.MustCompile(strings.Join(names, "|"))
+	}
+
+	var buf bytes.Buffer
+	last := 0
+	for _, m := range a.known.FindAllStringIndex(s, -1) {
+		if m[0] > 0 && isNameChar(s[m[0]-1]) || m[1] < len(s) && isNameChar(s[m[1]]) {
+			continue
+		}
+		buf.WriteString(s[last:m[0]])
+		buf.WriteString(a.names[s[m[0]:m[1]]])
+		last = m[1]
+	}
+	buf.WriteString(s[last:])
+	return buf.String()
+}
+
+// host returns s, a host or a list of them, with all the IP addresses and
+// the names added replaced by their hashes. IP addresses are only looked
+// for in hosts, where there are no versions like 4.15.0.123.
+func (a *anonymizer) host(s string) string {
+	s = ipv4Address.ReplaceAllStringFunc(s, a.ip)
+	s = ipv6Address.ReplaceAllStringFunc(s, a.ip)
+	return a.String(s)
+}
+
+// field returns the anonymized value s of the field key of an object that
+// is the value of the field parent. The strings out of any object, like the
+// names listed by listDatabases, are names too.
+func (a *anonymizer) field(parent, key, s string) string {
+	switch {
+	case hostFields[key]:
+		return a.host(s)
+	case key == "", nameFields[key], key == "name" && namedParents[parent]:
+		return a.String(s)
+	}
+	return s
+}
+
+// ip returns the hash of an IP address. Loopback and unspecified addresses
+// and strings that are not IP addresses are returned as they are.
+func (a *anonymizer) ip(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+		return s
+	}
+	return a.hash("ip", ip.String())
+}
+
+// JSON returns buf with the string values of its fields with names
+// anonymized. Keys, formatting and order are kept.
+func (a *anonymizer) JSON(buf []byte) ([]byte, error) {
+	var out bytes.Buffer
+	// objects has the objects and arrays being read, innermost last
+	var objects []jsonObject
+	last := 0
+	for _, m := range jsonString.FindAllIndex(buf, -1) {
+		// There are no strings between the matches
+		for _, c := range buf[last:m[0]] {
+			switch c {
+			case '{', '[':
+				objects = append(objects, jsonObject{array: c == '['})
+			case '}', ']':
+				if len(objects) > 0 {
+					objects = objects[:len(objects)-1]
+				}
+			}
+		}
+		out.Write(buf[last:m[0]])
+		last = m[1]
+		var s string
+		if err := json.Unmarshal(buf[m[0]:m[1]], &s); err != nil {
+			return nil, errors.Wrap(err, "invalid JSON")
+		}
+		if bytes.HasPrefix(bytes.TrimLeft(buf[m[1]:], " \t\r\n"), []byte(":")) {
+			if len(objects) > 0 {
+				objects[len(objects)-1].key = s
+			}
+			out.Write(buf[m[0]:m[1]])
+			continue
+		}
+		parent, key := fieldKeys(objects)
+		anonymized, err := json.Marshal(a.field(parent, key, s))
+		if err != nil {
+			return nil, err
+		}
+		out.Write(anonymized)
+	}
+	out.Write(buf[last:])
+	return out.Bytes(), nil
+}
+
+// jsonObject is an object or an array being read by JSON, with the last key
+// read in the object
+type jsonObject struct {
+	array bool
+	key   string
+}
+
+// fieldKeys returns the key of the values being read in objects and the key
+// of the object that has them. The values of an array belong to the key of
+// the array.
+func fieldKeys(objects []jsonObject) (parent, key string) {
+	var keys []string
+	for i := len(objects) - 1; i >= 0 && len(keys) < 2; i-- {
+		if !objects[i].array {
+			keys = append(keys, objects[i].key)
+		}
+	}
+	switch len(keys) {
+	case 2:
+		return keys[1], keys[0]
+	case 1:
+		return "", keys[0]
+	}
+	return "", ""
+}
+
+// anonymize anonymizes the strings of v, a pointer to a value that can be
+// written as JSON and read back, like results
+func (a *anonymizer) anonymize(v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "cannot anonymize the report")
+	}
+	if buf, err = a.JSON(buf); err != nil {
+		return errors.Wrap(err, "cannot anonymize the report")
+	}
+	elem := reflect.ValueOf(v).Elem()
+	elem.Set(reflect.Zero(elem.Type()))
+	return errors.Wrap(json.Unmarshal(buf, v), "cannot anonymize the report")
+}
+
+// results anonymizes res
+func (a *anonymizer) results(res *results) error {
+	return a.anonymize(res)
+}
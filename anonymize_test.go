@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+func TestAnonymizerString(t *testing.T) {
+	a, err := newAnonymizer([]byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.addHosts("rs0/db1.example.com:27017,db2.example.com:27017")
+	a.addHosts("10.0.0.1:27017")
+	a.addHosts("localhost:27017")
+	a.add("db", "shop")
+	a.add("coll", "orders")
+	a.add("user", "alice")
+
+	db1, db2, shop, orders, alice := a.hash("host", "db1.example.com"), a.hash("host", "db2.example.com"),
+		a.hash("db", "shop"), a.hash("coll", "orders"), a.hash("user", "alice")
+	ip := a.hash("ip", "10.0.0.1")
+	if !strings.HasPrefix(db1, "host-") || len(db1) != len("host-")+8 || db1 == db2 {
+		t.Fatalf("invalid hashes %q and %q", db1, db2)
+	}
+
+	tests := []struct {
+		in, expect string
+	}{
+		{"db1.example.com:27017", db1 + ":27017"},
+		{"rs0/db1.example.com:27017,db2.example.com:27017", "rs0/" + db1 + ":27017," + db2 + ":27017"},
+		{"shop.orders", shop + "." + orders},
+		{"cannot connect to 10.0.0.1:27017 as alice", "cannot connect to " + ip + ":27017 as " + alice},
+		// Only whole names are replaced
+		{"shopping orders_2017", "shopping orders_2017"},
+		// Not identifying
+		{"localhost:27017 127.0.0.1 0.0.0.0 ::1", "localhost:27017 127.0.0.1 0.0.0.0 ::1"},
+		{"2017-03-28T13:35:57.120Z 3.4.1", "2017-03-28T13:35:57.120Z 3.4.1"},
+		// Only the IP addresses of the hosts are known in messages
+		{"kernel 4.15.0.123", "kernel 4.15.0.123"},
+	}
+	for _, test := range tests {
+		if got := a.String(test.in); got != test.expect {
+			t.Errorf("%q: got %q, expect %q", test.in, got, test.expect)
+		}
+	}
+
+	// All the IP addresses are anonymized in hosts
+	hosts := []struct {
+		in, expect string
+	}{
+		{"[fe80::1]:27017", "[" + a.hash("ip", "fe80::1") + "]:27017"},
+		{"10.0.0.2:27017,db1.example.com:27017", a.hash("ip", "10.0.0.2") + ":27017," + db1 + ":27017"},
+		{"127.0.0.1:27017", "127.0.0.1:27017"},
+	}
+	for _, test := range hosts {
+		if got := a.host(test.in); got != test.expect {
+			t.Errorf("host %q: got %q, expect %q", test.in, got, test.expect)
+		}
+	}
+
+	other, _ := newAnonymizer([]byte("other key"))
+	if other.hash("db", "shop") == shop {
+		t.Errorf("the hashes must depend on the key")
+	}
+
+	buf, err := a.JSON([]byte("{\n    \"shop\": \"shop\",\n    \"hosts\": [\"db1.example.com:27017\"], \"n\": 1\n}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "{\n    \"shop\": \"shop\",\n    \"hosts\": [\"" + db1 + ":27017\"], \"n\": 1\n}"; string(buf) != expect {
+		t.Errorf("the keys and the formatting must be kept.\ngot:    %s\nexpect: %s", buf, expect)
+	}
+
+	// Only the fields with names are anonymized
+	buf, err = a.JSON([]byte(`{"databases": [{"name": "shop", "empty": false}], "storageEngine": {"name": "shop"}, ` +
+		`"inprog": [{"ns": "shop.orders", "client": "10.1.2.3:51000", "op": "shop"}], "version": "4.15.0.123"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"databases": [{"name": "` + shop + `", "empty": false}], "storageEngine": {"name": "shop"}, ` +
+		`"inprog": [{"ns": "` + shop + "." + orders + `", "client": "` + a.hash("ip", "10.1.2.3") + `:51000", "op": "shop"}], "version": "4.15.0.123"}`
+	if string(buf) != expect {
+		t.Errorf("invalid anonymized fields.\ngot:    %s\nexpect: %s", buf, expect)
+	}
+	// Lists of names, like the reply of listDatabases
+	if buf, err := a.JSON([]byte(`["shop", "admin"]`)); err != nil || string(buf) != `["`+shop+`", "admin"]` {
+		t.Errorf("invalid anonymized list %s: %v", buf, err)
+	}
+}
+
+func TestAnonymizeResults(t *testing.T) {
+	td := testReport()
+	td.CommandLineOptions.Argv = []string{"mongod", "--bind_ip", "10.0.0.1", "--replSet", "rs0"}
+	td.Security.AuthUser = "alice"
+	td.Databases = []proto.Database{
+		{Name: "shop", Collections: []proto.CollStats{{Name: "orders"}, {Name: "system.profile"}}},
+		{Name: "admin", Collections: []proto.CollStats{{Name: "system.users"}}},
+	}
+	// A collection with the name of a section, and a database and a
+	// collection named like a node type and a member state
+	td.Databases[0].Collections = append(td.Databases[0].Collections, proto.CollStats{Name: "security"})
+	td.Databases = append(td.Databases, proto.Database{Name: "replset", Collections: []proto.CollStats{{Name: "PRIMARY"}}})
+	td.Sections = []string{"instances", "security", "collections"}
+	td.addFinding(severityWarning, "security", "db1:27017", "TLS is disabled on db1:27017")
+	res := results{
+		Run:      newRunInfo([]string{"pt-mongodb-summary", "--hosts", "db1:27017", "--username", "alice"}, []seed{{hostname: "db1:27017"}}, timeNow()),
+		Reports:  []templateData{td},
+		Findings: []finding{{Severity: severityError, Section: "connection", Host: "db2:27017", Message: "cannot connect to db2:27017"}},
+	}
+
+	a, _ := newAnonymizer([]byte("key"))
+	a.learn(res, nil)
+	if err := a.results(&res); err != nil {
+		t.Fatalf("cannot anonymize: %s", err)
+	}
+
+	buf := &bytes.Buffer{}
+	renderers["json"].Render(buf, res)
+	for _, name := range []string{"db1", "db2", "10.0.0.1", "alice", "shop", "orders"} {
+		if strings.Contains(buf.String(), name) {
+			t.Errorf("%q is in the anonymized report:\n%s", name, buf)
+		}
+	}
+	for _, kept := range []string{"admin", "system.users", "system.profile", "rs0"} {
+		if !strings.Contains(buf.String(), kept) {
+			t.Errorf("%q must be kept in the anonymized report", kept)
+		}
+	}
+
+	host := a.hash("host", "db1")
+	got := res.Reports[0]
+	if got.AnsweredBy != host+":27017" || got.ReplicaMembers[0].Name != host+":27017" || res.Run.Seeds[0] != host+":27017" {
+		t.Errorf("the hosts must have the same hash everywhere: %q %q %q", got.AnsweredBy, got.ReplicaMembers[0].Name, res.Run.Seeds)
+	}
+	if got.Databases[0].Collections[2].Name != a.hash("coll", "security") {
+		t.Errorf("invalid collection %q", got.Databases[0].Collections[2].Name)
+	}
+	if got.NodeType != "replset" || got.ReplicaMembers[0].StateStr != "PRIMARY" || got.Databases[2].Name != a.hash("db", "replset") {
+		t.Errorf("only the names must be anonymized: %q %q %q", got.NodeType, got.ReplicaMembers[0].StateStr, got.Databases[2].Name)
+	}
+	if strings.Join(got.Sections, ",") != "instances,security,collections" {
+		t.Errorf("the sections must be kept: %v", got.Sections)
+	}
+	f := got.Findings[0]
+	if f.Section != "security" || f.Severity != severityWarning || f.Message != "TLS is disabled on "+host+":27017" {
+		t.Errorf("invalid finding %+v", f)
+	}
+	if f := res.Findings[0]; f.Section != "connection" || f.Host != a.hash("host", "db2")+":27017" {
+		t.Errorf("invalid finding %+v", f)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/percona/pt-mongodb-summary/db"
+	"github.com/pkg/errors"
+)
+
+// saveBundle writes the commands recorded in rec, with the run metadata, in
+// --save-dir and --bundle
+func saveBundle(opts options, rec *db.Recorder, run *runInfo) error {
+	if opts.SaveDir != "" {
+		if err := rec.WriteDir(opts.SaveDir, run); err != nil {
+			return err
+		}
+	}
+	if opts.Bundle != "" {
+		f, err := os.OpenFile(opts.Bundle, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return errors.Wrap(err, "cannot create the bundle")
+		}
+		if err := rec.WriteTarGz(f, run); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return errors.Wrap(err, "cannot write the bundle")
+		}
+	}
+	return nil
+}
+
+// bundleSeeds returns the seeds of the run that saved the bundle, with the
+// dial options of the given seeds. Bundles without run metadata, like the ones
+// laid out like test/sample, use the given seeds.
+func bundleSeeds(b *db.Bundle, seeds []seed) []seed {
+	if b.Manifest == nil || b.Manifest.Run == nil {
+		return seeds
+	}
+	var run runInfo
+	if err := json.Unmarshal(b.Manifest.Run, &run); err != nil || len(run.Seeds) == 0 {
+		return seeds
+	}
+	saved := make([]seed, 0, len(run.Seeds))
+	for _, hostname := range run.Seeds {
+		saved = append(saved, seed{hostname: hostname, dialOpts: seeds[0].dialOpts})
+	}
+	return saved
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/percona/pt-mongodb-summary/db"
+)
+
+func TestReportFromBundle(t *testing.T) {
+	bundle, err := db.OpenBundle("test/sample")
+	if err != nil {
+		t.Fatalf("cannot open the bundle: %s", err)
+	}
+	d := &deployment{
+		Seeds:    []string{"localhost"},
+		hostname: "localhost",
+		cm:       db.NewConnectionManager(bundle.Factory()),
+	}
+	td, err := collectReport(context.Background(), d, reportSections, options{FromBundle: "test/sample"})
+	if err != nil {
+		t.Fatalf("cannot get the report from the bundle: %s", err)
+	}
+	if td.NodeType != "mongos" || td.ServerStatus.Version != "3.0.11" || td.HostInfo.System == nil {
+		t.Errorf("the report must have the saved data: %+v", td)
+	}
+	// The members of both shards, each from its own replsetgetstatus_NN.json
+	if len(td.ReplicaMembers) != 6 || td.ReplicaMembers[0].Set != "r1" || td.ReplicaMembers[5].Set != "r2" {
+		t.Errorf("invalid members: %+v", td.ReplicaMembers)
+	}
+	// The sample has no databases nor users
+	for _, f := range td.Findings {
+		if f.Severity != severityError || (f.Section != "collections" && f.Section != "security") {
+			t.Errorf("unexpected finding: %+v", f)
+		}
+	}
+}
+
+func TestSampleBundleSeeds(t *testing.T) {
+	// --from-bundle test/sample --hosts localhost
+	opts := options{Hosts: []string{"localhost"}, FromBundle: "test/sample"}
+	bundle, err := db.OpenBundle(opts.FromBundle)
+	if err != nil {
+		t.Fatalf("cannot open the bundle: %s", err)
+	}
+	seeds, err := getSeeds(opts, db.DefaultResolver)
+	if err != nil {
+		t.Fatalf("cannot get the seeds: %s", err)
+	}
+	deployments, skipped := groupSeeds(bundleSeeds(bundle, seeds), func(s seed) *db.ConnectionManager {
+		return db.NewConnectionManager(bundle.Factory())
+	})
+	if len(deployments) != 1 || len(skipped) != 0 {
+		t.Fatalf("expected one deployment, got %+v, skipped %+v", deployments, skipped)
+	}
+	if id := deployments[0].ID; id != "cluster/58c1e57b2f6ca3a0f1a9e0e2" {
+		t.Errorf("invalid deployment id %q", id)
+	}
+	td, err := collectReport(context.Background(), deployments[0], reportSections, opts)
+	if err != nil {
+		t.Fatalf("cannot get the report from the bundle: %s", err)
+	}
+	if td.DeploymentID != deployments[0].ID || td.NodeType != "mongos" || len(td.ReplicaMembers) != 6 {
+		t.Errorf("the report must have the saved data: %+v", td)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/percona/pt-mongodb-summary/db"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	toolName = "pt-mongodb-summary"
+	// passwordEnvVar holds the password if it is not given in the command line
+	passwordEnvVar = "PT_MONGODB_SUMMARY_PASSWORD"
+)
+
+// Options are read from these sources. Each one overrides the previous ones:
+//
+//  1. /etc/percona-toolkit/pt-mongodb-summary.conf
+//  2. ~/.pt-mongodb-summary.conf
+//  3. The PT_MONGODB_SUMMARY_PASSWORD environment variable (password only)
+//  4. The command line
+//
+// If --config FILE[,FILE...] is given, those files are read instead of 1 and
+// 2, in the order they were specified.
+// If --password is given without a value, the password is read from the
+// terminal without echoing it and it overrides all the above.
+//
+// Config files have one option per line, using the long option name without
+// the leading dashes. Options without a value are booleans, except password,
+// which asks for the password like --password without a value unless a later
+// source sets it. Blank lines and lines starting with # are ignored:
+//
+//	# pt-mongodb-summary.conf
+//	username=admin
+//	authenticationDatabase=admin
+//	tls
+func defaultConfigFiles() []string {
+	files := []string{"/etc/percona-toolkit/" + toolName + ".conf"}
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, "."+toolName+".conf"))
+	}
+	return files
+}
+
+func newFlagSet(opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(toolName, flag.ContinueOnError)
+	opts.Hosts = []string{"localhost:27017"}
+	fs.Var(&hostList{hosts: &opts.Hosts}, "hosts",
+		"Comma separated list of host:port or a mongodb:// or mongodb+srv:// connection string. "+
+			"Can be repeated. Hosts in different deployments get a report each")
+	fs.StringVar(&opts.User, "username", "", "Username to use for optional MongoDB authentication")
+	fs.StringVar(&opts.Password, "password", "", "Password to use for optional MongoDB authentication. Without a value, it is asked interactively")
+	fs.StringVar(&opts.AuthDB, "authenticationDatabase", "", "Database used to establish credentials and privileges with a MongoDB server (default admin or $external for MONGODB-X509 and PLAIN)")
+	fs.StringVar(&opts.AuthMechanism, "authenticationMechanism", "",
+		"Authentication mechanism: "+strings.Join(db.AuthMechanisms, ", ")+". Empty means SCRAM-SHA-256 or SCRAM-SHA-1, depending on the server and the user")
+	fs.BoolVar(&opts.TLS, "tls", false, "Use TLS for all connections")
+	fs.StringVar(&opts.TLSCAFile, "tlsCAFile", "", "Certificate Authority file for TLS")
+	fs.StringVar(&opts.TLSCertificateKeyFile, "tlsCertificateKeyFile", "", "PEM file with the client certificate and key for TLS")
+	fs.StringVar(&opts.TLSCertificateKeyFilePassword, "tlsCertificateKeyFilePassword", "", "Password to decrypt the key in --tlsCertificateKeyFile")
+	fs.BoolVar(&opts.TLSAllowInvalidHostnames, "tlsAllowInvalidHostnames", false, "Don't verify that the server certificate hostname matches the host")
+	fs.BoolVar(&opts.TLSAllowInvalidCertificates, "tlsAllowInvalidCertificates", false, "Don't validate the server certificate")
+	fs.DurationVar(&opts.ConnectTimeout, "connect-timeout", 0, "Maximum time to wait for each connection (default 10s)")
+	fs.DurationVar(&opts.CommandTimeout, "command-timeout", db.DefaultCommandTimeout, "Maximum time to wait for each command")
+	fs.DurationVar(&opts.MaxRuntime, "max-runtime", 0, "Maximum time for the whole collection run. 0 means no limit")
+	fs.BoolVar(&opts.Direct, "direct", false, "Run all commands on the given host, even if it is a secondary, without discovering the cluster")
+	fs.StringVar(&opts.Proxy, "proxy", "", "Connect to all the hosts, including the ones found during discovery, through this SOCKS5 proxy: socks5://[user:password@]host:port")
+	fs.StringVar(&opts.OutputFormat, "output-format", "text", "Report format: "+strings.Join(outputFormats, ", "))
+	fs.StringVar(&opts.Sections, "sections", "", "Comma separated list of sections to collect and write, in order: "+strings.Join(reportSections, ", ")+" (default all)")
+	fs.StringVar(&opts.SkipSections, "skip-sections", "", "Comma separated list of sections not to collect nor write")
+	fs.StringVar(&opts.TemplateDir, "template-dir", "", "Directory with templates replacing the built-in text sections. Each file is named <section>.tmpl: "+strings.Join(textSectionNames(), ", "))
+	fs.StringVar(&opts.Template, "template", "", "Template file used to render each report instead of the built-in text sections")
+	fs.BoolVar(&opts.NoColor, "no-color", false, "Don't color the severity markers, even when writing to a terminal")
+	fs.IntVar(&opts.Width, "width", 0, fmt.Sprintf("Width of the text report. 0 means the terminal width, or %d if the output is not a terminal", defaultWidth))
+	fs.StringVar(&opts.SaveDir, "save-dir", "", "Save the response of every command run, by host, and a manifest in this directory")
+	fs.StringVar(&opts.Bundle, "bundle", "", "Save the response of every command run, by host, and a manifest in this .tar.gz file")
+	fs.StringVar(&opts.FromBundle, "from-bundle", "", "Write the report from a directory or .tar.gz file saved with --save-dir or --bundle, without connecting to any server")
+	fs.BoolVar(&opts.Anonymize, "anonymize", false, "Replace hostnames, IP addresses, database, collection and user names by hashes in the report and the bundle")
+	fs.StringVar(&opts.History, "history", "", "Append the key metrics of each deployment to this file, for the trend command")
+	fs.String("config", "", "Comma separated list of config files to read instead of the default ones")
+	fs.BoolVar(&opts.Debug, "debug", false, "debug mode")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", toolName)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nDefaults are read from %s.\n", strings.Join(defaultConfigFiles(), " and "))
+		fmt.Fprintf(os.Stderr, "The password can also be set in the %s environment variable.\n", passwordEnvVar)
+	}
+	return fs
+}
+
+// parseArgs returns the options from the config files, the environment and
+// the command line args. getenv and readPassword are parameters so tests
+// don't depend on the real environment and terminal.
+func parseArgs(args []string, getenv func(string) string, readPassword func() (string, error)) (options, error) {
+	var opts options
+	fs := newFlagSet(&opts)
+
+	args, promptPassword := passwordPromptArg(args)
+
+	configFiles, explicit := configFilesFromArgs(args)
+	if !explicit {
+		configFiles = defaultConfigFiles()
+	}
+	hosts := fs.Lookup("hosts").Value.(*hostList)
+	var promptConfigPassword bool
+	for _, filename := range configFiles {
+		hosts.replace = true
+		err := loadConfigFile(fs, filename, &promptConfigPassword)
+		if os.IsNotExist(errors.Cause(err)) && !explicit {
+			continue
+		}
+		if err != nil {
+			return options{}, err
+		}
+	}
+
+	if password := getenv(passwordEnvVar); password != "" {
+		opts.Password = password
+		promptConfigPassword = false
+	}
+
+	hosts.replace = true
+	if err := fs.Parse(args); err != nil {
+		return options{}, err
+	}
+	// Hosts given without --hosts would silently connect to localhost
+	if fs.NArg() > 0 {
+		return options{}, fmt.Errorf("unexpected arguments: %s. The hosts are given with --hosts", strings.Join(fs.Args(), " "))
+	}
+	if promptConfigPassword && !passwordArg(args) {
+		promptPassword = true
+	}
+
+	if !isOutputFormat(opts.OutputFormat) {
+		return options{}, errInvalidOutputFormat(opts.OutputFormat)
+	}
+	if _, err := selectSections(opts.Sections, opts.SkipSections); err != nil {
+		return options{}, err
+	}
+	if opts.Width < 0 {
+		return options{}, fmt.Errorf("invalid width %d", opts.Width)
+	}
+
+	if promptPassword {
+		password, err := readPassword()
+		if err != nil {
+			return options{}, errors.Wrap(err, "cannot read the password")
+		}
+		opts.Password = password
+	}
+
+	return opts, nil
+}
+
+// hostList is a flag.Value that collects all the --hosts values. The first
+// value found in a source (a config file or the command line) replaces the
+// ones from the previous sources, the next ones are added to the list.
+type hostList struct {
+	hosts   *[]string
+	replace bool
+}
+
+func (h *hostList) String() string {
+	if h.hosts == nil {
+		return ""
+	}
+	return strings.Join(*h.hosts, ",")
+}
+
+func (h *hostList) Set(value string) error {
+	if h.replace {
+		*h.hosts = nil
+		h.replace = false
+	}
+	*h.hosts = append(*h.hosts, value)
+	return nil
+}
+
+// passwordPromptArg removes --password from args when it has no value. It
+// has no value if it is the last arg or if the next arg is another option.
+// Passwords starting with a dash must be given as --password=-secret.
+func passwordPromptArg(args []string) ([]string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if arg != "-password" && arg != "--password" {
+			continue
+		}
+		if i == len(args)-1 || strings.HasPrefix(args[i+1], "-") {
+			newArgs := append([]string{}, args[:i]...)
+			return append(newArgs, args[i+1:]...), true
+		}
+	}
+	return args, false
+}
+
+// passwordArg returns true if args set --password to a value
+func passwordArg(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name != arg && (name == "password" || strings.HasPrefix(name, "password=")) {
+			return true
+		}
+	}
+	return false
+}
+
+// configFilesFromArgs returns the files in --config, if it was given
+func configFilesFromArgs(args []string) ([]string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		var value string
+		switch {
+		case strings.HasPrefix(name, "config="):
+			value = strings.TrimPrefix(name, "config=")
+		case name == "config" && i < len(args)-1:
+			value = args[i+1]
+		default:
+			continue
+		}
+		return strings.Split(value, ","), true
+	}
+	return nil, false
+}
+
+// loadConfigFile sets the flags in fs using the values in filename.
+// promptPassword is set if the last password line in the file has no value
+// and cleared if it has one.
+func loadConfigFile(fs *flag.FlagSet, filename string, promptPassword *bool) error {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return errors.Wrap(err, "cannot open config file")
+	}
+	defer fh.Close()
+
+	scanner := bufio.NewScanner(fh)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value := line, ""
+		i := strings.Index(line, "=")
+		if i != -1 {
+			name, value = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		}
+		name = strings.TrimLeft(name, "-")
+		if name == "config" {
+			return fmt.Errorf("%s:%d: config files cannot include other config files", filename, lineNumber)
+		}
+		if name == "password" {
+			*promptPassword = i == -1
+		}
+		if i == -1 && name != "password" {
+			if !isBoolFlag(fs.Lookup(name)) {
+				return fmt.Errorf("%s:%d: option %q needs a value", filename, lineNumber, name)
+			}
+			value = "true" // options without a value are booleans
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s:%d: invalid option %q: %s", filename, lineNumber, name, err)
+		}
+	}
+	return errors.Wrapf(scanner.Err(), "cannot read config file %s", filename)
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	if f == nil {
+		return true // let fs.Set report the unknown option
+	}
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func readPasswordFromTerminal() (string, error) {
+	fmt.Fprint(os.Stderr, "Enter password: ")
+	password, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func noEnv(string) string {
+	return ""
+}
+
+func noPrompt() (string, error) {
+	return "", fmt.Errorf("the password should not be asked")
+}
+
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pt-mongodb-summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file1 := writeConfigFile(t, dir, "1.conf", `
+# comments and blank lines are ignored
+hosts=file1:27017
+username = file1user
+password=file1pass
+tls
+`)
+	file2 := writeConfigFile(t, dir, "2.conf", `
+--username=file2user
+max-runtime=1m
+`)
+	file3 := writeConfigFile(t, dir, "3.conf", `
+hosts=rs1a:27017,rs1b:27017
+hosts=rs2a:27017
+`)
+
+	env := func(name string) string {
+		if name == passwordEnvVar {
+			return "envpass"
+		}
+		return ""
+	}
+
+	tests := []struct {
+		args   []string
+		getenv func(string) string
+		expect func(*options)
+	}{
+		{
+			// The last config file wins
+			args:   []string{"--config", file1 + "," + file2},
+			getenv: noEnv,
+			expect: func(o *options) {
+				o.Hosts, o.User, o.Password, o.TLS = []string{"file1:27017"}, "file2user", "file1pass", true
+				o.MaxRuntime = time.Minute
+			},
+		},
+		{
+			// The environment overrides config files
+			args:   []string{"--config=" + file1},
+			getenv: env,
+			expect: func(o *options) {
+				o.Hosts, o.User, o.Password, o.TLS = []string{"file1:27017"}, "file1user", "envpass", true
+			},
+		},
+		{
+			// The command line overrides everything
+			args:   []string{"--config=" + file1, "--password", "argpass", "-hosts", "arghost:17001", "-tls=false"},
+			getenv: env,
+			expect: func(o *options) {
+				o.Hosts, o.User, o.Password = []string{"arghost:17001"}, "file1user", "argpass"
+			},
+		},
+		{
+			// --hosts can be repeated in the same source
+			args:   []string{"--config", file3},
+			getenv: noEnv,
+			expect: func(o *options) {
+				o.Hosts = []string{"rs1a:27017,rs1b:27017", "rs2a:27017"}
+			},
+		},
+		{
+			// but the values in a source replace the ones from the previous sources
+			args:   []string{"--config", file3, "--hosts", "arghost:17001", "--hosts", "arghost:17002"},
+			getenv: noEnv,
+			expect: func(o *options) {
+				o.Hosts = []string{"arghost:17001", "arghost:17002"}
+			},
+		},
+	}
+
+	for i, test := range tests {
+		got, err := parseArgs(test.args, test.getenv, noPrompt)
+		if err != nil {
+			t.Errorf("test %d: cannot parse args: %s", i, err)
+			continue
+		}
+		var expect options
+		newFlagSet(&expect) // set the defaults
+		test.expect(&expect)
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("test %d:\ngot:    %+v\nexpect: %+v", i, got, expect)
+		}
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pt-mongodb-summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	unknown := writeConfigFile(t, dir, "unknown.conf", "hosts=localhost\nfoo=bar\n")
+	invalid := writeConfigFile(t, dir, "invalid.conf", "max-runtime=forever\n")
+	nested := writeConfigFile(t, dir, "nested.conf", "config=/etc/other.conf\n")
+	format := writeConfigFile(t, dir, "format.conf", "output-format=xml\n")
+	sections := writeConfigFile(t, dir, "sections.conf", "sections=instances,replicas\n")
+	noValue := writeConfigFile(t, dir, "novalue.conf", "username\n")
+
+	for _, args := range [][]string{
+		{"--config", unknown},
+		{"--config", invalid},
+		{"--config", nested},
+		{"--config", format},
+		{"--config", sections},
+		{"--config", noValue},
+		{"--width", "-1"},
+		{"--config", filepath.Join(dir, "missing.conf")},
+		{"host:27017"},
+		{"--hosts", "h1:27017", "h2:27017"},
+	} {
+		if _, err := parseArgs(args, noEnv, noPrompt); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
+
+func TestPasswordPrompt(t *testing.T) {
+	prompt := func() (string, error) {
+		return "typedpass", nil
+	}
+	empty := writeConfigFile(t, os.TempDir(), "pt-mongodb-summary-empty.conf", "")
+	defer os.Remove(empty)
+	bare := writeConfigFile(t, os.TempDir(), "pt-mongodb-summary-password.conf", "password\n")
+	defer os.Remove(bare)
+
+	tests := []struct {
+		args     []string
+		password string
+	}{
+		{[]string{"--config", empty, "--password"}, "typedpass"},
+		{[]string{"--config", empty, "--password", "--username", "admin"}, "typedpass"},
+		{[]string{"--config", empty, "--password", "secret"}, "secret"},
+		{[]string{"--config", empty, "--password=-secret"}, "-secret"},
+		// a password line without a value in a config file asks for it
+		{[]string{"--config", bare}, "typedpass"},
+		{[]string{"--config", bare + "," + empty}, "typedpass"},
+		// unless a later source sets it
+		{[]string{"--config", bare, "--password", "secret"}, "secret"},
+		{[]string{"--config", bare, "--password=secret"}, "secret"},
+	}
+	for _, test := range tests {
+		opts, err := parseArgs(test.args, noEnv, prompt)
+		if err != nil {
+			t.Errorf("%v: cannot parse args: %s", test.args, err)
+			continue
+		}
+		if opts.Password != test.password {
+			t.Errorf("%v: got password %q, expect %q", test.args, opts.Password, test.password)
+		}
+	}
+}
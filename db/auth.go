@@ -0,0 +1,412 @@
+package db
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+	"labix.org/v2/mgo/bson"
+)
+
+// authSource returns the database the credentials are checked against
+func (o DialOptions) authSource() string {
+	switch {
+	case o.AuthDB != "":
+		return o.AuthDB
+	case o.IsExternalAuth():
+		return ExternalAuthDB
+	}
+	return DefaultAuthDB
+}
+
+// needsAuth returns true if connections must be authenticated
+func (o DialOptions) needsAuth() bool {
+	return o.Username != "" || o.AuthMechanism == AuthMechanismX509
+}
+
+// authenticate authenticates conn using the credentials in opts. im is the
+// isMaster reply used to pick the mechanism if opts doesn't set one.
+//
+// mgo only implements MONGODB-CR and PLAIN natively, needs cgo and libsasl
+// for the SASL mechanisms and doesn't implement MONGODB-X509, so the tool
+// authenticates each connection itself, right after opening it and before
+// handing it to mgo. The server keeps the authentication per connection, so
+// every command mgo sends on it runs as the authenticated user.
+func authenticate(conn net.Conn, opts DialOptions, im isMasterReply) error {
+	source := opts.authSource()
+	mechanism := opts.AuthMechanism
+	if mechanism == "" {
+		mechanism = defaultMechanism(im)
+	}
+
+	var err error
+	switch mechanism {
+	case AuthMechanismSCRAMSHA1:
+		err = authenticateSCRAM(conn, source, newSCRAMClient(sha1.New, opts.Username, mongoPasswordDigest(opts.Username, opts.Password)))
+	case AuthMechanismSCRAMSHA256:
+		var password string
+		if password, err = saslPrep(opts.Password); err == nil {
+			err = authenticateSCRAM(conn, source, newSCRAMClient(sha256.New, opts.Username, password))
+		}
+	case AuthMechanismCR:
+		err = authenticateCR(conn, source, opts.Username, opts.Password)
+	case AuthMechanismPlain:
+		err = authenticatePlain(conn, source, opts.Username, opts.Password)
+	case AuthMechanismX509:
+		err = authenticateX509(conn, source, opts.Username)
+	default:
+		err = fmt.Errorf("unsupported authentication mechanism %q", mechanism)
+	}
+	return errors.Wrapf(err, "%s authentication of user %q on %s failed", mechanism, opts.Username, source)
+}
+
+// defaultMechanism returns the mechanism to use when none was given, like
+// the official drivers do: SCRAM-SHA-256 if the user has SCRAM-SHA-256
+// credentials, SCRAM-SHA-1 for MongoDB 3.0 and newer and MONGODB-CR before.
+func defaultMechanism(im isMasterReply) string {
+	for _, m := range im.SASLSupportedMechs {
+		if m == AuthMechanismSCRAMSHA256 {
+			return AuthMechanismSCRAMSHA256
+		}
+	}
+	if im.MaxWireVersion >= 3 {
+		return AuthMechanismSCRAMSHA1
+	}
+	return AuthMechanismCR
+}
+
+// saslPrep prepares password for SCRAM-SHA-256 with SASLprep (RFC 4013).
+// Printable ASCII passwords are left as they are. The other ones need the
+// Unicode normalization tables, so they are rejected instead of being sent
+// unprepared and failing with a misleading authentication error.
+func saslPrep(password string) (string, error) {
+	for _, r := range password {
+		if r < 0x20 || r > 0x7e {
+			return "", fmt.Errorf("the password contains the character %q: only printable ASCII passwords are supported with %s", r, AuthMechanismSCRAMSHA256)
+		}
+	}
+	return password, nil
+}
+
+// mongoPasswordDigest is the password used by MONGODB-CR and SCRAM-SHA-1
+func mongoPasswordDigest(username, password string) string {
+	sum := md5.Sum([]byte(username + ":mongo:" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+func authenticateCR(conn net.Conn, source, username, password string) error {
+	var nonce struct {
+		Nonce string `bson:"nonce"`
+	}
+	if err := runCommand(conn, source, bson.D{{"getnonce", 1}}, &nonce); err != nil {
+		return err
+	}
+	key := md5.Sum([]byte(nonce.Nonce + username + mongoPasswordDigest(username, password)))
+	cmd := bson.D{
+		{"authenticate", 1},
+		{"user", username},
+		{"nonce", nonce.Nonce},
+		{"key", hex.EncodeToString(key[:])},
+	}
+	return runCommand(conn, source, cmd, nil)
+}
+
+func authenticatePlain(conn net.Conn, source, username, password string) error {
+	var reply saslReply
+	cmd := bson.D{
+		{"saslStart", 1},
+		{"mechanism", AuthMechanismPlain},
+		{"payload", []byte("\x00" + username + "\x00" + password)},
+		{"autoAuthorize", 1},
+	}
+	if err := runCommand(conn, source, cmd, &reply); err != nil {
+		return err
+	}
+	if !reply.Done {
+		return fmt.Errorf("the server didn't finish the conversation")
+	}
+	return nil
+}
+
+// authenticateX509 authenticates with the client certificate presented in
+// the TLS handshake. username must be its subject.
+func authenticateX509(conn net.Conn, source, username string) error {
+	cmd := bson.D{
+		{"authenticate", 1},
+		{"mechanism", AuthMechanismX509},
+		{"user", username},
+	}
+	return runCommand(conn, source, cmd, nil)
+}
+
+type saslReply struct {
+	ConversationID int    `bson:"conversationId"`
+	Done           bool   `bson:"done"`
+	Payload        []byte `bson:"payload"`
+}
+
+func authenticateSCRAM(conn net.Conn, source string, client *scramClient) error {
+	var reply saslReply
+	cmd := bson.D{
+		{"saslStart", 1},
+		{"mechanism", client.mechanism()},
+		{"payload", []byte(client.clientFirst())},
+		{"autoAuthorize", 1},
+	}
+	if err := runCommand(conn, source, cmd, &reply); err != nil {
+		return err
+	}
+	clientFinal, err := client.clientFinal(string(reply.Payload))
+	if err != nil {
+		return err
+	}
+
+	cmd = bson.D{
+		{"saslContinue", 1},
+		{"conversationId", reply.ConversationID},
+		{"payload", []byte(clientFinal)},
+	}
+	if err := runCommand(conn, source, cmd, &reply); err != nil {
+		return err
+	}
+	if err := client.verifyServerFinal(string(reply.Payload)); err != nil {
+		return err
+	}
+
+	// Older servers need an empty message to finish the conversation
+	for i := 0; !reply.Done; i++ {
+		if i == 2 {
+			return fmt.Errorf("the server didn't finish the conversation")
+		}
+		cmd = bson.D{
+			{"saslContinue", 1},
+			{"conversationId", reply.ConversationID},
+			{"payload", []byte{}},
+		}
+		if err := runCommand(conn, source, cmd, &reply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scramClient is the client side of a SCRAM conversation (RFC 5802)
+// without channel binding
+type scramClient struct {
+	hash     func() hash.Hash
+	username string
+	password string
+	nonce    string
+
+	authMessage string
+	serverKey   []byte
+}
+
+// newSCRAMClient returns a client for the given hash function. password
+// must be already prepared for the mechanism.
+func newSCRAMClient(h func() hash.Hash, username, password string) *scramClient {
+	nonce := make([]byte, 24)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		panic(err) // there is no way to continue without random numbers
+	}
+	return &scramClient{
+		hash:     h,
+		username: username,
+		password: password,
+		nonce:    base64.StdEncoding.EncodeToString(nonce),
+	}
+}
+
+func (c *scramClient) mechanism() string {
+	if c.hash().Size() == sha1.Size {
+		return AuthMechanismSCRAMSHA1
+	}
+	return AuthMechanismSCRAMSHA256
+}
+
+var scramUsernameEscaper = strings.NewReplacer("=", "=3D", ",", "=2C")
+
+func (c *scramClient) clientFirstBare() string {
+	return "n=" + scramUsernameEscaper.Replace(c.username) + ",r=" + c.nonce
+}
+
+// clientFirst returns the first message of the conversation
+func (c *scramClient) clientFirst() string {
+	return "n,," + c.clientFirstBare()
+}
+
+// clientFinal returns the answer to the server first message, with the
+// client proof
+func (c *scramClient) clientFinal(serverFirst string) (string, error) {
+	fields := scramFields(serverFirst)
+	nonce, salt, iterations := fields["r"], fields["s"], fields["i"]
+	if !strings.HasPrefix(nonce, c.nonce) || len(nonce) == len(c.nonce) {
+		return "", fmt.Errorf("invalid server nonce in %q", serverFirst)
+	}
+	saltBytes, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return "", fmt.Errorf("invalid salt in %q", serverFirst)
+	}
+	i, err := strconv.Atoi(iterations)
+	if err != nil || i < 4096 {
+		return "", fmt.Errorf("invalid iteration count in %q", serverFirst)
+	}
+
+	saltedPassword := pbkdf2.Key([]byte(c.password), saltBytes, i, c.hash().Size(), c.hash)
+	clientKey := c.hmac(saltedPassword, "Client Key")
+	storedKey := c.hash()
+	storedKey.Write(clientKey)
+
+	clientFinalWithoutProof := "c=biws,r=" + nonce
+	c.authMessage = c.clientFirstBare() + "," + serverFirst + "," + clientFinalWithoutProof
+	clientSignature := c.hmac(storedKey.Sum(nil), c.authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+	c.serverKey = c.hmac(saltedPassword, "Server Key")
+
+	return clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// verifyServerFinal checks the server signature, proving the server knows
+// the password too
+func (c *scramClient) verifyServerFinal(serverFinal string) error {
+	fields := scramFields(serverFinal)
+	if e, ok := fields["e"]; ok {
+		return fmt.Errorf("server error: %s", e)
+	}
+	signature, err := base64.StdEncoding.DecodeString(fields["v"])
+	if err != nil || !hmac.Equal(signature, c.hmac(c.serverKey, c.authMessage)) {
+		return fmt.Errorf("invalid server signature")
+	}
+	return nil
+}
+
+func (c *scramClient) hmac(key []byte, message string) []byte {
+	mac := hmac.New(c.hash, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// scramFields splits a SCRAM message in its attributes
+func scramFields(message string) map[string]string {
+	fields := map[string]string{}
+	for _, field := range strings.Split(message, ",") {
+		if len(field) > 2 && field[1] == '=' {
+			fields[field[:1]] = field[2:]
+		}
+	}
+	return fields
+}
+
+const (
+	opReply = 1
+	opQuery = 2004
+
+	// queryFailure is set in the OP_REPLY flags if the query failed
+	queryFailure = 1 << 1
+	// maxMessageSize is the largest message accepted from the server
+	maxMessageSize = 48 * 1024 * 1024
+)
+
+var lastRequestID int32
+
+// runCommand runs cmd on dbname using the OP_QUERY wire protocol message and
+// unmarshals the reply into result, if it is not nil. It returns an error if
+// the command fails.
+func runCommand(conn net.Conn, dbname string, cmd interface{}, result interface{}) error {
+	doc, err := bson.Marshal(cmd)
+	if err != nil {
+		return errors.Wrap(err, "cannot encode the command")
+	}
+	requestID := atomic.AddInt32(&lastRequestID, 1)
+
+	msg := new(bytes.Buffer)
+	binary.Write(msg, binary.LittleEndian, []int32{0, requestID, 0, opQuery, 0})
+	msg.WriteString(dbname + ".$cmd\x00")
+	binary.Write(msg, binary.LittleEndian, []int32{0, -1}) // skip, return
+	msg.Write(doc)
+	buf := msg.Bytes()
+	binary.LittleEndian.PutUint32(buf, uint32(len(buf)))
+	if _, err := conn.Write(buf); err != nil {
+		return errors.Wrap(err, "cannot send the command")
+	}
+
+	reply, err := readReply(conn, requestID)
+	if err != nil {
+		return err
+	}
+	var status struct {
+		Ok     float64 `bson:"ok"`
+		Err    string  `bson:"$err"`
+		Errmsg string  `bson:"errmsg"`
+		Code   int     `bson:"code"`
+	}
+	if err := bson.Unmarshal(reply, &status); err != nil {
+		return errors.Wrap(err, "cannot decode the reply")
+	}
+	if status.Err != "" {
+		return fmt.Errorf("%s", status.Err)
+	}
+	if status.Ok != 1 {
+		if status.Errmsg == "" {
+			status.Errmsg = "command failed"
+		}
+		return fmt.Errorf("%s (code %d)", status.Errmsg, status.Code)
+	}
+	if result == nil {
+		return nil
+	}
+	return errors.Wrap(bson.Unmarshal(reply, result), "cannot decode the reply")
+}
+
+// readReply reads an OP_REPLY message answering requestID and returns its
+// first document
+func readReply(conn net.Conn, requestID int32) ([]byte, error) {
+	header := make([]byte, 36)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, errors.Wrap(err, "cannot read the reply")
+	}
+	length := int32(binary.LittleEndian.Uint32(header))
+	responseTo := int32(binary.LittleEndian.Uint32(header[8:]))
+	opCode := int32(binary.LittleEndian.Uint32(header[12:]))
+	flags := int32(binary.LittleEndian.Uint32(header[16:]))
+	returned := int32(binary.LittleEndian.Uint32(header[32:]))
+	if length < 36 || length > maxMessageSize {
+		return nil, fmt.Errorf("invalid reply length %d", length)
+	}
+	body := make([]byte, length-36)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return nil, errors.Wrap(err, "cannot read the reply")
+	}
+	if opCode != opReply || responseTo != requestID {
+		return nil, fmt.Errorf("unexpected reply (opCode %d, responseTo %d) to request %d", opCode, responseTo, requestID)
+	}
+	if returned < 1 || len(body) < 4 {
+		if flags&queryFailure != 0 {
+			return nil, fmt.Errorf("query failure")
+		}
+		return nil, fmt.Errorf("empty reply")
+	}
+	docLength := int(binary.LittleEndian.Uint32(body))
+	if docLength < 5 || docLength > len(body) {
+		return nil, fmt.Errorf("invalid reply document length %d", docLength)
+	}
+	return body[:docLength], nil
+}
@@ -0,0 +1,544 @@
+package db
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+	"labix.org/v2/mgo/bson"
+)
+
+// fakeMongod answers the OP_QUERY commands mgo and the authentication code
+// send, keeping the authenticated user per connection like a real server.
+// If there is a user, commands other than isMaster fail on connections not
+// authenticated.
+type fakeMongod struct {
+	ln             net.Listener
+	user           string
+	password       string
+	maxWireVersion int
+	// scramMechanisms are the SCRAM mechanisms the user has credentials for
+	scramMechanisms []string
+	// collections are the names and options of the collections in local
+	// returned by listCollections
+	collections []bson.M
+	// replies are the replies of other commands, by name
+	replies map[string]bson.M
+
+	lock       sync.Mutex
+	mechanisms []string
+	clients    []bson.M
+}
+
+// fakeConn is the state of a connection to fakeMongod
+type fakeConn struct {
+	user  string
+	nonce string
+	// peerSubject is the subject of the TLS client certificate
+	peerSubject string
+
+	// SCRAM conversation
+	mechanism       string
+	clientFirstBare string
+	serverFirst     string
+	verified        bool
+}
+
+func startFakeMongod(t *testing.T, user, password string, scramMechanisms ...string) *fakeMongod {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveFakeMongod(ln, user, password, scramMechanisms...)
+}
+
+// startFakeMongodTLS starts a fake server using TLS. Only user can
+// authenticate, with MONGODB-X509.
+func startFakeMongodTLS(t *testing.T, cfg *tls.Config, user string) *fakeMongod {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveFakeMongod(ln, user, "")
+}
+
+func serveFakeMongod(ln net.Listener, user, password string, scramMechanisms ...string) *fakeMongod {
+	s := &fakeMongod{
+		ln:              ln,
+		user:            user,
+		password:        password,
+		maxWireVersion:  7,
+		scramMechanisms: scramMechanisms,
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeMongod) Addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeMongod) Close() {
+	s.ln.Close()
+}
+
+// Clients returns the client metadata sent in isMaster
+func (s *fakeMongod) Clients() []bson.M {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]bson.M{}, s.clients...)
+}
+
+// Mechanisms returns the mechanisms used by the successful authentications
+func (s *fakeMongod) Mechanisms() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.mechanisms...)
+}
+
+func (s *fakeMongod) serve(conn net.Conn) {
+	defer conn.Close()
+	state := &fakeConn{}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			state.peerSubject = certs[0].Subject.String()
+		}
+	}
+	for {
+		header := make([]byte, 16)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		body := make([]byte, binary.LittleEndian.Uint32(header)-16)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		requestID := binary.LittleEndian.Uint32(header[4:])
+		if opCode := binary.LittleEndian.Uint32(header[12:]); opCode != opQuery {
+			return
+		}
+		// flags, full collection name, skip, return, query
+		name := body[4 : 4+bytes.IndexByte(body[4:], 0)]
+		dbname := strings.TrimSuffix(string(name), ".$cmd")
+		var cmd bson.D
+		if err := bson.Unmarshal(body[4+len(name)+1+8:], &cmd); err != nil {
+			return
+		}
+		if len(cmd) > 0 && cmd[0].Name == "$query" {
+			if query, ok := cmd[0].Value.(bson.D); ok {
+				cmd = query
+			}
+		}
+
+		doc, _ := bson.Marshal(s.run(state, dbname, cmd))
+		reply := new(bytes.Buffer)
+		binary.Write(reply, binary.LittleEndian, []uint32{uint32(36 + len(doc)), 0, requestID, opReply, 0, 0, 0, 0, 1})
+		reply.Write(doc)
+		conn.Write(reply.Bytes())
+	}
+}
+
+var authFailed = bson.M{"ok": 0, "errmsg": "Authentication failed.", "code": 18}
+
+func (s *fakeMongod) run(state *fakeConn, dbname string, cmd bson.D) bson.M {
+	if len(cmd) == 0 {
+		return bson.M{"ok": 0, "errmsg": "no command"}
+	}
+	args := cmd.Map()
+	switch strings.ToLower(cmd[0].Name) {
+	case "ismaster":
+		reply := bson.M{"ok": 1, "ismaster": true, "maxWireVersion": s.maxWireVersion}
+		if args["saslSupportedMechs"] == "admin."+s.user {
+			reply["saslSupportedMechs"] = s.scramMechanisms
+		}
+		if client, ok := args["client"]; ok {
+			var metadata bson.M
+			raw, _ := bson.Marshal(bson.M{"client": client})
+			bson.Unmarshal(raw, &metadata)
+			s.lock.Lock()
+			s.clients = append(s.clients, metadata["client"].(bson.M))
+			s.lock.Unlock()
+		}
+		return reply
+	case "getnonce":
+		state.nonce = "2375531c32080ae8"
+		return bson.M{"ok": 1, "nonce": state.nonce}
+	case "authenticate":
+		if args["mechanism"] == AuthMechanismX509 {
+			if dbname != ExternalAuthDB || args["user"] != s.user || state.peerSubject != s.user {
+				return authFailed
+			}
+			return s.authenticated(state, AuthMechanismX509)
+		}
+		key := md5.Sum([]byte(state.nonce + s.user + mongoPasswordDigest(s.user, s.password)))
+		if dbname != "admin" || args["user"] != s.user || args["key"] != hex.EncodeToString(key[:]) {
+			return authFailed
+		}
+		return s.authenticated(state, AuthMechanismCR)
+	case "saslstart":
+		state.mechanism, _ = args["mechanism"].(string)
+		payload, _ := args["payload"].([]byte)
+		if state.mechanism == AuthMechanismPlain {
+			if dbname != ExternalAuthDB || string(payload) != "\x00"+s.user+"\x00"+s.password {
+				return authFailed
+			}
+			return s.authenticated(state, AuthMechanismPlain)
+		}
+		if dbname != "admin" || !s.hasSCRAM(state.mechanism) || !strings.HasPrefix(string(payload), "n,,") {
+			return authFailed
+		}
+		state.clientFirstBare = strings.TrimPrefix(string(payload), "n,,")
+		fields := scramFields(state.clientFirstBare)
+		if fields["n"] != s.user {
+			return authFailed
+		}
+		state.serverFirst = "r=" + fields["r"] + "3rfcNHYJY1ZVvWVs7j,s=" + base64.StdEncoding.EncodeToString([]byte("salt")) + ",i=4096"
+		return bson.M{"ok": 1, "conversationId": 1, "done": false, "payload": []byte(state.serverFirst)}
+	case "saslcontinue":
+		payload, _ := args["payload"].([]byte)
+		if state.verified {
+			return s.authenticated(state, state.mechanism)
+		}
+		serverFinal, ok := s.verifySCRAM(state, string(payload))
+		if !ok {
+			return authFailed
+		}
+		state.verified = true
+		return bson.M{"ok": 1, "conversationId": 1, "done": false, "payload": []byte(serverFinal)}
+	}
+	if s.user != "" && state.user == "" {
+		return bson.M{"ok": 0, "errmsg": "command " + cmd[0].Name + " requires authentication", "code": 13}
+	}
+	if reply, ok := s.replies[cmd[0].Name]; ok {
+		return reply
+	}
+	if cmd[0].Name == "listCollections" {
+		var filter struct {
+			Name struct {
+				In []string `bson:"$in"`
+			} `bson:"name"`
+		}
+		raw, _ := bson.Marshal(args["filter"])
+		bson.Unmarshal(raw, &filter)
+		batch := []bson.M{}
+		for _, col := range s.collections {
+			for _, name := range filter.Name.In {
+				if col["name"] == name {
+					batch = append(batch, col)
+				}
+			}
+		}
+		return bson.M{"ok": 1, "cursor": bson.M{"id": int64(0), "ns": dbname + ".$cmd.listCollections", "firstBatch": batch}}
+	}
+	if cmd[0].Name == "sleep" {
+		millis, _ := args["millis"].(int)
+		time.Sleep(time.Duration(millis) * time.Millisecond)
+		return bson.M{"ok": 1, "slept": true}
+	}
+	return bson.M{"ok": 1}
+}
+
+func (s *fakeMongod) authenticated(state *fakeConn, mechanism string) bson.M {
+	state.user = s.user
+	s.lock.Lock()
+	s.mechanisms = append(s.mechanisms, mechanism)
+	s.lock.Unlock()
+	return bson.M{"ok": 1, "conversationId": 1, "done": true, "payload": []byte{}}
+}
+
+func (s *fakeMongod) hasSCRAM(mechanism string) bool {
+	for _, m := range s.scramMechanisms {
+		if m == mechanism {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySCRAM checks the client proof and returns the server final message
+func (s *fakeMongod) verifySCRAM(state *fakeConn, clientFinal string) (string, bool) {
+	h, password := sha256.New, s.password
+	if state.mechanism == AuthMechanismSCRAMSHA1 {
+		h, password = sha1.New, mongoPasswordDigest(s.user, s.password)
+	}
+	mac := func(key []byte, message string) []byte {
+		m := hmac.New(h, key)
+		m.Write([]byte(message))
+		return m.Sum(nil)
+	}
+	sum := func(b []byte) []byte {
+		d := h()
+		d.Write(b)
+		return d.Sum(nil)
+	}
+
+	i := strings.Index(clientFinal, ",p=")
+	if i == -1 {
+		return "", false
+	}
+	proof, _ := base64.StdEncoding.DecodeString(clientFinal[i+3:])
+	saltedPassword := pbkdf2.Key([]byte(password), []byte("salt"), 4096, h().Size(), h)
+	storedKey := sum(mac(saltedPassword, "Client Key"))
+	authMessage := state.clientFirstBare + "," + state.serverFirst + "," + clientFinal[:i]
+	clientSignature := mac(storedKey, authMessage)
+	if len(proof) != len(clientSignature) {
+		return "", false
+	}
+	clientKey := make([]byte, len(proof))
+	for i := range proof {
+		clientKey[i] = proof[i] ^ clientSignature[i]
+	}
+	if !hmac.Equal(sum(clientKey), storedKey) {
+		return "", false
+	}
+	return "v=" + base64.StdEncoding.EncodeToString(mac(mac(saltedPassword, "Server Key"), authMessage)), true
+}
+
+func TestSCRAMClient(t *testing.T) {
+	// Examples from RFC 5802 and RFC 7677
+	tests := []struct {
+		hash        func() hash.Hash
+		nonce       string
+		serverFirst string
+		clientFinal string
+		serverFinal string
+	}{
+		{
+			hash:        sha1.New,
+			nonce:       "fyko+d2lbbFgONRv9qkxdawL",
+			serverFirst: "r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+			clientFinal: "c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+			serverFinal: "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+		},
+		{
+			hash:        sha256.New,
+			nonce:       "rOprNGfwEbeRWgbNEkqO",
+			serverFirst: "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			clientFinal: "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+			serverFinal: "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+		},
+	}
+
+	for _, test := range tests {
+		client := newSCRAMClient(test.hash, "user", "pencil")
+		client.nonce = test.nonce
+		if got, expect := client.clientFirst(), "n,,n=user,r="+test.nonce; got != expect {
+			t.Errorf("invalid client first message. got %q, expect %q", got, expect)
+		}
+		clientFinal, err := client.clientFinal(test.serverFirst)
+		if err != nil {
+			t.Errorf("%s: %s", client.mechanism(), err)
+			continue
+		}
+		if clientFinal != test.clientFinal {
+			t.Errorf("%s: invalid client final message.\ngot:    %s\nexpect: %s", client.mechanism(), clientFinal, test.clientFinal)
+		}
+		if err := client.verifyServerFinal(test.serverFinal); err != nil {
+			t.Errorf("%s: %s", client.mechanism(), err)
+		}
+		if err := client.verifyServerFinal("v=" + base64.StdEncoding.EncodeToString([]byte("forged"))); err == nil {
+			t.Errorf("%s: a wrong server signature should fail", client.mechanism())
+		}
+	}
+
+	client := newSCRAMClient(sha1.New, "user", "pencil")
+	for _, serverFirst := range []string{
+		"r=anothernonce,s=QSXCR+Q6sek8bf92,i=4096",
+		"r=" + client.nonce + ",s=QSXCR+Q6sek8bf92,i=4096",
+		"r=" + client.nonce + "server,s=QSXCR+Q6sek8bf92,i=1",
+	} {
+		if _, err := client.clientFinal(serverFirst); err == nil {
+			t.Errorf("%s: expected error", serverFirst)
+		}
+	}
+}
+
+func TestSASLPrep(t *testing.T) {
+	for _, password := range []string{"", "pencil", "p@ss w0rd!~"} {
+		got, err := saslPrep(password)
+		if err != nil {
+			t.Errorf("%q: %s", password, err)
+		} else if got != password {
+			t.Errorf("%q: got %q", password, got)
+		}
+	}
+	for _, password := range []string{"pässword", "pass\u00a0word", "pass\tword", "pass\x7fword"} {
+		if _, err := saslPrep(password); err == nil {
+			t.Errorf("%q: expected error", password)
+		}
+	}
+}
+
+func TestDialAuth(t *testing.T) {
+	bothSCRAM := startFakeMongod(t, "admin", "s3cr3t", AuthMechanismSCRAMSHA1, AuthMechanismSCRAMSHA256)
+	defer bothSCRAM.Close()
+	onlySHA1 := startFakeMongod(t, "admin", "s3cr3t", AuthMechanismSCRAMSHA1)
+	defer onlySHA1.Close()
+
+	tests := []struct {
+		server    *fakeMongod
+		mechanism string
+		expect    string
+	}{
+		{bothSCRAM, "", AuthMechanismSCRAMSHA256},
+		{onlySHA1, "", AuthMechanismSCRAMSHA1},
+		{bothSCRAM, AuthMechanismSCRAMSHA1, AuthMechanismSCRAMSHA1},
+		{bothSCRAM, AuthMechanismSCRAMSHA256, AuthMechanismSCRAMSHA256},
+		{bothSCRAM, AuthMechanismCR, AuthMechanismCR},
+		{bothSCRAM, AuthMechanismPlain, AuthMechanismPlain},
+	}
+
+	for _, test := range tests {
+		opts := DialOptions{
+			Username:       "admin",
+			Password:       "s3cr3t",
+			AuthMechanism:  test.mechanism,
+			ConnectTimeout: 300 * time.Millisecond,
+		}
+		session, err := Dial(test.server.Addr(), opts)
+		if err != nil {
+			t.Errorf("mechanism %q: cannot connect: %s", test.mechanism, err)
+			continue
+		}
+		err = session.Run("ping", nil)
+		session.Close()
+		if err != nil {
+			t.Errorf("mechanism %q: the connection is not authenticated: %s", test.mechanism, err)
+		}
+		mechanisms := test.server.Mechanisms()
+		if len(mechanisms) == 0 || mechanisms[len(mechanisms)-1] != test.expect {
+			t.Errorf("mechanism %q: got %v, expect %s", test.mechanism, mechanisms, test.expect)
+		}
+
+		opts.Password = "wrong"
+		conn, err := net.Dial("tcp", test.server.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = handshake(conn, opts, time.Second)
+		conn.Close()
+		if err == nil || !strings.Contains(err.Error(), "Authentication failed") {
+			t.Errorf("mechanism %q: expected an authentication error, got: %v", test.mechanism, err)
+		}
+	}
+
+	// Dial returns the authentication error instead of mgo's no reachable
+	// servers
+	opts := DialOptions{Username: "admin", Password: "wrong", ConnectTimeout: 300 * time.Millisecond}
+	if session, err := Dial(bothSCRAM.Addr(), opts); err == nil {
+		session.Close()
+		t.Errorf("a wrong password should fail")
+	} else if !strings.Contains(err.Error(), "Authentication failed") {
+		t.Errorf("expected an authentication error, got: %s", err)
+	}
+
+	// Without credentials the connections are not authenticated
+	session, err := Dial(bothSCRAM.Addr(), DialOptions{ConnectTimeout: 300 * time.Millisecond})
+	if err == nil {
+		session.Close()
+		t.Errorf("connecting without credentials should fail")
+	}
+}
+
+func TestDialX509(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pt-mongodb-summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(caFile, ca.pem, 0600)
+	writeCertificateKeyFile := func(name string, cert tls.Certificate) string {
+		keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})...)
+		filename := filepath.Join(dir, name)
+		ioutil.WriteFile(filename, data, 0600)
+		return filename
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	server := startFakeMongodTLS(t, &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, 2, "localhost")},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}, "CN=client")
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Addr())
+	host := net.JoinHostPort("localhost", port)
+
+	opts := DialOptions{
+		AuthMechanism:         AuthMechanismX509,
+		TLS:                   true,
+		TLSCAFile:             caFile,
+		TLSCertificateKeyFile: writeCertificateKeyFile("client.pem", ca.issue(t, 3, "client")),
+		ConnectTimeout:        time.Second,
+	}
+	session, err := Dial(host, opts)
+	if err != nil {
+		t.Fatalf("cannot connect: %s", err)
+	}
+	err = session.Run("ping", nil)
+	session.Close()
+	if err != nil {
+		t.Errorf("the connection is not authenticated: %s", err)
+	}
+	if mechanisms := server.Mechanisms(); len(mechanisms) == 0 || mechanisms[0] != AuthMechanismX509 {
+		t.Errorf("invalid mechanisms. got %v, expect %s", mechanisms, AuthMechanismX509)
+	}
+
+	// A certificate for another user is rejected
+	opts.TLSCertificateKeyFile = writeCertificateKeyFile("other.pem", ca.issue(t, 4, "other"))
+	cfg, err := opts.TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dial, _ := opts.netDialFunc(time.Second)
+	conn, err := tlsDialFunc(cfg, dial, time.Second)(host)
+	if err != nil {
+		t.Fatalf("cannot connect: %s", err)
+	}
+	opts.Username = "CN=other"
+	err = handshake(conn, opts, time.Second)
+	conn.Close()
+	if err == nil || !strings.Contains(err.Error(), "Authentication failed") {
+		t.Errorf("expected an authentication error, got: %v", err)
+	}
+}
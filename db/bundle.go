@@ -0,0 +1,351 @@
+package db
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+	"github.com/pkg/errors"
+	"labix.org/v2/mgo"
+)
+
+// sampleFiles are the files of the commands in a bundle without manifest,
+// laid out like test/sample. ReplicaSetGetStatus is read from
+// replsetgetstatus_NN.json, NN being the order in which the host was
+// connected, or from replsetgetstatus.json. FindOne only answers the
+// config.version document of a sharded cluster, which identifies it.
+var sampleFiles = map[string]string{
+	"BuildInfo":              "buildinfo.json",
+	"FindOne config.version": "configversion.json",
+	"GetCmdLineOpts":         "cmdopts.json",
+	"GetCurrentOp":           "currentop.json",
+	"HostInfo":               "hostinfo.json",
+	"IsMaster":               "ismaster.json",
+	"ListShards":             "shardsinfo.json",
+	"ReplicaSetGetStatus":    "replsetgetstatus.json",
+	"ServerStatus":           "serverstatus.json",
+}
+
+// Bundle is a capture of the responses of a previous run, saved with
+// --save-dir or --bundle, or laid out like test/sample. Its connectors answer
+// the commands with the saved responses, without any network access.
+type Bundle struct {
+	// Manifest is nil for bundles laid out like test/sample
+	Manifest *BundleManifest
+
+	lock  sync.Mutex
+	files map[string][]byte
+	// used are the manifest entries already replayed
+	used map[int]bool
+	// hosts are the hosts connected, in order
+	hosts map[string]int
+}
+
+// OpenBundle reads a bundle from a directory or a .tar.gz file
+func OpenBundle(path string) (*Bundle, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open the bundle")
+	}
+	var files map[string][]byte
+	if fi.IsDir() {
+		files, err = readBundleDir(path)
+	} else {
+		files, err = readBundleTarGz(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newBundle(files)
+}
+
+func newBundle(files map[string][]byte) (*Bundle, error) {
+	b := &Bundle{files: files, used: make(map[int]bool), hosts: make(map[string]int)}
+	if buf, ok := files[ManifestFile]; ok {
+		b.Manifest = &BundleManifest{}
+		if err := json.Unmarshal(buf, b.Manifest); err != nil {
+			return nil, errors.Wrap(err, "invalid bundle manifest")
+		}
+		if b.Manifest.FormatVersion > BundleFormatVersion {
+			return nil, fmt.Errorf("unsupported bundle format version %d", b.Manifest.FormatVersion)
+		}
+	}
+	return b, nil
+}
+
+func readBundleDir(dir string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = buf
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read the bundle")
+	}
+	return files, nil
+}
+
+func readBundleTarGz(filename string) (map[string][]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open the bundle")
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid bundle")
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid bundle")
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		buf, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read the bundle")
+		}
+		files[filepath.ToSlash(filepath.Clean(hdr.Name))] = buf
+	}
+	return files, nil
+}
+
+// Factory returns a ConnectorFactory whose connectors answer from the bundle
+func (b *Bundle) Factory() ConnectorFactory {
+	return func(host string) MongoConnector {
+		return &bundleConnector{bundle: b, host: host}
+	}
+}
+
+// response decodes the saved response of a command into result. The saved
+// commands are replayed in order. A command run more times than it was saved
+// gets the last response again.
+func (b *Bundle) response(host, command string, args interface{}, result interface{}) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.Manifest == nil {
+		return b.sampleResponse(host, command, args, result)
+	}
+
+	var wantArgs []byte
+	if args != nil {
+		var err error
+		if wantArgs, err = json.Marshal(args); err != nil {
+			return err
+		}
+	}
+	found := -1
+	for i, entry := range b.Manifest.Entries {
+		if entry.Host != host || entry.Command != command || !sameJSON(entry.Args, wantArgs) {
+			continue
+		}
+		found = i
+		if !b.used[i] {
+			break
+		}
+	}
+	if found < 0 {
+		return fmt.Errorf("%s on %s is not in the bundle", command, host)
+	}
+	b.used[found] = true
+
+	entry := b.Manifest.Entries[found]
+	if entry.Error != "" {
+		return errors.New(entry.Error)
+	}
+	if entry.File == "" || result == nil {
+		return nil
+	}
+	buf, ok := b.files[entry.File]
+	if !ok {
+		return fmt.Errorf("%s is missing in the bundle", entry.File)
+	}
+	if entry.Reply {
+		return errors.Wrapf(unmarshalExtJSON(buf, result), "invalid %s", entry.File)
+	}
+	return errors.Wrapf(json.Unmarshal(buf, result), "invalid %s", entry.File)
+}
+
+func (b *Bundle) sampleResponse(host, command string, args interface{}, result interface{}) error {
+	if command == "Connect" {
+		if _, ok := b.hosts[host]; !ok {
+			b.hosts[host] = len(b.hosts)
+		}
+		return nil
+	}
+	key := command
+	if a, ok := args.(map[string]interface{}); ok && command == "FindOne" {
+		key = fmt.Sprintf("%s %s.%s", command, a["db"], a["collection"])
+	}
+	name, ok := sampleFiles[key]
+	if !ok {
+		return fmt.Errorf("%s is not in the bundle", key)
+	}
+	buf, ok := b.files[name]
+	if command == "ReplicaSetGetStatus" {
+		if hostBuf, hostOk := b.files[fmt.Sprintf("replsetgetstatus_%02d.json", b.hosts[host])]; hostOk {
+			buf, ok = hostBuf, true
+		}
+	}
+	if !ok {
+		return fmt.Errorf("%s is not in the bundle", key)
+	}
+	return errors.Wrapf(json.Unmarshal(buf, result), "invalid %s", name)
+}
+
+// sameJSON tells if a and b are the same JSON, no matter how they are indented
+func sameJSON(a, b []byte) bool {
+	var ca, cb bytes.Buffer
+	if len(a) > 0 && json.Compact(&ca, a) != nil {
+		return false
+	}
+	if len(b) > 0 && json.Compact(&cb, b) != nil {
+		return false
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// bundleConnector answers the commands of one host from a bundle
+type bundleConnector struct {
+	bundle *Bundle
+	host   string
+}
+
+func (c *bundleConnector) BuildInfo() (bi mgo.BuildInfo, err error) {
+	err = c.bundle.response(c.host, "BuildInfo", nil, &bi)
+	return bi, err
+}
+
+func (c *bundleConnector) Close() {}
+
+func (c *bundleConnector) CollectionNames(dbname string) (names []string, err error) {
+	err = c.bundle.response(c.host, "CollectionNames", map[string]interface{}{"db": dbname}, &names)
+	return names, err
+}
+
+func (c *bundleConnector) Connect() error {
+	return c.bundle.response(c.host, "Connect", nil, nil)
+}
+
+func (c *bundleConnector) DatabaseNames() (names []string, err error) {
+	err = c.bundle.response(c.host, "DatabaseNames", nil, &names)
+	return names, err
+}
+
+func (c *bundleConnector) DbRun(dbname string, cmd interface{}, result interface{}) error {
+	return c.bundle.response(c.host, "DbRun", map[string]interface{}{"db": dbname, "cmd": cmd}, result)
+}
+
+func (c *bundleConnector) FindOne(dbname string, collection string, query interface{}, sort []string, result interface{}) error {
+	args := map[string]interface{}{"db": dbname, "collection": collection, "query": query, "sort": sort}
+	return c.bundle.response(c.host, "FindOne", args, result)
+}
+
+func (c *bundleConnector) GetCmdLineOpts() (clo proto.CommandLineOptions, err error) {
+	// Bundles saved by older versions can have the secrets
+	err = c.bundle.response(c.host, "GetCmdLineOpts", nil, &clo)
+	RedactCmdLineOpts(&clo)
+	return clo, err
+}
+
+func (c *bundleConnector) GetCurrentOp() (co proto.CurrentOp, err error) {
+	err = c.bundle.response(c.host, "GetCurrentOp", nil, &co)
+	return co, err
+}
+
+func (c *bundleConnector) GetOplogCollection() (name string, err error) {
+	err = c.bundle.response(c.host, "GetOplogCollection", nil, &name)
+	return name, err
+}
+
+func (c *bundleConnector) GetOplogEntry(oplogCol string) (*OplogEntry, error) {
+	entry := &OplogEntry{}
+	if err := c.bundle.response(c.host, "GetOplogEntry", map[string]interface{}{"collection": oplogCol}, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (c *bundleConnector) HostInfo() (hi proto.HostInfo, err error) {
+	err = c.bundle.response(c.host, "HostInfo", nil, &hi)
+	return hi, err
+}
+
+func (c *bundleConnector) IsMaster() (md proto.MasterDoc, err error) {
+	err = c.bundle.response(c.host, "IsMaster", nil, &md)
+	return md, err
+}
+
+func (c *bundleConnector) ListShards() (*proto.ShardsInfo, error) {
+	si := &proto.ShardsInfo{}
+	if err := c.bundle.response(c.host, "ListShards", nil, si); err != nil {
+		return nil, err
+	}
+	return si, nil
+}
+
+func (c *bundleConnector) ReplicaSetGetStatus() (rss proto.ReplicaSetStatus, err error) {
+	err = c.bundle.response(c.host, "ReplicaSetGetStatus", nil, &rss)
+	return rss, err
+}
+
+func (c *bundleConnector) RolesCount() (n int, err error) {
+	err = c.bundle.response(c.host, "RolesCount", nil, &n)
+	return n, err
+}
+
+func (c *bundleConnector) ServerStatus() (ss proto.ServerStatus, err error) {
+	err = c.bundle.response(c.host, "ServerStatus", nil, &ss)
+	return ss, err
+}
+
+// Session returns nil. There is no server behind a bundle.
+func (c *bundleConnector) Session() *mgo.Session {
+	return nil
+}
+
+func (c *bundleConnector) SessionRun(cmd interface{}, result interface{}) error {
+	return c.bundle.response(c.host, "SessionRun", map[string]interface{}{"cmd": cmd}, result)
+}
+
+func (c *bundleConnector) UsersCount() (n int, err error) {
+	err = c.bundle.response(c.host, "UsersCount", nil, &n)
+	return n, err
+}
+
+func (c *bundleConnector) ConnectionPoolStats() (stats interface{}, err error) {
+	err = c.bundle.response(c.host, "ConnectionPoolStats", nil, &stats)
+	return stats, err
+}
+
+func (c *bundleConnector) ShardConnectionPoolStats() (stats interface{}, err error) {
+	err = c.bundle.response(c.host, "ShardConnectionPoolStats", nil, &stats)
+	return stats, err
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"labix.org/v2/mgo/bson"
+)
+
+func TestBundleReplay(t *testing.T) {
+	rec := NewRecorder()
+	factory := rec.Factory(func(host string) MongoConnector {
+		server := &statusServer{fakeConnector{host: host}}
+		if host == "down:27017" {
+			server.err = fmt.Errorf("no reachable servers")
+		}
+		return server
+	})
+	conn := factory("db1:27017")
+	conn.Connect()
+	conn.ServerStatus()
+	conn.DbRun("local", bson.M{"collStats": "oplog.rs"}, &bson.M{})
+	factory("down:27017").Connect()
+
+	dir, err := ioutil.TempDir("", "bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archive := &bytes.Buffer{}
+	if err := rec.WriteTarGz(archive, nil); err != nil {
+		t.Fatal(err)
+	}
+	tarGz := filepath.Join(dir, "bundle.tar.gz")
+	if err := ioutil.WriteFile(tarGz, archive.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.WriteDir(filepath.Join(dir, "bundle"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{tarGz, filepath.Join(dir, "bundle")} {
+		b, err := OpenBundle(path)
+		if err != nil {
+			t.Fatalf("cannot open %s: %s", path, err)
+		}
+		offline := b.Factory()("db1:27017")
+		if err := offline.Connect(); err != nil {
+			t.Errorf("%s: the saved connection must succeed: %s", path, err)
+		}
+		if ss, err := offline.ServerStatus(); err != nil || ss.Host != "db1:27017" || ss.Version != "3.4.1" {
+			t.Errorf("%s: invalid saved server status: %+v, %v", path, ss, err)
+		}
+		// Commands run more times than saved get the last response
+		if ss, err := offline.ServerStatus(); err != nil || ss.Version != "3.4.1" {
+			t.Errorf("%s: invalid repeated server status: %+v, %v", path, ss, err)
+		}
+		if err := offline.DbRun("local", bson.M{"collStats": "oplog.rs"}, &bson.M{}); err == nil || err.Error() != "not authorized on local" {
+			t.Errorf("%s: the saved error must be returned: %v", path, err)
+		}
+		if err := offline.DbRun("admin", bson.M{"collStats": "oplog.rs"}, &bson.M{}); err == nil {
+			t.Errorf("%s: commands with other arguments are not in the bundle", path)
+		}
+		if _, err := offline.HostInfo(); err == nil {
+			t.Errorf("%s: commands that were not run are not in the bundle", path)
+		}
+		if err := b.Factory()("down:27017").Connect(); err == nil {
+			t.Errorf("%s: the saved connection error must be returned", path)
+		}
+		if err := b.Factory()("other:27017").Connect(); err == nil {
+			t.Errorf("%s: hosts that were not contacted are not in the bundle", path)
+		}
+	}
+}
+
+func TestSampleBundle(t *testing.T) {
+	b, err := OpenBundle("../test/sample")
+	if err != nil {
+		t.Fatalf("cannot open the sample bundle: %s", err)
+	}
+	if b.Manifest != nil {
+		t.Errorf("the sample has no manifest")
+	}
+	factory := b.Factory()
+	var sets []string
+	for _, host := range []string{"localhost", "localhost:17001", "localhost:18001"} {
+		conn := factory(host)
+		if err := conn.Connect(); err != nil {
+			t.Fatalf("cannot connect to %s: %s", host, err)
+		}
+		rss, err := conn.ReplicaSetGetStatus()
+		if err != nil {
+			t.Fatalf("cannot get the replica set status of %s: %s", host, err)
+		}
+		sets = append(sets, rss.Set)
+	}
+	if sets[0] != "" || sets[1] != "r1" || sets[2] != "r2" {
+		t.Errorf("each host must get its own replsetgetstatus_NN.json: %v", sets)
+	}
+	if ss, err := factory("localhost").ServerStatus(); err != nil || ss.Process != "mongos" {
+		t.Errorf("invalid server status: %+v, %v", ss, err)
+	}
+	if _, err := factory("localhost").UsersCount(); err == nil {
+		t.Errorf("expected error for a command without a sample file")
+	}
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"labix.org/v2/mgo"
+)
+
+// mgo has no support for cancellation so, the functions in this file run
+// the blocking calls in a goroutine and stop waiting for them when the
+// context is done. The abandoned call finishes in the background when the
+// socket timeout expires.
+
+// RunWithTimeout runs f and waits until it finishes, timeout expires or ctx
+// is done, whatever happens first. A zero timeout means no timeout other
+// than the one in ctx. f returns its result instead of setting variables of
+// the caller because it keeps running after giving up.
+func RunWithTimeout(ctx context.Context, timeout time.Duration, f func() (interface{}, error)) (interface{}, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		value, err := f()
+		ch <- result{value, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.value, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// DialContext works like Dial but gives up when ctx is done.
+func DialContext(ctx context.Context, host string, opts DialOptions) (*mgo.Session, error) {
+	type dialResult struct {
+		session *mgo.Session
+		err     error
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ch := make(chan dialResult, 1)
+	go func() {
+		session, err := Dial(host, opts)
+		ch <- dialResult{session, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.session, r.err
+	case <-ctx.Done():
+		// Don't leak the session if the dial succeeds after giving up
+		go func() {
+			if r := <-ch; r.session != nil {
+				r.session.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// IsTimeout returns true if err was caused by a connection or command timeout
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	err = errors.Cause(err)
+	if err == context.DeadlineExceeded {
+		return true
+	}
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
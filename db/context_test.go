@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"labix.org/v2/mgo/bson"
+)
+
+func TestRunWithTimeout(t *testing.T) {
+	_, err := RunWithTimeout(context.Background(), 0, func() (interface{}, error) {
+		return nil, fmt.Errorf("some error")
+	})
+	if err == nil || err.Error() != "some error" {
+		t.Errorf("expected the function error, got %v", err)
+	}
+
+	v, err := RunWithTimeout(context.Background(), 0, func() (interface{}, error) {
+		return 42, nil
+	})
+	if err != nil || v != 42 {
+		t.Errorf("expected the function result, got %v, %v", v, err)
+	}
+
+	start := time.Now()
+	v, err = RunWithTimeout(context.Background(), 50*time.Millisecond, func() (interface{}, error) {
+		time.Sleep(time.Second)
+		return 42, nil
+	})
+	if err != context.DeadlineExceeded || v != nil {
+		t.Errorf("expected a deadline exceeded error, got %v, %v", v, err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("RunWithTimeout didn't give up in time")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = RunWithTimeout(ctx, time.Minute, func() (interface{}, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	})
+	if err != context.Canceled {
+		t.Errorf("expected a canceled error, got %v", err)
+	}
+}
+
+func TestCommandTimeoutResult(t *testing.T) {
+	server := startFakeMongod(t, "", "")
+	defer server.Close()
+
+	conn := NewConnectorFactory(context.Background(), DialOptions{CommandTimeout: 50 * time.Millisecond})(server.Addr())
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("cannot connect: %s", err)
+	}
+	defer conn.Close()
+
+	result := struct {
+		Slept bool `bson:"slept"`
+	}{}
+	err := conn.SessionRun(bson.D{{"sleep", 1}, {"millis", 200}}, &result)
+	if !IsTimeout(err) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	// The abandoned command must not set the result when it finishes
+	time.Sleep(300 * time.Millisecond)
+	if result.Slept {
+		t.Errorf("the result was changed after giving up")
+	}
+
+	conn = NewConnectorFactory(context.Background(), DialOptions{CommandTimeout: time.Second})(server.Addr())
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("cannot connect: %s", err)
+	}
+	defer conn.Close()
+	if err := conn.SessionRun(bson.D{{"sleep", 1}, {"millis", 0}}, &result); err != nil || !result.Slept {
+		t.Errorf("expected the command result, got %+v, %v", result, err)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestIsTimeout(t *testing.T) {
+	tests := []struct {
+		err    error
+		expect bool
+	}{
+		{nil, false},
+		{fmt.Errorf("auth failed"), false},
+		{context.DeadlineExceeded, true},
+		{errors.Wrap(context.DeadlineExceeded, "cannot get server status"), true},
+		{timeoutError{}, true},
+		{fmt.Errorf("no reachable servers"), false},
+	}
+	for _, test := range tests {
+		if got := IsTimeout(test.err); got != test.expect {
+			t.Errorf("IsTimeout(%v): got %v, expect %v", test.err, got, test.expect)
+		}
+	}
+}
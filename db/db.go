@@ -1,7 +1,10 @@
 package db
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/percona/pt-mongodb-summary/proto"
 	"github.com/pkg/errors"
@@ -13,6 +16,10 @@ type DB struct {
 	host      string
 	connected bool
 	session   *mgo.Session
+	opts      DialOptions
+	ctx       context.Context
+	// onReply gets the raw reply of each command decoded by runInto
+	onReply func(bson.Raw)
 }
 
 type OplogEntry struct {
@@ -58,12 +65,32 @@ type MongoConnector interface {
 func NewMongoConnector(host string) MongoConnector {
 	db := &DB{
 		host: host,
+		ctx:  context.Background(),
 	}
 	return db
 }
 
+// NewConnectorFactory returns a ConnectorFactory whose connectors dial using
+// opts. Connecting and running commands give up when ctx is done or when
+// the connect and command timeouts in opts expire.
+func NewConnectorFactory(ctx context.Context, opts DialOptions) ConnectorFactory {
+	return func(host string) MongoConnector {
+		return &DB{
+			host: host,
+			opts: opts,
+			ctx:  ctx,
+		}
+	}
+}
+
 func (m *DB) BuildInfo() (mgo.BuildInfo, error) {
-	return m.session.BuildInfo()
+	bi, err := m.run(func() (interface{}, error) {
+		return m.session.BuildInfo()
+	})
+	if err != nil {
+		return mgo.BuildInfo{}, err
+	}
+	return bi.(mgo.BuildInfo), nil
 }
 
 func (m *DB) Close() {
@@ -73,16 +100,18 @@ func (m *DB) Close() {
 }
 
 func (m *DB) CollectionNames(dbname string) ([]string, error) {
-	collectionNames, err := m.session.DB(dbname).CollectionNames()
+	collectionNames, err := m.run(func() (interface{}, error) {
+		return m.session.DB(dbname).CollectionNames()
+	})
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot get collection names for db %s", dbname)
 	}
-	return collectionNames, nil
+	return collectionNames.([]string), nil
 }
 
 func (m *DB) Connect() error {
 	var err error
-	m.session, err = mgo.Dial(m.host)
+	m.session, err = DialContext(m.ctx, m.host, m.opts)
 	if err != nil {
 		return err
 	}
@@ -90,73 +119,125 @@ func (m *DB) Connect() error {
 }
 
 func (m *DB) DatabaseNames() ([]string, error) {
-	return m.session.DatabaseNames()
+	names, err := m.run(func() (interface{}, error) {
+		return m.session.DatabaseNames()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names.([]string), nil
 }
 
 func (m *DB) FindOne(dbname string, collection string, query interface{}, sort []string, result interface{}) error {
 	db := m.session.DB(dbname)
 	col := db.C(collection)
 
-	err := col.Find(query).Sort(sort...).One(result)
-	if err != nil {
-		return err
-	}
-	return nil
+	return m.runInto(func(out interface{}) error {
+		return col.Find(query).Sort(sort...).One(out)
+	}, result)
 }
 
 func (m *DB) GetCmdLineOpts() (proto.CommandLineOptions, error) {
 	clo := proto.CommandLineOptions{}
-	err := m.session.DB("admin").Run(bson.D{{"getCmdLineOpts", 1}, {"recordStats", 1}}, &clo)
+	err := m.runInto(func(out interface{}) error {
+		return m.session.DB("admin").Run(bson.D{{"getCmdLineOpts", 1}, {"recordStats", 1}}, out)
+	}, &clo)
 	if err != nil {
 		return clo, errors.Wrap(err, "cannot get command line options")
 	}
+	RedactCmdLineOpts(&clo)
 	return clo, nil
 }
 
 func (m *DB) GetCurrentOp() (proto.CurrentOp, error) {
 	co := proto.CurrentOp{}
 
-	err := m.session.DB("admin").C("$cmd.sys.inprog").Find(nil).One(&co)
+	err := m.runInto(func(out interface{}) error {
+		return m.session.DB("admin").C("$cmd.sys.inprog").Find(nil).One(out)
+	}, &co)
 	if err != nil {
 		return co, err
 	}
 	return co, nil
 }
 
+// GetOplogCollection returns the name of the oplog collection in the local
+// database: oplog.rs for replica sets or oplog.$main for master/slave
 func (m *DB) GetOplogCollection() (string, error) {
-	oplog := "oplog.rs"
-
-	db := m.session.DB("local")
-	nsCol := db.C("system.namespaces")
-
-	var res interface{}
-	if err := nsCol.Find(bson.M{"name": "local." + oplog}).One(&res); err == nil {
-		return oplog, nil
+	oplogs := []string{"oplog.rs", "oplog.$main"}
+	entries, err := m.localCollections(oplogs...)
+	if err != nil {
+		return "", err
 	}
-
-	oplog = "oplog.$main"
-	if err := nsCol.Find(bson.M{"name": "local." + oplog}).One(&res); err == nil {
-		return oplog, nil
+	for _, oplog := range oplogs {
+		for _, entry := range entries {
+			if entry.Name == oplog {
+				return oplog, nil
+			}
+		}
 	}
 
 	return "", fmt.Errorf("neither master/slave nor replica set replication detected")
 }
 
 func (m *DB) GetOplogEntry(oplogCol string) (*OplogEntry, error) {
+	entries, err := m.localCollections(oplogCol)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("local.%s, or its options, not found", oplogCol)
+	}
+	return &entries[0], nil
+}
+
+// localCollections returns the names and options of the given collections in
+// the local database. It uses listCollections, falling back to reading
+// system.namespaces on servers before 3.0, where that command doesn't exist.
+// system.namespaces only exists with MMAPv1, so it can't be read first.
+func (m *DB) localCollections(names ...string) ([]OplogEntry, error) {
 	db := m.session.DB("local")
-	nsCol := db.C("system.namespaces")
-	olEntry := &OplogEntry{}
 
-	err := nsCol.Find(bson.M{"name": "local." + oplogCol}).One(&olEntry)
-	if err != nil {
-		return nil, fmt.Errorf("local.%s, or its options, not found in system.namespaces collection", oplogCol)
+	var reply struct {
+		Cursor struct {
+			FirstBatch []OplogEntry `bson:"firstBatch"`
+		} `bson:"cursor"`
+	}
+	cmd := bson.D{{"listCollections", 1}, {"filter", bson.M{"name": bson.M{"$in": names}}}}
+	err := m.runInto(func(out interface{}) error {
+		return db.Run(cmd, out)
+	}, &reply)
+	if err == nil {
+		return reply.Cursor.FirstBatch, nil
 	}
-	return olEntry, nil
+	if IsTimeout(err) {
+		return nil, err
+	}
+
+	namespaces := make([]string, len(names))
+	for i, name := range names {
+		namespaces[i] = "local." + name
+	}
+	res, nsErr := m.run(func() (interface{}, error) {
+		var entries []OplogEntry
+		err := db.C("system.namespaces").Find(bson.M{"name": bson.M{"$in": namespaces}}).All(&entries)
+		return entries, err
+	})
+	if nsErr != nil {
+		return nil, errors.Wrapf(nsErr, "cannot list the collections in the local database (listCollections: %s)", err)
+	}
+	entries := res.([]OplogEntry)
+	for i := range entries {
+		entries[i].Name = strings.TrimPrefix(entries[i].Name, "local.")
+	}
+	return entries, nil
 }
 
 func (m *DB) DbRun(dbName string, cmd interface{}, result interface{}) error {
 	db := m.session.DB(dbName)
-	err := db.Run(cmd, result)
+	err := m.runInto(func(out interface{}) error {
+		return db.Run(cmd, out)
+	}, result)
 	if err != nil {
 		return errors.Wrapf(err, "cannot run cmd on db %s", dbName)
 	}
@@ -165,7 +246,7 @@ func (m *DB) DbRun(dbName string, cmd interface{}, result interface{}) error {
 
 func (m *DB) HostInfo() (proto.HostInfo, error) {
 	hi := proto.HostInfo{}
-	err := m.session.Run(bson.M{"hostInfo": 1}, &hi)
+	err := m.SessionRun(bson.M{"hostInfo": 1}, &hi)
 	if err != nil {
 		return hi, errors.Wrap(err, "cannot get host info")
 	}
@@ -174,7 +255,7 @@ func (m *DB) HostInfo() (proto.HostInfo, error) {
 
 func (m *DB) IsMaster() (proto.MasterDoc, error) {
 	md := proto.MasterDoc{}
-	err := m.session.Run("isMaster", &md)
+	err := m.SessionRun("isMaster", &md)
 	if err != nil {
 		return md, errors.Wrap(err, "cannot get isMaster")
 	}
@@ -183,7 +264,7 @@ func (m *DB) IsMaster() (proto.MasterDoc, error) {
 
 func (m *DB) ListShards() (*proto.ShardsInfo, error) {
 	ls := proto.ShardsInfo{}
-	err := m.session.Run("listShards", &ls)
+	err := m.SessionRun("listShards", &ls)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot list shards")
 	}
@@ -192,7 +273,7 @@ func (m *DB) ListShards() (*proto.ShardsInfo, error) {
 
 func (m *DB) ReplicaSetGetStatus() (proto.ReplicaSetStatus, error) {
 	rss := proto.ReplicaSetStatus{}
-	err := m.session.Run(bson.M{"replSetGetStatus": 1}, &rss)
+	err := m.SessionRun(bson.M{"replSetGetStatus": 1}, &rss)
 	if err != nil {
 		return rss, errors.Wrap(err, "cannot get ReplicaSetStatus")
 	}
@@ -200,12 +281,20 @@ func (m *DB) ReplicaSetGetStatus() (proto.ReplicaSetStatus, error) {
 }
 
 func (m *DB) RolesCount() (int, error) {
-	return m.session.DB("admin").C("system.roles").Count()
+	n, err := m.run(func() (interface{}, error) {
+		return m.session.DB("admin").C("system.roles").Count()
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n.(int), nil
 }
 
 func (m *DB) ServerStatus() (proto.ServerStatus, error) {
 	stat := proto.ServerStatus{}
-	err := m.session.DB("admin").Run(bson.D{{"serverStatus", 1}, {"recordStats", 1}}, &stat)
+	err := m.runInto(func(out interface{}) error {
+		return m.session.DB("admin").Run(bson.D{{"serverStatus", 1}, {"recordStats", 1}}, out)
+	}, &stat)
 	if err != nil {
 		return stat, errors.Wrap(err, "cannot get server status")
 	}
@@ -217,23 +306,81 @@ func (m *DB) Session() *mgo.Session {
 }
 
 func (m *DB) SessionRun(cmd interface{}, result interface{}) error {
-	return m.session.Run(cmd, result)
+	return m.runInto(func(out interface{}) error {
+		return m.session.Run(cmd, out)
+	}, result)
 }
 
 func (m *DB) UsersCount() (int, error) {
-	return m.session.DB("admin").C("system.users").Count()
+	n, err := m.run(func() (interface{}, error) {
+		return m.session.DB("admin").C("system.users").Count()
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n.(int), nil
 }
 
 //TODO: do we need these functions?
 
 func (m *DB) ConnectionPoolStats() (interface{}, error) {
 	var stats interface{}
-	err := m.session.Run(bson.M{"connPoolStats": 1}, &stats)
+	err := m.SessionRun(bson.M{"connPoolStats": 1}, &stats)
 	return stats, err
 }
 
 func (m *DB) ShardConnectionPoolStats() (interface{}, error) {
 	var stats interface{}
-	err := m.session.Run(bson.M{"shardConnPoolStats": 1}, &stats)
+	err := m.SessionRun(bson.M{"shardConnPoolStats": 1}, &stats)
 	return stats, err
 }
+
+// run runs f giving up if the command timeout expires or the connector's
+// context is done
+func (m *DB) run(f func() (interface{}, error)) (interface{}, error) {
+	return RunWithTimeout(m.ctx, m.opts.CommandTimeout, f)
+}
+
+// runInto is run for functions decoding a reply into result. f decodes it
+// into a private value of the same type that is copied into result only if
+// f finishes in time, so a command finishing after giving up cannot change
+// result. If there is an onReply function, f gets the raw reply instead,
+// which is decoded into result and passed to onReply.
+func (m *DB) runInto(f func(interface{}) error, result interface{}) error {
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("the result must be a non nil pointer, not %T", result)
+	}
+	type reply struct {
+		value reflect.Value
+		raw   bson.Raw
+	}
+	res, err := m.run(func() (interface{}, error) {
+		r := reply{value: reflect.New(rv.Type().Elem())}
+		if m.onReply == nil {
+			return r, f(r.value.Interface())
+		}
+		if err := f(&r.raw); err != nil {
+			return r, err
+		}
+		r.raw.Data = append([]byte{}, r.raw.Data...)
+		return r, r.raw.Unmarshal(r.value.Interface())
+	})
+	if err != nil {
+		return err
+	}
+	r := res.(reply)
+	rv.Elem().Set(r.value.Elem())
+	if m.onReply != nil {
+		m.onReply(r.raw)
+	}
+	return nil
+}
+
+// withReply returns a connector using the same session that passes the raw
+// reply of the commands it runs to onReply
+func (m *DB) withReply(onReply func(bson.Raw)) MongoConnector {
+	db := *m
+	db.onReply = onReply
+	return &db
+}
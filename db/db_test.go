@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"labix.org/v2/mgo/bson"
+)
+
+func TestGetOplogCollection(t *testing.T) {
+	server := startFakeMongod(t, "", "")
+	defer server.Close()
+	// WiredTiger has no system.namespaces collection
+	server.collections = []bson.M{
+		{"name": "startup_log", "options": bson.M{"capped": true, "size": 10485760}},
+		{"name": "oplog.rs", "options": bson.M{"capped": true, "size": 1073741824, "autoIndexId": false}},
+	}
+
+	opts := DialOptions{ConnectTimeout: time.Second, CommandTimeout: time.Second}
+	conn := NewConnectorFactory(context.Background(), opts)(server.Addr())
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("cannot connect: %s", err)
+	}
+	defer conn.Close()
+
+	oplogCol, err := conn.GetOplogCollection()
+	if err != nil {
+		t.Fatalf("cannot get the oplog collection: %s", err)
+	}
+	if oplogCol != "oplog.rs" {
+		t.Errorf("invalid oplog collection %q", oplogCol)
+	}
+
+	entry, err := conn.GetOplogEntry(oplogCol)
+	if err != nil {
+		t.Fatalf("cannot get the oplog entry: %s", err)
+	}
+	if entry.Name != "oplog.rs" || !entry.Options.Capped || entry.Options.Size != 1073741824 {
+		t.Errorf("invalid oplog entry %+v", entry)
+	}
+
+	if _, err := conn.GetOplogEntry("oplog.$main"); err == nil {
+		t.Errorf("missing error for a collection not in local")
+	}
+}
+
+func TestGetOplogCollectionStandalone(t *testing.T) {
+	server := startFakeMongod(t, "", "")
+	defer server.Close()
+	server.collections = []bson.M{{"name": "startup_log", "options": bson.M{"capped": true}}}
+
+	opts := DialOptions{ConnectTimeout: time.Second, CommandTimeout: time.Second}
+	conn := NewConnectorFactory(context.Background(), opts)(server.Addr())
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("cannot connect: %s", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.GetOplogCollection(); err == nil {
+		t.Errorf("missing error without replication")
+	}
+}
+
+func TestGetOplogCollectionErrors(t *testing.T) {
+	server := startFakeMongod(t, "", "")
+	defer server.Close()
+	server.replies = map[string]bson.M{
+		"listCollections": {"ok": 0, "errmsg": "no such command: listCollections", "code": 59},
+		// The system.namespaces query, by the first field of its filter
+		"name": {"$err": "not authorized for query on local.system.namespaces"},
+	}
+
+	opts := DialOptions{ConnectTimeout: time.Second, CommandTimeout: time.Second}
+	conn := NewConnectorFactory(context.Background(), opts)(server.Addr())
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("cannot connect: %s", err)
+	}
+	defer conn.Close()
+
+	// Both errors are reported
+	_, err := conn.GetOplogCollection()
+	if err == nil || !strings.Contains(err.Error(), "not authorized for query on local.system.namespaces") ||
+		!strings.Contains(err.Error(), "no such command: listCollections") {
+		t.Errorf("invalid error: %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"labix.org/v2/mgo"
+)
+
+const (
+	DefaultDialTimeout    = 10 * time.Second
+	DefaultCommandTimeout = 1 * time.Minute
+)
+
+const (
+	AuthMechanismSCRAMSHA1   = "SCRAM-SHA-1"
+	AuthMechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	AuthMechanismCR          = "MONGODB-CR"
+	AuthMechanismX509        = "MONGODB-X509"
+	AuthMechanismPlain       = "PLAIN"
+	// DefaultAuthDB is the auth database used if none is given
+	DefaultAuthDB = "admin"
+	// ExternalAuthDB is the auth database for mechanisms where credentials
+	// are stored outside MongoDB (x509 certificates, LDAP)
+	ExternalAuthDB = "$external"
+)
+
+// AuthMechanisms are the authentication mechanisms accepted in DialOptions.
+// An empty mechanism negotiates SCRAM-SHA-256 or SCRAM-SHA-1 with the server.
+var AuthMechanisms = []string{
+	AuthMechanismSCRAMSHA1,
+	AuthMechanismSCRAMSHA256,
+	AuthMechanismCR,
+	AuthMechanismX509,
+	AuthMechanismPlain,
+}
+
+var readPreferenceModes = map[string]bool{
+	"primary":            true,
+	"primaryPreferred":   true,
+	"secondary":          true,
+	"secondaryPreferred": true,
+	"nearest":            true,
+}
+
+// DialOptions holds the settings applied to every session the tool opens,
+// including the ones opened for shards and replica set members found
+// during discovery.
+type DialOptions struct {
+	Username      string
+	Password      string
+	AuthDB        string
+	AuthMechanism string
+
+	// Direct pins the connection to the given host, without discovering
+	// the rest of the replica set, and reads from it even if it is a
+	// secondary.
+	Direct bool
+	// ReplicaSetName, if set, is checked against the set name reported by
+	// the server after connecting.
+	ReplicaSetName string
+	ReadPreference string
+	ConnectTimeout time.Duration
+	// CommandTimeout is the maximum time to wait for each command
+	CommandTimeout time.Duration
+	// AppName is sent to the servers in the client metadata of each
+	// connection
+	AppName string
+	// Proxy is the socks5://[user:password@]host:port URL of a SOCKS5 proxy
+	// used for all the connections
+	Proxy string
+
+	TLS                           bool
+	TLSCAFile                     string
+	TLSCertificateKeyFile         string
+	TLSCertificateKeyFilePassword string
+	TLSAllowInvalidHostnames      bool
+	TLSAllowInvalidCertificates   bool
+}
+
+func (o DialOptions) Validate() error {
+	if o.Direct && o.ReadPreference != "" && o.ReadPreference != "secondaryPreferred" {
+		return fmt.Errorf("direct connections must use the secondaryPreferred read preference")
+	}
+	if o.ReadPreference != "" {
+		if !readPreferenceModes[o.ReadPreference] {
+			return fmt.Errorf("invalid read preference %q", o.ReadPreference)
+		}
+	}
+	if !o.TLS && (o.TLSCAFile != "" || o.TLSCertificateKeyFile != "" ||
+		o.TLSAllowInvalidHostnames || o.TLSAllowInvalidCertificates) {
+		return fmt.Errorf("TLS options were given but TLS is not enabled")
+	}
+	if o.Proxy != "" {
+		if _, err := o.proxyURL(); err != nil {
+			return err
+		}
+	}
+	switch o.AuthMechanism {
+	case "":
+		return nil
+	case AuthMechanismX509:
+		if !o.TLS || o.TLSCertificateKeyFile == "" {
+			return fmt.Errorf("%s authentication needs TLS and a client certificate", AuthMechanismX509)
+		}
+	case AuthMechanismPlain:
+		if o.Username == "" || o.Password == "" {
+			return fmt.Errorf("%s authentication needs a username and a password", AuthMechanismPlain)
+		}
+	}
+	for _, m := range AuthMechanisms {
+		if o.AuthMechanism == m {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid authentication mechanism %q. Valid mechanisms are: %s",
+		o.AuthMechanism, strings.Join(AuthMechanisms, ", "))
+}
+
+// IsExternalAuth returns true if the auth mechanism authenticates against
+// the $external database
+func (o DialOptions) IsExternalAuth() bool {
+	return o.AuthMechanism == AuthMechanismX509 || o.AuthMechanism == AuthMechanismPlain
+}
+
+// ForDiscoveredHost returns the options to use for the shards and members
+// found during discovery. They share credentials and connection settings
+// with the seed but may belong to a different replica set.
+func (o DialOptions) ForDiscoveredHost() DialOptions {
+	o.ReplicaSetName = ""
+	return o
+}
+
+// DialInfo returns the mgo.DialInfo needed to connect to the given hosts
+// using these options. Connections are authenticated in DialServer, so the
+// credentials are not passed to mgo.
+func (o DialOptions) DialInfo(hosts ...string) (*mgo.DialInfo, error) {
+	di, _, err := o.dialInfo(hosts)
+	return di, err
+}
+
+// dialInfo is DialInfo also returning where the last connection error is
+// kept. mgo only reports that there are no reachable servers if it cannot
+// connect to any, hiding timeouts and authentication failures.
+func (o DialOptions) dialInfo(hosts []string) (*mgo.DialInfo, *lastError, error) {
+	dialErr := &lastError{}
+	di := &mgo.DialInfo{
+		Addrs:   hosts,
+		Direct:  o.Direct,
+		Timeout: DefaultDialTimeout,
+	}
+	if o.ConnectTimeout > 0 {
+		di.Timeout = o.ConnectTimeout
+	}
+	dial, err := o.netDialFunc(di.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if o.TLS {
+		cfg, err := o.TLSConfig()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "invalid TLS options")
+		}
+		dial = tlsDialFunc(cfg, dial, di.Timeout)
+		// The x509 user name is the subject of the client certificate
+		if o.AuthMechanism == AuthMechanismX509 && o.Username == "" {
+			if o.Username, err = certificateSubject(cfg); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	var names map[string]string
+	if o.Proxy != "" {
+		di.Addrs, names = proxyAddrs(hosts)
+	}
+	di.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+		host := addr.String()
+		if name, ok := names[host]; ok {
+			host = name
+		}
+		conn, err := dial(host)
+		if err != nil {
+			dialErr.set(err)
+			return nil, err
+		}
+		if err := handshake(conn, o, di.Timeout); err != nil {
+			conn.Close()
+			dialErr.set(err)
+			return nil, err
+		}
+		return conn, nil
+	}
+	return di, dialErr, nil
+}
+
+// lastError keeps the last error set by concurrent goroutines
+type lastError struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (e *lastError) set(err error) {
+	e.mu.Lock()
+	e.err = err
+	e.mu.Unlock()
+}
+
+func (e *lastError) get() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// tlsDialFunc returns a function that opens connections using dial and
+// starts TLS on them
+func tlsDialFunc(cfg *tls.Config, dial func(string) (net.Conn, error), timeout time.Duration) func(string) (net.Conn, error) {
+	return func(addr string) (net.Conn, error) {
+		rawConn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		connCfg := cfg
+		if cfg.ServerName == "" {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				host = addr
+			}
+			connCfg = cfg.Clone()
+			connCfg.ServerName = host
+		}
+		conn := tls.Client(rawConn, connCfg)
+		conn.SetDeadline(time.Now().Add(timeout))
+		if err := conn.Handshake(); err != nil {
+			rawConn.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		return conn, nil
+	}
+}
+
+// Dial opens a new session to host (a host:port or a comma separated list of
+// them) authenticating with the credentials in opts.
+func Dial(host string, opts DialOptions) (*mgo.Session, error) {
+	di, dialErr, err := opts.dialInfo(strings.Split(host, ","))
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	session, err := mgo.DialWithInfo(di)
+	if err != nil {
+		if err := dialErr.get(); err != nil {
+			return nil, err
+		}
+		if time.Since(start) >= di.Timeout {
+			return nil, errors.Wrap(context.DeadlineExceeded, "no reachable servers within the connect timeout")
+		}
+		return nil, err
+	}
+
+	if opts.Direct {
+		setReadPreference(session, "secondaryPreferred")
+	} else {
+		setReadPreference(session, opts.ReadPreference)
+	}
+	if opts.CommandTimeout > 0 {
+		session.SetSocketTimeout(opts.CommandTimeout)
+	}
+
+	if opts.ReplicaSetName != "" {
+		var md struct {
+			SetName string `bson:"setName"`
+		}
+		if err := session.Run("isMaster", &md); err != nil {
+			session.Close()
+			return nil, errors.Wrap(err, "cannot check replica set name")
+		}
+		if md.SetName != opts.ReplicaSetName {
+			session.Close()
+			return nil, fmt.Errorf("%s belongs to replica set %q instead of %q", host, md.SetName, opts.ReplicaSetName)
+		}
+	}
+
+	return session, nil
+}
+
+// setReadPreference sets the mgo consistency mode closest to the connection
+// string read preference.
+func setReadPreference(session *mgo.Session, readPreference string) {
+	switch readPreference {
+	case "primary":
+		session.SetMode(mgo.Strong, true)
+	case "primaryPreferred", "secondary", "secondaryPreferred":
+		session.SetMode(mgo.Monotonic, true)
+	case "nearest":
+		session.SetMode(mgo.Eventual, true)
+	}
+}
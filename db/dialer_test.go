@@ -0,0 +1,100 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDialInfo(t *testing.T) {
+	opts := DialOptions{
+		Username:      "admin",
+		Password:      "secret",
+		AuthDB:        "users",
+		AuthMechanism: "SCRAM-SHA-256",
+	}
+
+	di, err := opts.DialInfo("localhost:17001", "localhost:17002")
+	if err != nil {
+		t.Fatalf("cannot get dial info: %s", err)
+	}
+	if !reflect.DeepEqual(di.Addrs, []string{"localhost:17001", "localhost:17002"}) {
+		t.Errorf("invalid addresses: %v", di.Addrs)
+	}
+	// The connections are authenticated by DialServer, not by mgo
+	if di.Username != "" || di.Password != "" || di.Mechanism != "" {
+		t.Errorf("credentials should not be passed to mgo: %+v", di)
+	}
+	if di.DialServer == nil {
+		t.Errorf("authenticated connections need a custom dialer")
+	}
+	if source := opts.authSource(); source != "users" {
+		t.Errorf("invalid auth source. got %q, expect 'users'", source)
+	}
+	opts.AuthDB = ""
+	if source := opts.authSource(); source != DefaultAuthDB {
+		t.Errorf("invalid default auth source. got %q, expect %q", source, DefaultAuthDB)
+	}
+}
+
+func TestValidateAuthMechanism(t *testing.T) {
+	tests := []struct {
+		mechanism string
+		wantErr   bool
+	}{
+		{"", false},
+		{"SCRAM-SHA-1", false},
+		{"SCRAM-SHA-256", false},
+		{"MONGODB-CR", false},
+		{"scram-sha-1", true},
+		{"GSSAPI", true},
+		{AuthMechanismPlain, false},
+		{AuthMechanismX509, false},
+	}
+
+	for _, test := range tests {
+		opts := DialOptions{
+			AuthMechanism:         test.mechanism,
+			Username:              "user",
+			Password:              "pass",
+			TLS:                   true,
+			TLSCertificateKeyFile: "client.pem",
+		}
+		err := opts.Validate()
+		if (err != nil) != test.wantErr {
+			t.Errorf("mechanism %q: got error %v, want error: %v", test.mechanism, err, test.wantErr)
+		}
+	}
+}
+
+func TestValidateExternalAuth(t *testing.T) {
+	if err := (DialOptions{AuthMechanism: AuthMechanismX509}).Validate(); err == nil {
+		t.Errorf("x509 without a client certificate should fail")
+	}
+	if err := (DialOptions{AuthMechanism: AuthMechanismPlain, Username: "user"}).Validate(); err == nil {
+		t.Errorf("PLAIN without a password should fail")
+	}
+
+	opts := DialOptions{AuthMechanism: AuthMechanismPlain, Username: "user", Password: "pass"}
+	if source := opts.authSource(); source != ExternalAuthDB {
+		t.Errorf("invalid auth source for PLAIN. got %q, expect %q", source, ExternalAuthDB)
+	}
+}
+
+func TestDirectDialInfo(t *testing.T) {
+	opts := DialOptions{Direct: true}
+	if err := opts.Validate(); err != nil {
+		t.Errorf("direct connections should be valid: %s", err)
+	}
+	di, err := opts.DialInfo("localhost:17002")
+	if err != nil {
+		t.Fatalf("cannot get dial info: %s", err)
+	}
+	if !di.Direct {
+		t.Errorf("dial info should be direct")
+	}
+
+	opts.ReadPreference = "primary"
+	if err := opts.Validate(); err == nil {
+		t.Errorf("direct connections reading from the primary should fail")
+	}
+}
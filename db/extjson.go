@@ -0,0 +1,294 @@
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"labix.org/v2/mgo/bson"
+)
+
+// The replies of the servers are saved in the bundles as relaxed MongoDB
+// Extended JSON, keeping the order of the fields and the BSON types, like
+// {"$oid": "..."} for an ObjectId or {"$numberLong": "..."} for an int64, so
+// they are decoded on replay like the replies of a server.
+// See https://github.com/mongodb/specifications/blob/master/source/extended-json.rst
+
+const extJSONDate = "2006-01-02T15:04:05.000Z07:00"
+
+// marshalExtJSON returns the BSON document raw as indented Extended JSON
+func marshalExtJSON(raw bson.Raw) ([]byte, error) {
+	var doc bson.D
+	if err := raw.Unmarshal(&doc); err != nil {
+		return nil, errors.Wrap(err, "invalid reply")
+	}
+	buf := &bytes.Buffer{}
+	if err := writeExtJSON(buf, doc); err != nil {
+		return nil, err
+	}
+	indented := &bytes.Buffer{}
+	if err := json.Indent(indented, buf.Bytes(), "", "    "); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+func writeExtJSON(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case bson.D:
+		buf.WriteByte('{')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, elem.Name)
+			buf.WriteByte(':')
+			if err := writeExtJSON(buf, elem.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case bson.M:
+		// The scope of JavaScript code
+		doc := bson.D{}
+		for name, value := range v {
+			doc = append(doc, bson.DocElem{Name: name, Value: value})
+		}
+		return writeExtJSON(buf, doc)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, value := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeExtJSON(buf, value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case string:
+		writeJSONString(buf, v)
+	case int:
+		buf.WriteString(strconv.Itoa(v))
+	case int64:
+		return writeExtJSON(buf, bson.D{{"$numberLong", strconv.FormatInt(v, 10)}})
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return writeExtJSON(buf, bson.D{{"$numberDouble", strconv.FormatFloat(v, 'g', -1, 64)}})
+		}
+		// The decimal point tells a double from an int32
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		buf.WriteString(s)
+	case time.Time:
+		if v.IsZero() || v.Year() < 1970 || v.Year() > 9999 {
+			ms := v.Unix()*1000 + int64(v.Nanosecond()/1e6)
+			return writeExtJSON(buf, bson.D{{"$date", bson.D{{"$numberLong", strconv.FormatInt(ms, 10)}}}})
+		}
+		return writeExtJSON(buf, bson.D{{"$date", v.UTC().Format(extJSONDate)}})
+	case bson.ObjectId:
+		return writeExtJSON(buf, bson.D{{"$oid", v.Hex()}})
+	case bson.MongoTimestamp:
+		return writeExtJSON(buf, bson.D{{"$timestamp", bson.D{{"t", int(uint64(v) >> 32)}, {"i", int(uint32(v))}}}})
+	case []byte:
+		return writeExtJSON(buf, bson.Binary{Kind: 0x00, Data: v})
+	case bson.Binary:
+		return writeExtJSON(buf, bson.D{{"$binary", bson.D{
+			{"base64", base64.StdEncoding.EncodeToString(v.Data)},
+			{"subType", fmt.Sprintf("%02x", v.Kind)},
+		}}})
+	case bson.RegEx:
+		return writeExtJSON(buf, bson.D{{"$regularExpression", bson.D{{"pattern", v.Pattern}, {"options", v.Options}}}})
+	case bson.JavaScript:
+		if v.Scope == nil {
+			return writeExtJSON(buf, bson.D{{"$code", v.Code}})
+		}
+		return writeExtJSON(buf, bson.D{{"$code", v.Code}, {"$scope", v.Scope}})
+	case bson.Symbol:
+		return writeExtJSON(buf, bson.D{{"$symbol", string(v)}})
+	default:
+		switch v {
+		case bson.MinKey:
+			return writeExtJSON(buf, bson.D{{"$minKey", 1}})
+		case bson.MaxKey:
+			return writeExtJSON(buf, bson.D{{"$maxKey", 1}})
+		case bson.Undefined:
+			return writeExtJSON(buf, bson.D{{"$undefined", true}})
+		}
+		return fmt.Errorf("cannot save a %T in Extended JSON", v)
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	buf.Write(b)
+}
+
+// unmarshalExtJSON decodes a document saved by marshalExtJSON into result
+// the same way a reply of a server is decoded
+func unmarshalExtJSON(buf []byte, result interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	v, err := readExtJSON(dec)
+	if err != nil {
+		return err
+	}
+	doc, ok := v.(bson.D)
+	if !ok {
+		return fmt.Errorf("the reply is not a document")
+	}
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, result)
+}
+
+func readExtJSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			array := []interface{}{}
+			for dec.More() {
+				value, err := readExtJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, value)
+			}
+			_, err := dec.Token()
+			return array, err
+		}
+		doc := bson.D{}
+		for dec.More() {
+			name, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readExtJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, bson.DocElem{Name: name.(string), Value: value})
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return extJSONValue(doc)
+	case json.Number:
+		if strings.ContainsAny(tok.String(), ".eE") {
+			return tok.Float64()
+		}
+		n, err := tok.Int64()
+		if err != nil {
+			return nil, err
+		}
+		if n != int64(int32(n)) {
+			return n, nil
+		}
+		return int(n), nil
+	default:
+		// string, bool or nil
+		return tok, nil
+	}
+}
+
+// extJSONValue returns the BSON value of doc if it is one of the Extended
+// JSON types like {"$oid": "..."}, or doc otherwise
+func extJSONValue(doc bson.D) (interface{}, error) {
+	if len(doc) == 2 && doc[0].Name == "$code" && doc[1].Name == "$scope" {
+		code, _ := doc[0].Value.(string)
+		scope, ok := doc[1].Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("invalid $scope")
+		}
+		return bson.JavaScript{Code: code, Scope: scope.Map()}, nil
+	}
+	if len(doc) != 1 {
+		return doc, nil
+	}
+	value := doc[0].Value
+	s, _ := value.(string)
+	fields, _ := value.(bson.D)
+	switch doc[0].Name {
+	case "$oid":
+		if !bson.IsObjectIdHex(s) {
+			return nil, fmt.Errorf("invalid $oid %q", s)
+		}
+		return bson.ObjectIdHex(s), nil
+	case "$date":
+		if ms, ok := value.(int64); ok {
+			return time.Unix(ms/1e3, ms%1e3*1e6), nil
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	case "$timestamp":
+		m := fields.Map()
+		t, tok := extJSONInt(m["t"])
+		i, iok := extJSONInt(m["i"])
+		if !tok || !iok {
+			return nil, fmt.Errorf("invalid $timestamp")
+		}
+		return bson.MongoTimestamp(t<<32 | i), nil
+	case "$numberLong":
+		return strconv.ParseInt(s, 10, 64)
+	case "$numberDouble":
+		return strconv.ParseFloat(s, 64)
+	case "$binary":
+		m := fields.Map()
+		data, err := base64.StdEncoding.DecodeString(fmt.Sprint(m["base64"]))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid $binary")
+		}
+		kind, err := strconv.ParseUint(fmt.Sprint(m["subType"]), 16, 8)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid $binary subtype")
+		}
+		if kind == 0x00 {
+			return data, nil
+		}
+		return bson.Binary{Kind: byte(kind), Data: data}, nil
+	case "$regularExpression":
+		m := fields.Map()
+		pattern, _ := m["pattern"].(string)
+		options, _ := m["options"].(string)
+		return bson.RegEx{Pattern: pattern, Options: options}, nil
+	case "$code":
+		return bson.JavaScript{Code: s}, nil
+	case "$symbol":
+		return bson.Symbol(s), nil
+	case "$minKey":
+		return bson.MinKey, nil
+	case "$maxKey":
+		return bson.MaxKey, nil
+	case "$undefined":
+		return bson.Undefined, nil
+	}
+	return doc, nil
+}
+
+func extJSONInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
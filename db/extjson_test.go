@@ -0,0 +1,81 @@
+package db
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"labix.org/v2/mgo/bson"
+)
+
+func TestExtJSON(t *testing.T) {
+	doc := bson.D{
+		{"ok", 1.0},
+		{"int32", 42},
+		{"int64", int64(1) << 40},
+		{"double", 0.25},
+		{"inf", math.Inf(-1)},
+		{"string", "a \"quoted\" string"},
+		{"bool", true},
+		{"null", nil},
+		{"oid", bson.ObjectIdHex("58da66e5f1a8ff2d4a29ca8d")},
+		{"date", time.Date(2017, 3, 28, 13, 35, 56, 120e6, time.UTC)},
+		{"zeroDate", time.Time{}},
+		{"ts", bson.MongoTimestamp(1490708156<<32 | 3)},
+		{"binary", []byte("secret")},
+		{"uuid", bson.Binary{Kind: 0x04, Data: []byte("0123456789abcdef")}},
+		{"regex", bson.RegEx{Pattern: "^rs", Options: "i"}},
+		{"code", bson.JavaScript{Code: "function() {}"}},
+		{"min", bson.MinKey},
+		{"array", []interface{}{1, "two", bson.D{{"three", 3.0}}}},
+		{"nested", bson.D{{"$gleStats", bson.D{{"lastOpTime", bson.MongoTimestamp(0)}}}}},
+	}
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := marshalExtJSON(bson.Raw{Kind: 0x03, Data: data})
+	if err != nil {
+		t.Fatalf("cannot marshal: %s", err)
+	}
+	for _, expect := range []string{
+		`"ok": 1.0`,
+		`"int32": 42`,
+		`"int64": {
+        "$numberLong": "1099511627776"
+    }`,
+		`"$oid": "58da66e5f1a8ff2d4a29ca8d"`,
+		`"$date": "2017-03-28T13:35:56.120Z"`,
+		`"t": 1490708156`,
+		`"subType": "04"`,
+	} {
+		if !strings.Contains(string(buf), expect) {
+			t.Errorf("%s not found in:\n%s", expect, buf)
+		}
+	}
+	if strings.Index(string(buf), `"ok"`) > strings.Index(string(buf), `"int32"`) {
+		t.Errorf("the order of the fields must be kept:\n%s", buf)
+	}
+
+	var decoded bson.D
+	if err := unmarshalExtJSON(buf, &decoded); err != nil {
+		t.Fatalf("cannot unmarshal: %s", err)
+	}
+	redata, err := bson.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(redata, data) {
+		t.Errorf("the document changed:\ngot:    %v\nexpect: %v", decoded, doc)
+	}
+
+	if err := unmarshalExtJSON([]byte(`{"oid": {"$oid": "xyz"}}`), &decoded); err == nil {
+		t.Errorf("missing error for an invalid $oid")
+	}
+	if err := unmarshalExtJSON([]byte(`[1, 2]`), &decoded); err == nil {
+		t.Errorf("missing error for a reply that is not a document")
+	}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"net"
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+	"labix.org/v2/mgo/bson"
+)
+
+// isMasterReply has the isMaster fields used by the handshake
+type isMasterReply struct {
+	MaxWireVersion     int      `bson:"maxWireVersion"`
+	SASLSupportedMechs []string `bson:"saslSupportedMechs"`
+}
+
+// handshake prepares conn before handing it to mgo. It sends the client
+// metadata if there is an application name, so it shows up in the server
+// logs and in currentOp, and authenticates the connection. It gives up if
+// the server doesn't answer within timeout.
+func handshake(conn net.Conn, opts DialOptions, timeout time.Duration) error {
+	if opts.AppName == "" && !opts.needsAuth() {
+		return nil
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.SetDeadline(time.Time{})
+
+	var im isMasterReply
+	if opts.AppName != "" || opts.AuthMechanism == "" {
+		if err := runCommand(conn, "admin", opts.isMasterCommand(), &im); err != nil {
+			return errors.Wrap(err, "cannot run isMaster")
+		}
+	}
+	if !opts.needsAuth() {
+		return nil
+	}
+	return authenticate(conn, opts, im)
+}
+
+// isMasterCommand returns the first command sent on each connection. The
+// server only accepts the client metadata in the first isMaster.
+func (o DialOptions) isMasterCommand() bson.D {
+	cmd := bson.D{{"isMaster", 1}}
+	if o.AppName != "" {
+		cmd = append(cmd, bson.DocElem{"client", bson.M{
+			"application": bson.M{"name": o.AppName},
+			"driver":      bson.M{"name": "mgo", "version": "v2"},
+			"os":          bson.M{"type": runtime.GOOS, "architecture": runtime.GOARCH},
+		}})
+	}
+	if o.needsAuth() && o.AuthMechanism == "" {
+		cmd = append(cmd, bson.DocElem{"saslSupportedMechs", o.authSource() + "." + o.Username})
+	}
+	return cmd
+}
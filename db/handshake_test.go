@@ -0,0 +1,54 @@
+package db
+
+import (
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"labix.org/v2/mgo/bson"
+)
+
+func TestHandshakeAppName(t *testing.T) {
+	server := startFakeMongod(t, "", "")
+	defer server.Close()
+
+	session, err := Dial(server.Addr(), DialOptions{AppName: "summary", ConnectTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("cannot connect: %s", err)
+	}
+	session.Close()
+
+	// Every connection opened by mgo sends the client metadata
+	clients := server.Clients()
+	if len(clients) == 0 {
+		t.Fatalf("the client metadata was not sent")
+	}
+	for _, client := range clients {
+		application, _ := client["application"].(bson.M)
+		if application["name"] != "summary" {
+			t.Errorf("invalid application name in %v", client)
+		}
+		os, _ := client["os"].(bson.M)
+		if os["type"] != runtime.GOOS {
+			t.Errorf("invalid os type in %v", client)
+		}
+	}
+}
+
+func TestHandshakeWithoutAppName(t *testing.T) {
+	server := startFakeMongod(t, "", "")
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := handshake(conn, DialOptions{}, time.Second); err != nil {
+		t.Errorf("handshake without credentials nor application name failed: %s", err)
+	}
+	if clients := server.Clients(); len(clients) != 0 {
+		t.Errorf("no client metadata should be sent without an application name: %v", clients)
+	}
+}
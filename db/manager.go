@@ -0,0 +1,214 @@
+package db
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+	"labix.org/v2/mgo"
+)
+
+// ConnectionManager hands out one connected MongoConnector per host so
+// discovery and collection share the same connections instead of dialing
+// the same hosts again. All connections are closed by Close.
+type ConnectionManager struct {
+	lock      sync.Mutex
+	factory   ConnectorFactory
+	conns     map[string]MongoConnector
+	errs      map[string]error
+	dialed    map[string]bool
+	dialCount int
+	failures  int
+}
+
+func NewConnectionManager(factory ConnectorFactory) *ConnectionManager {
+	return &ConnectionManager{
+		factory: factory,
+		conns:   make(map[string]MongoConnector),
+		errs:    make(map[string]error),
+		dialed:  make(map[string]bool),
+	}
+}
+
+// Get returns a connector for host, connecting to it on first use. If the
+// first connection failed, the same error is returned without dialing again.
+// The commands run with the connector that fail are counted in Failures.
+func (cm *ConnectionManager) Get(host string) (MongoConnector, error) {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	if conn, ok := cm.conns[host]; ok {
+		return conn, nil
+	}
+	if err, ok := cm.errs[host]; ok {
+		return nil, err
+	}
+
+	cm.dialCount++
+	cm.dialed[host] = true
+	conn := cm.factory(host)
+	if err := conn.Connect(); err != nil {
+		cm.failures++
+		cm.errs[host] = err
+		return nil, err
+	}
+	cm.conns[host] = &countingConnector{conn: conn, cm: cm}
+	return cm.conns[host], nil
+}
+
+// Failures returns how many connections and commands failed
+func (cm *ConnectionManager) Failures() int {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+	return cm.failures
+}
+
+func (cm *ConnectionManager) countFailure(err error) error {
+	if err != nil {
+		cm.lock.Lock()
+		cm.failures++
+		cm.lock.Unlock()
+	}
+	return err
+}
+
+// Dials returns how many times the manager dialed a host
+func (cm *ConnectionManager) Dials() int {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+	return cm.dialCount
+}
+
+// Hosts returns the hosts the manager tried to connect to, even after Close
+func (cm *ConnectionManager) Hosts() []string {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	hosts := make([]string, 0, len(cm.dialed))
+	for host := range cm.dialed {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Close closes all the connections
+func (cm *ConnectionManager) Close() {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	for host, conn := range cm.conns {
+		conn.Close()
+		delete(cm.conns, host)
+	}
+}
+
+// countingConnector runs the commands on conn counting the failed ones in cm
+type countingConnector struct {
+	conn MongoConnector
+	cm   *ConnectionManager
+}
+
+func (c *countingConnector) BuildInfo() (mgo.BuildInfo, error) {
+	bi, err := c.conn.BuildInfo()
+	return bi, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) Close() {
+	c.conn.Close()
+}
+
+func (c *countingConnector) CollectionNames(dbname string) ([]string, error) {
+	names, err := c.conn.CollectionNames(dbname)
+	return names, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) Connect() error {
+	return c.cm.countFailure(c.conn.Connect())
+}
+
+func (c *countingConnector) DatabaseNames() ([]string, error) {
+	names, err := c.conn.DatabaseNames()
+	return names, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) DbRun(dbname string, cmd interface{}, result interface{}) error {
+	return c.cm.countFailure(c.conn.DbRun(dbname, cmd, result))
+}
+
+func (c *countingConnector) FindOne(dbname string, collection string, query interface{}, sort []string, result interface{}) error {
+	return c.cm.countFailure(c.conn.FindOne(dbname, collection, query, sort, result))
+}
+
+func (c *countingConnector) GetCmdLineOpts() (proto.CommandLineOptions, error) {
+	clo, err := c.conn.GetCmdLineOpts()
+	return clo, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) GetCurrentOp() (proto.CurrentOp, error) {
+	co, err := c.conn.GetCurrentOp()
+	return co, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) GetOplogCollection() (string, error) {
+	col, err := c.conn.GetOplogCollection()
+	return col, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) GetOplogEntry(oplogCol string) (*OplogEntry, error) {
+	entry, err := c.conn.GetOplogEntry(oplogCol)
+	return entry, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) HostInfo() (proto.HostInfo, error) {
+	hi, err := c.conn.HostInfo()
+	return hi, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) IsMaster() (proto.MasterDoc, error) {
+	md, err := c.conn.IsMaster()
+	return md, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) ListShards() (*proto.ShardsInfo, error) {
+	ls, err := c.conn.ListShards()
+	return ls, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) ReplicaSetGetStatus() (proto.ReplicaSetStatus, error) {
+	rss, err := c.conn.ReplicaSetGetStatus()
+	return rss, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) RolesCount() (int, error) {
+	n, err := c.conn.RolesCount()
+	return n, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) ServerStatus() (proto.ServerStatus, error) {
+	ss, err := c.conn.ServerStatus()
+	return ss, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) Session() *mgo.Session {
+	return c.conn.Session()
+}
+
+func (c *countingConnector) SessionRun(cmd interface{}, result interface{}) error {
+	return c.cm.countFailure(c.conn.SessionRun(cmd, result))
+}
+
+func (c *countingConnector) UsersCount() (int, error) {
+	n, err := c.conn.UsersCount()
+	return n, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) ConnectionPoolStats() (interface{}, error) {
+	stats, err := c.conn.ConnectionPoolStats()
+	return stats, c.cm.countFailure(err)
+}
+
+func (c *countingConnector) ShardConnectionPoolStats() (interface{}, error) {
+	stats, err := c.conn.ShardConnectionPoolStats()
+	return stats, c.cm.countFailure(err)
+}
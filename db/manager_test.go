@@ -0,0 +1,109 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+// fakeConnector only implements Connect, Close and ServerStatus. Calling any
+// other method panics.
+type fakeConnector struct {
+	MongoConnector
+	host   string
+	closed bool
+	err    error
+	// statusErr is returned by ServerStatus
+	statusErr error
+}
+
+func (f *fakeConnector) Connect() error {
+	return f.err
+}
+
+func (f *fakeConnector) Close() {
+	f.closed = true
+}
+
+func (f *fakeConnector) ServerStatus() (proto.ServerStatus, error) {
+	return proto.ServerStatus{}, f.statusErr
+}
+
+func TestConnectionManager(t *testing.T) {
+	var created []*fakeConnector
+	factory := func(host string) MongoConnector {
+		conn := &fakeConnector{host: host}
+		if host == "down:27017" {
+			conn.err = fmt.Errorf("no reachable servers")
+		}
+		created = append(created, conn)
+		return conn
+	}
+
+	cm := NewConnectionManager(factory)
+	for _, host := range []string{"h1:27017", "h2:27017", "h1:27017", "down:27017", "h2:27017", "down:27017"} {
+		conn, err := cm.Get(host)
+		if host == "down:27017" {
+			if err == nil {
+				t.Errorf("expected connection error for %s", host)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("cannot get a connector for %s: %s", host, err)
+			continue
+		}
+		if got := conn.(*countingConnector).conn.(*fakeConnector).host; got != host {
+			t.Errorf("got a connector for %s, expect %s", got, host)
+		}
+	}
+
+	if cm.Dials() != 3 {
+		t.Errorf("each host should be dialed only once. got %d dials, expect 3", cm.Dials())
+	}
+	expectHosts := []string{"down:27017", "h1:27017", "h2:27017"}
+	if !reflect.DeepEqual(cm.Hosts(), expectHosts) {
+		t.Errorf("invalid hosts list. got %v, expect %v", cm.Hosts(), expectHosts)
+	}
+
+	cm.Close()
+	for _, conn := range created {
+		if conn.err == nil && !conn.closed {
+			t.Errorf("connection to %s was not closed", conn.host)
+		}
+	}
+	if !reflect.DeepEqual(cm.Hosts(), expectHosts) {
+		t.Errorf("closing must not change the hosts list. got %v, expect %v", cm.Hosts(), expectHosts)
+	}
+}
+
+func TestConnectionManagerFailures(t *testing.T) {
+	factory := func(host string) MongoConnector {
+		conn := &fakeConnector{host: host}
+		switch host {
+		case "down:27017":
+			conn.err = fmt.Errorf("no reachable servers")
+		case "h2:27017":
+			conn.statusErr = fmt.Errorf("not authorized")
+		}
+		return conn
+	}
+
+	cm := NewConnectionManager(factory)
+	defer cm.Close()
+	for _, host := range []string{"h1:27017", "h2:27017", "down:27017", "h2:27017", "down:27017"} {
+		conn, err := cm.Get(host)
+		if err != nil {
+			continue
+		}
+		conn.ServerStatus()
+	}
+
+	// The connection to down is attempted once, and the server status of
+	// h2 fails twice
+	if cm.Failures() != 3 {
+		t.Errorf("invalid failures count. got %d, expect 3", cm.Failures())
+	}
+}
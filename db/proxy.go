@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/proxy"
+)
+
+// ProxySchemes are the proxy URL schemes accepted in DialOptions.Proxy. Both
+// send the host names to the proxy, which resolves them.
+var ProxySchemes = []string{"socks5", "socks5h"}
+
+// proxyURL parses and checks the proxy in opts
+func (o DialOptions) proxyURL() (*url.URL, error) {
+	u, err := url.Parse(o.Proxy)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid proxy URL")
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q. It must be socks5://host:port", o.Proxy)
+	}
+	for _, scheme := range ProxySchemes {
+		if u.Scheme == scheme {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q. Only socks5 and socks5h are supported", u.Scheme)
+}
+
+// proxyAddrs returns the addresses given to mgo for hosts when connecting
+// through a proxy, and the hosts they stand for. mgo resolves every address
+// before dialing, which fails for the names only known behind the proxy, so
+// it gets placeholder IP addresses and the real names are dialed through the
+// proxy.
+func proxyAddrs(hosts []string) ([]string, map[string]string) {
+	addrs := make([]string, len(hosts))
+	names := make(map[string]string, len(hosts))
+	for i, host := range hosts {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), "27017")
+		}
+		// 100::/64 is a discard-only prefix, no server has these addresses
+		addrs[i] = net.JoinHostPort(fmt.Sprintf("100::%x", i+1), "27017")
+		names[addrs[i]] = host
+	}
+	return addrs, names
+}
+
+// netDialFunc returns the function used to open the TCP connections to the
+// servers. If there is a proxy, connections go through it.
+func (o DialOptions) netDialFunc(timeout time.Duration) (func(string) (net.Conn, error), error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if o.Proxy == "" {
+		return func(addr string) (net.Conn, error) {
+			return dialer.Dial("tcp", addr)
+		}, nil
+	}
+
+	u, err := o.proxyURL()
+	if err != nil {
+		return nil, err
+	}
+	pd, err := proxy.FromURL(u, dialer)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create the proxy dialer")
+	}
+	cd, ok := pd.(proxy.ContextDialer)
+	if !ok {
+		return nil, fmt.Errorf("the %s proxy dialer doesn't support timeouts", u.Scheme)
+	}
+	return func(addr string) (net.Conn, error) {
+		// The timeout covers the SOCKS handshake too
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		conn, err := cd.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot connect to %s through proxy %s", addr, u.Host)
+		}
+		return conn, nil
+	}, nil
+}
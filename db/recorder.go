@@ -0,0 +1,464 @@
+package db
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+	"github.com/pkg/errors"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+)
+
+// BundleFormatVersion is the version of the bundle layout and manifest.
+// Version 2 saves the replies of the servers instead of the decoded values.
+const BundleFormatVersion = 2
+
+// ManifestFile is the name of the manifest in a bundle
+const ManifestFile = "manifest.json"
+
+// BundleManifest lists every command run while collecting the reports, in
+// the order they were run
+type BundleManifest struct {
+	FormatVersion int `json:"formatVersion"`
+	// Run is the metadata of the run that created the bundle
+	Run     json.RawMessage `json:"run,omitempty"`
+	Entries []BundleEntry   `json:"entries"`
+}
+
+// BundleEntry is a command run on a host. File is the path, inside the
+// bundle, of the response as JSON. It is empty if the command failed or
+// returns nothing.
+type BundleEntry struct {
+	Host    string          `json:"host"`
+	Command string          `json:"command"`
+	Args    json.RawMessage `json:"args,omitempty"`
+	File    string          `json:"file,omitempty"`
+	// Reply tells File has the reply of the server as Extended JSON, not
+	// the value the tool got from it, like the count of UsersCount
+	Reply    bool          `json:"reply,omitempty"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"` // nanoseconds in JSON
+	Error    string        `json:"error,omitempty"`
+}
+
+// Anonymizer replaces the names of hosts, databases, collections and users
+// in a bundle
+type Anonymizer interface {
+	// String anonymizes a host or a message
+	String(s string) string
+	// JSON anonymizes the strings of a JSON document
+	JSON(buf []byte) ([]byte, error)
+}
+
+// Recorder keeps the response of every command run through its connectors,
+// to be saved as a diagnostic bundle
+type Recorder struct {
+	lock       sync.Mutex
+	entries    []BundleEntry
+	files      map[string][]byte
+	anonymizer Anonymizer
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{files: make(map[string][]byte)}
+}
+
+// Factory returns a ConnectorFactory whose connectors record the commands
+// run through the connectors of factory
+func (r *Recorder) Factory(factory ConnectorFactory) ConnectorFactory {
+	return func(host string) MongoConnector {
+		return &recordingConnector{conn: factory(host), host: host, rec: r}
+	}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// Anonymize makes the bundle be written anonymized by a, including the run
+// metadata and the names of the files of each host
+func (r *Recorder) Anonymize(a Anonymizer) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.anonymizer = a
+}
+
+// hostDir returns the directory of the responses of host in the bundle
+func hostDir(host string) string {
+	return unsafeFileChars.ReplaceAllString(host, "_")
+}
+
+// record adds a command to the manifest. args and response are saved as
+// JSON, and response as Extended JSON if it is the raw reply of the server.
+// The response is not saved if the command failed.
+func (r *Recorder) record(host, command string, args interface{}, start time.Time, response interface{}, err error) {
+	entry := BundleEntry{
+		Host:     host,
+		Command:  command,
+		Time:     start.UTC(),
+		Duration: time.Since(start),
+	}
+	if args != nil {
+		if buf, jerr := json.Marshal(args); jerr == nil {
+			entry.Args = buf
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err != nil {
+		entry.Error = err.Error()
+	} else if response != nil {
+		var buf []byte
+		var jerr error
+		if raw, ok := response.(bson.Raw); ok {
+			buf, jerr = marshalExtJSON(raw)
+			entry.Reply = true
+		} else {
+			buf, jerr = json.MarshalIndent(response, "", "    ")
+		}
+		if jerr != nil {
+			entry.Reply = false
+			entry.Error = "cannot save the response: " + jerr.Error()
+		} else {
+			entry.File = path.Join(hostDir(host), fmt.Sprintf("%04d-%s.json", len(r.entries)+1, command))
+			r.files[entry.File] = buf
+		}
+	}
+	r.entries = append(r.entries, entry)
+}
+
+// Manifest returns the manifest of the commands recorded so far. run is
+// saved as the metadata of the run and can be nil.
+func (r *Recorder) Manifest(run interface{}) (BundleManifest, error) {
+	m, _, err := r.snapshot(run)
+	return m, err
+}
+
+// snapshot returns the manifest of the commands recorded so far and their
+// responses by path, anonymized if Anonymize was called
+func (r *Recorder) snapshot(run interface{}) (BundleManifest, map[string][]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	m := BundleManifest{FormatVersion: BundleFormatVersion, Entries: append([]BundleEntry{}, r.entries...)}
+	if run != nil {
+		buf, err := json.Marshal(run)
+		if err != nil {
+			return BundleManifest{}, nil, errors.Wrap(err, "cannot save the run metadata")
+		}
+		m.Run = buf
+	}
+	files := make(map[string][]byte)
+	for _, entry := range m.Entries {
+		if entry.File != "" {
+			files[entry.File] = r.files[entry.File]
+		}
+	}
+	if r.anonymizer == nil {
+		return m, files, nil
+	}
+
+	a := r.anonymizer
+	var err error
+	if m.Run != nil {
+		if m.Run, err = a.JSON(m.Run); err != nil {
+			return BundleManifest{}, nil, errors.Wrap(err, "cannot anonymize the run metadata")
+		}
+	}
+	anonymized := make(map[string][]byte)
+	for i, entry := range m.Entries {
+		entry.Host = a.String(entry.Host)
+		entry.Error = a.String(entry.Error)
+		if entry.Args != nil {
+			if entry.Args, err = a.JSON(entry.Args); err != nil {
+				return BundleManifest{}, nil, errors.Wrapf(err, "cannot anonymize the arguments of %s", entry.Command)
+			}
+		}
+		if entry.File != "" {
+			file := path.Join(hostDir(entry.Host), path.Base(entry.File))
+			if anonymized[file], err = a.JSON(files[entry.File]); err != nil {
+				return BundleManifest{}, nil, errors.Wrapf(err, "cannot anonymize %s", entry.File)
+			}
+			entry.File = file
+		}
+		m.Entries[i] = entry
+	}
+	return m, anonymized, nil
+}
+
+// bundleFiles returns the manifest and the responses by path, and the paths
+// in the order they are written
+func (r *Recorder) bundleFiles(run interface{}) (map[string][]byte, []string, error) {
+	m, files, err := r.snapshot(run)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot write the manifest")
+	}
+
+	files[ManifestFile] = manifest
+	names := []string{ManifestFile}
+	for _, entry := range m.Entries {
+		if entry.File != "" {
+			names = append(names, entry.File)
+		}
+	}
+	return files, names, nil
+}
+
+// WriteDir saves the bundle in dir, creating it if it doesn't exist
+func (r *Recorder) WriteDir(dir string, run interface{}) error {
+	files, names, err := r.bundleFiles(run)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+			return errors.Wrap(err, "cannot create the bundle directory")
+		}
+		if err := ioutil.WriteFile(filename, files[name], 0600); err != nil {
+			return errors.Wrap(err, "cannot write the bundle")
+		}
+	}
+	return nil
+}
+
+// WriteTarGz writes the bundle as a gzipped tar archive
+func (r *Recorder) WriteTarGz(w io.Writer, run interface{}) error {
+	files, names, err := r.bundleFiles(run)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(files[name])), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return errors.Wrap(err, "cannot write the bundle")
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return errors.Wrap(err, "cannot write the bundle")
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "cannot write the bundle")
+	}
+	return gz.Close()
+}
+
+// replyConnector is implemented by the connectors that can give the raw
+// replies of the servers, like DB
+type replyConnector interface {
+	withReply(onReply func(bson.Raw)) MongoConnector
+}
+
+// recordingConnector runs the commands on conn and records them in rec
+type recordingConnector struct {
+	conn MongoConnector
+	host string
+	rec  *Recorder
+}
+
+// replyConn returns a connector running the commands on c.conn and a function
+// returning the raw reply of the last command run with it. The function
+// returns decoded if there is no raw reply, like with a bundle.
+func (c *recordingConnector) replyConn() (MongoConnector, func(decoded interface{}) interface{}) {
+	rc, ok := c.conn.(replyConnector)
+	if !ok {
+		return c.conn, func(decoded interface{}) interface{} { return decoded }
+	}
+	var reply *bson.Raw
+	conn := rc.withReply(func(raw bson.Raw) { reply = &raw })
+	return conn, func(decoded interface{}) interface{} {
+		if reply == nil {
+			return decoded
+		}
+		return *reply
+	}
+}
+
+func (c *recordingConnector) BuildInfo() (mgo.BuildInfo, error) {
+	start := time.Now()
+	bi, err := c.conn.BuildInfo()
+	c.rec.record(c.host, "BuildInfo", nil, start, bi, err)
+	return bi, err
+}
+
+func (c *recordingConnector) Close() {
+	c.conn.Close()
+}
+
+func (c *recordingConnector) CollectionNames(dbname string) ([]string, error) {
+	start := time.Now()
+	names, err := c.conn.CollectionNames(dbname)
+	c.rec.record(c.host, "CollectionNames", map[string]interface{}{"db": dbname}, start, names, err)
+	return names, err
+}
+
+func (c *recordingConnector) Connect() error {
+	start := time.Now()
+	err := c.conn.Connect()
+	c.rec.record(c.host, "Connect", nil, start, nil, err)
+	return err
+}
+
+func (c *recordingConnector) DatabaseNames() ([]string, error) {
+	start := time.Now()
+	names, err := c.conn.DatabaseNames()
+	c.rec.record(c.host, "DatabaseNames", nil, start, names, err)
+	return names, err
+}
+
+func (c *recordingConnector) DbRun(dbname string, cmd interface{}, result interface{}) error {
+	start := time.Now()
+	conn, reply := c.replyConn()
+	err := conn.DbRun(dbname, cmd, result)
+	c.rec.record(c.host, "DbRun", map[string]interface{}{"db": dbname, "cmd": cmd}, start, reply(result), err)
+	return err
+}
+
+func (c *recordingConnector) FindOne(dbname string, collection string, query interface{}, sort []string, result interface{}) error {
+	start := time.Now()
+	conn, reply := c.replyConn()
+	err := conn.FindOne(dbname, collection, query, sort, result)
+	args := map[string]interface{}{"db": dbname, "collection": collection, "query": query, "sort": sort}
+	c.rec.record(c.host, "FindOne", args, start, reply(result), err)
+	return err
+}
+
+func (c *recordingConnector) GetCmdLineOpts() (proto.CommandLineOptions, error) {
+	start := time.Now()
+	conn, reply := c.replyConn()
+	clo, err := conn.GetCmdLineOpts()
+	response := reply(clo)
+	if raw, ok := response.(bson.Raw); ok {
+		// The reply has the secrets redacted in clo
+		response = clo
+		if redacted, rerr := redactCmdLineReply(raw); rerr == nil {
+			response = redacted
+		}
+	}
+	c.rec.record(c.host, "GetCmdLineOpts", nil, start, response, err)
+	return clo, err
+}
+
+func (c *recordingConnector) GetCurrentOp() (proto.CurrentOp, error) {
+	start := time.Now()
+	conn, reply := c.replyConn()
+	co, err := conn.GetCurrentOp()
+	c.rec.record(c.host, "GetCurrentOp", nil, start, reply(co), err)
+	return co, err
+}
+
+func (c *recordingConnector) GetOplogCollection() (string, error) {
+	start := time.Now()
+	name, err := c.conn.GetOplogCollection()
+	c.rec.record(c.host, "GetOplogCollection", nil, start, name, err)
+	return name, err
+}
+
+func (c *recordingConnector) GetOplogEntry(oplogCol string) (*OplogEntry, error) {
+	start := time.Now()
+	entry, err := c.conn.GetOplogEntry(oplogCol)
+	c.rec.record(c.host, "GetOplogEntry", map[string]interface{}{"collection": oplogCol}, start, entry, err)
+	return entry, err
+}
+
+func (c *recordingConnector) HostInfo() (proto.HostInfo, error) {
+	start := time.Now()
+	conn, reply := c.replyConn()
+	hi, err := conn.HostInfo()
+	c.rec.record(c.host, "HostInfo", nil, start, reply(hi), err)
+	return hi, err
+}
+
+func (c *recordingConnector) IsMaster() (proto.MasterDoc, error) {
+	start := time.Now()
+	conn, reply := c.replyConn()
+	md, err := conn.IsMaster()
+	c.rec.record(c.host, "IsMaster", nil, start, reply(md), err)
+	return md, err
+}
+
+func (c *recordingConnector) ListShards() (*proto.ShardsInfo, error) {
+	start := time.Now()
+	conn, reply := c.replyConn()
+	si, err := conn.ListShards()
+	c.rec.record(c.host, "ListShards", nil, start, reply(si), err)
+	return si, err
+}
+
+func (c *recordingConnector) ReplicaSetGetStatus() (proto.ReplicaSetStatus, error) {
+	start := time.Now()
+	conn, reply := c.replyConn()
+	rss, err := conn.ReplicaSetGetStatus()
+	c.rec.record(c.host, "ReplicaSetGetStatus", nil, start, reply(rss), err)
+	return rss, err
+}
+
+func (c *recordingConnector) RolesCount() (int, error) {
+	start := time.Now()
+	n, err := c.conn.RolesCount()
+	c.rec.record(c.host, "RolesCount", nil, start, n, err)
+	return n, err
+}
+
+func (c *recordingConnector) ServerStatus() (proto.ServerStatus, error) {
+	start := time.Now()
+	conn, reply := c.replyConn()
+	ss, err := conn.ServerStatus()
+	c.rec.record(c.host, "ServerStatus", nil, start, reply(ss), err)
+	return ss, err
+}
+
+// Session gives direct access to the server so whatever is run through the
+// session is not recorded
+func (c *recordingConnector) Session() *mgo.Session {
+	return c.conn.Session()
+}
+
+func (c *recordingConnector) SessionRun(cmd interface{}, result interface{}) error {
+	start := time.Now()
+	conn, reply := c.replyConn()
+	err := conn.SessionRun(cmd, result)
+	c.rec.record(c.host, "SessionRun", map[string]interface{}{"cmd": cmd}, start, reply(result), err)
+	return err
+}
+
+func (c *recordingConnector) UsersCount() (int, error) {
+	start := time.Now()
+	n, err := c.conn.UsersCount()
+	c.rec.record(c.host, "UsersCount", nil, start, n, err)
+	return n, err
+}
+
+func (c *recordingConnector) ConnectionPoolStats() (interface{}, error) {
+	start := time.Now()
+	conn, reply := c.replyConn()
+	stats, err := conn.ConnectionPoolStats()
+	c.rec.record(c.host, "ConnectionPoolStats", nil, start, reply(stats), err)
+	return stats, err
+}
+
+func (c *recordingConnector) ShardConnectionPoolStats() (interface{}, error) {
+	start := time.Now()
+	conn, reply := c.replyConn()
+	stats, err := conn.ShardConnectionPoolStats()
+	c.rec.record(c.host, "ShardConnectionPoolStats", nil, start, reply(stats), err)
+	return stats, err
+}
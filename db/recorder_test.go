@@ -0,0 +1,242 @@
+package db
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+	"labix.org/v2/mgo/bson"
+)
+
+// statusServer answers serverStatus and fails every DbRun
+type statusServer struct {
+	fakeConnector
+}
+
+func (s *statusServer) ServerStatus() (proto.ServerStatus, error) {
+	return proto.ServerStatus{Host: s.host, Version: "3.4.1"}, nil
+}
+
+func (s *statusServer) DbRun(dbname string, cmd interface{}, result interface{}) error {
+	return fmt.Errorf("not authorized on %s", dbname)
+}
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder()
+	factory := rec.Factory(func(host string) MongoConnector {
+		return &statusServer{fakeConnector{host: host}}
+	})
+
+	conn := factory("db1:27017")
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if ss, err := conn.ServerStatus(); err != nil || ss.Version != "3.4.1" {
+		t.Errorf("the response must be returned unchanged: %v, %v", ss, err)
+	}
+	var stats bson.M
+	if err := conn.DbRun("local", bson.M{"collStats": "oplog.rs"}, &stats); err == nil {
+		t.Errorf("the error must be returned unchanged")
+	}
+
+	m, err := rec.Manifest(map[string]string{"toolVersion": "dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.FormatVersion != BundleFormatVersion || string(m.Run) != `{"toolVersion":"dev"}` {
+		t.Errorf("invalid manifest: %+v", m)
+	}
+	var commands []string
+	for _, e := range m.Entries {
+		commands = append(commands, e.Command)
+		if e.Host != "db1:27017" || e.Time.IsZero() {
+			t.Errorf("invalid entry: %+v", e)
+		}
+	}
+	if expect := []string{"Connect", "ServerStatus", "DbRun"}; !reflect.DeepEqual(commands, expect) {
+		t.Errorf("invalid commands. got %v, expect %v", commands, expect)
+	}
+	if m.Entries[1].File != "db1_27017/0002-ServerStatus.json" {
+		t.Errorf("invalid response file %q", m.Entries[1].File)
+	}
+	dbRun := m.Entries[2]
+	if dbRun.File != "" || dbRun.Error != "not authorized on local" || string(dbRun.Args) != `{"cmd":{"collStats":"oplog.rs"},"db":"local"}` {
+		t.Errorf("invalid failed command entry: %+v", dbRun)
+	}
+
+	dir, err := ioutil.TempDir("", "bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := rec.WriteDir(dir, nil); err != nil {
+		t.Fatalf("cannot write the bundle: %s", err)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "db1_27017", "0002-ServerStatus.json"))
+	if err != nil {
+		t.Fatalf("cannot read the response: %s", err)
+	}
+	var ss proto.ServerStatus
+	if err := json.Unmarshal(buf, &ss); err != nil || ss.Version != "3.4.1" {
+		t.Errorf("invalid response: %v, %v", ss, err)
+	}
+
+	archive := &bytes.Buffer{}
+	if err := rec.WriteTarGz(archive, nil); err != nil {
+		t.Fatalf("cannot write the bundle: %s", err)
+	}
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		t.Fatalf("invalid gzip: %s", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid tar: %s", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if expect := []string{ManifestFile, "db1_27017/0002-ServerStatus.json"}; !reflect.DeepEqual(names, expect) {
+		t.Errorf("invalid bundle files. got %v, expect %v", names, expect)
+	}
+}
+
+// replaceAnonymizer replaces db1 by host-1
+type replaceAnonymizer struct{}
+
+func (replaceAnonymizer) String(s string) string {
+	return strings.Replace(s, "db1", "host-1", -1)
+}
+
+func (a replaceAnonymizer) JSON(buf []byte) ([]byte, error) {
+	return []byte(a.String(string(buf))), nil
+}
+
+func TestRecorderAnonymize(t *testing.T) {
+	rec := NewRecorder()
+	conn := rec.Factory(func(host string) MongoConnector {
+		return &statusServer{fakeConnector{host: host}}
+	})("db1:27017")
+	conn.ServerStatus()
+	var stats bson.M
+	conn.DbRun("db1", bson.M{"dbStats": 1}, &stats)
+	rec.Anonymize(replaceAnonymizer{})
+
+	dir, err := ioutil.TempDir("", "bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := rec.WriteDir(dir, map[string]string{"seeds": "db1:27017"}); err != nil {
+		t.Fatalf("cannot write the bundle: %s", err)
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(buf), "db1") {
+		t.Errorf("the manifest must be anonymized:\n%s", buf)
+	}
+	var m BundleManifest
+	if err := json.Unmarshal(buf, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Entries[0].Host != "host-1:27017" || m.Entries[0].File != "host-1_27017/0001-ServerStatus.json" {
+		t.Errorf("invalid entry: %+v", m.Entries[0])
+	}
+	if m.Entries[1].Error != "not authorized on host-1" {
+		t.Errorf("the errors must be anonymized: %+v", m.Entries[1])
+	}
+	buf, err = ioutil.ReadFile(filepath.Join(dir, "host-1_27017", "0001-ServerStatus.json"))
+	if err != nil {
+		t.Fatalf("cannot read the response: %s", err)
+	}
+	if !strings.Contains(string(buf), `"host": "host-1:27017"`) {
+		t.Errorf("the response must be anonymized:\n%s", buf)
+	}
+}
+
+func TestRecorderReplies(t *testing.T) {
+	server := startFakeMongod(t, "", "")
+	defer server.Close()
+	server.replies = map[string]bson.M{
+		"getCmdLineOpts": {
+			"ok":   1,
+			"argv": []string{"mongod", "--sslPEMKeyPassword", "pemsecret", "--port", "27018"},
+			"parsed": bson.M{
+				"net": bson.M{"port": 27018, "ssl": bson.M{"mode": "requireSSL", "PEMKeyPassword": "pemsecret"}},
+			},
+			"operationTime": bson.MongoTimestamp(1490708156<<32 | 1),
+		},
+	}
+
+	rec := NewRecorder()
+	opts := DialOptions{ConnectTimeout: time.Second, CommandTimeout: time.Second}
+	conn := rec.Factory(NewConnectorFactory(context.Background(), opts))(server.Addr())
+	if err := conn.Connect(); err != nil {
+		t.Fatalf("cannot connect: %s", err)
+	}
+	defer conn.Close()
+	clo, err := conn.GetCmdLineOpts()
+	if err != nil {
+		t.Fatalf("cannot get the command line options: %s", err)
+	}
+	if clo.Parsed.Net.SSL.Mode != "requireSSL" || clo.Parsed.Net.SSL.PEMKeyPassword != Redacted {
+		t.Errorf("invalid command line options %+v", clo.Parsed.Net.SSL)
+	}
+
+	files, _, err := rec.bundleFiles(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := rec.Manifest(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := m.Entries[1]
+	if entry.Command != "GetCmdLineOpts" || !entry.Reply {
+		t.Fatalf("the reply of the server must be saved: %+v", entry)
+	}
+	// The reply is saved with the fields the tool doesn't decode and their types
+	reply := string(files[entry.File])
+	for _, expect := range []string{`"port": 27018`, `"$timestamp"`} {
+		if !strings.Contains(reply, expect) {
+			t.Errorf("%s not found in the reply:\n%s", expect, reply)
+		}
+	}
+	if strings.Contains(reply, "pemsecret") {
+		t.Errorf("the reply must be redacted:\n%s", reply)
+	}
+
+	bundle, err := newBundle(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := bundle.Factory()(server.Addr())
+	replayed.Connect()
+	got, err := replayed.GetCmdLineOpts()
+	if err != nil {
+		t.Fatalf("cannot replay: %s", err)
+	}
+	if !reflect.DeepEqual(got, clo) {
+		t.Errorf("the replay must decode the reply like the server's:\ngot:    %+v\nexpect: %+v", got, clo)
+	}
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+	"labix.org/v2/mgo/bson"
+)
+
+// Redacted replaces the secrets in the reports and bundles
+const Redacted = "xxxxx"
+
+// secretOptions are the mongod and mongos options whose values are secrets
+var secretOptions = map[string]bool{
+	"sslPEMKeyPassword":             true,
+	"sslClusterPassword":            true,
+	"tlsCertificateKeyFilePassword": true,
+	"tlsClusterPassword":            true,
+	"kmipClientCertificatePassword": true,
+	"ldapQueryPassword":             true,
+}
+
+// uriPassword matches the password in a connection string or proxy URL
+var uriPassword = regexp.MustCompile(`([a-zA-Z0-9+.-]+://[^:@/,]*:)[^@/]*@`)
+
+// RedactArgs returns a copy of args with the values of the flags in secrets
+// and the passwords of URLs replaced by Redacted. Flags can start with - or
+// -- and have their value in the next argument or after =.
+func RedactArgs(args []string, secrets map[string]bool) []string {
+	redacted := make([]string, 0, len(args))
+	hideNext := false
+	for _, arg := range args {
+		switch {
+		case hideNext:
+			hideNext = false
+			// A flag after a secret means it is asked interactively
+			if !strings.HasPrefix(arg, "-") {
+				arg = Redacted
+			}
+		case strings.HasPrefix(arg, "-"):
+			name := strings.TrimLeft(arg, "-")
+			if i := strings.Index(name, "="); i >= 0 {
+				if secrets[name[:i]] {
+					arg = arg[:len(arg)-len(name)+i+1] + Redacted
+				}
+			} else {
+				hideNext = secrets[name]
+			}
+		}
+		redacted = append(redacted, uriPassword.ReplaceAllString(arg, "${1}"+Redacted+"@"))
+	}
+	return redacted
+}
+
+// secretSettings are the parsed options of a server whose values are secrets
+var secretSettings = []string{
+	"net.ssl.PEMKeyPassword",
+	"net.ssl.clusterPassword",
+	"net.tls.certificateKeyFilePassword",
+	"net.tls.clusterPassword",
+	"security.kmip.clientCertificatePassword",
+	"security.ldap.bind.queryPassword",
+}
+
+// RedactCmdLineOpts replaces the passwords in the options of a server, in
+// its parsed configuration and in its command line. The parsed configuration
+// is redacted as a document, like the getCmdLineOpts replies in the bundles.
+func RedactCmdLineOpts(clo *proto.CommandLineOptions) {
+	var parsed bson.D
+	if data, err := bson.Marshal(clo.Parsed); err == nil && bson.Unmarshal(data, &parsed) == nil {
+		redactParsed(parsed)
+		if data, err := bson.Marshal(parsed); err == nil {
+			bson.Unmarshal(data, &clo.Parsed)
+		}
+	}
+	if clo.Argv != nil {
+		clo.Argv = RedactArgs(clo.Argv, secretOptions)
+	}
+}
+
+// redactCmdLineReply returns a getCmdLineOpts reply with the passwords in
+// its parsed configuration and in its command line replaced by Redacted
+func redactCmdLineReply(raw bson.Raw) (bson.Raw, error) {
+	var doc bson.D
+	if err := raw.Unmarshal(&doc); err != nil {
+		return bson.Raw{}, err
+	}
+	for i, elem := range doc {
+		switch elem.Name {
+		case "argv":
+			argv, _ := elem.Value.([]interface{})
+			args := make([]string, 0, len(argv))
+			for _, arg := range argv {
+				s, _ := arg.(string)
+				args = append(args, s)
+			}
+			redacted := make([]interface{}, 0, len(args))
+			for _, arg := range RedactArgs(args, secretOptions) {
+				redacted = append(redacted, arg)
+			}
+			doc[i].Value = redacted
+		case "parsed":
+			if parsed, ok := elem.Value.(bson.D); ok {
+				redactParsed(parsed)
+			}
+		}
+	}
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return bson.Raw{}, err
+	}
+	return bson.Raw{Kind: 0x03, Data: data}, nil
+}
+
+// redactParsed replaces the values of the secretSettings set in parsed by
+// Redacted
+func redactParsed(parsed bson.D) {
+	for _, setting := range secretSettings {
+		redactSetting(parsed, strings.Split(setting, "."))
+	}
+}
+
+// redactSetting replaces the value of the setting at path in doc, if it is
+// set, by Redacted
+func redactSetting(doc bson.D, path []string) {
+	for i, elem := range doc {
+		if elem.Name != path[0] {
+			continue
+		}
+		if len(path) > 1 {
+			if sub, ok := elem.Value.(bson.D); ok {
+				redactSetting(sub, path[1:])
+			}
+		} else if s, ok := elem.Value.(string); ok && s != "" {
+			doc[i].Value = Redacted
+		}
+	}
+}
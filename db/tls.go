@@ -0,0 +1,133 @@
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// TLSConfig builds the tls.Config used to connect when TLS is enabled
+func (o DialOptions) TLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: o.TLSAllowInvalidCertificates,
+	}
+
+	if o.TLSCAFile != "" {
+		pemCerts, err := ioutil.ReadFile(o.TLSCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read CA file")
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pemCerts) {
+			return nil, fmt.Errorf("no valid certificates found in %s", o.TLSCAFile)
+		}
+	}
+
+	if o.TLSCertificateKeyFile != "" {
+		cert, err := loadCertificateKeyFile(o.TLSCertificateKeyFile, o.TLSCertificateKeyFilePassword)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	// Go cannot skip only the hostname verification so, turn off the
+	// standard verification and check the certificate chain by ourselves.
+	if o.TLSAllowInvalidHostnames && !o.TLSAllowInvalidCertificates {
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = verifyChainFunc(cfg.RootCAs)
+	}
+
+	return cfg, nil
+}
+
+func verifyChainFunc(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("server didn't present a certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return errors.Wrap(err, "cannot parse server certificate")
+			}
+			certs[i] = cert
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		return err
+	}
+}
+
+// loadCertificateKeyFile loads a PEM file having both, the client
+// certificate and its private key. The key can be encrypted with password,
+// either as a legacy OpenSSL PEM block or as an encrypted PKCS#8 key.
+func loadCertificateKeyFile(filename, password string) (tls.Certificate, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "cannot read certificate key file")
+	}
+
+	var certPEM, keyPEM []byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch {
+		case block.Type == "CERTIFICATE":
+			certPEM = append(certPEM, pem.EncodeToMemory(block)...)
+		case block.Type == "ENCRYPTED PRIVATE KEY":
+			if password == "" {
+				return tls.Certificate{}, fmt.Errorf("the private key in %s is encrypted but no password was given", filename)
+			}
+			der, err := decryptPKCS8(block.Bytes, password)
+			if err != nil {
+				return tls.Certificate{}, errors.Wrap(err, "cannot decrypt private key")
+			}
+			keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		case x509.IsEncryptedPEMBlock(block):
+			if password == "" {
+				return tls.Certificate{}, fmt.Errorf("the private key in %s is encrypted but no password was given", filename)
+			}
+			der, err := x509.DecryptPEMBlock(block, []byte(password))
+			if err != nil {
+				return tls.Certificate{}, errors.Wrap(err, "cannot decrypt private key")
+			}
+			keyPEM = pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})
+		default:
+			keyPEM = pem.EncodeToMemory(block)
+		}
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrapf(err, "invalid certificate key file %s", filename)
+	}
+	return cert, nil
+}
+
+// certificateSubject returns the subject of the client certificate in
+// RFC 2253 format, as expected by MONGODB-X509 authentication.
+func certificateSubject(cfg *tls.Config) (string, error) {
+	if len(cfg.Certificates) == 0 || len(cfg.Certificates[0].Certificate) == 0 {
+		return "", fmt.Errorf("there is no client certificate")
+	}
+	cert, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	if err != nil {
+		return "", errors.Wrap(err, "cannot parse client certificate")
+	}
+	return cert.Subject.String(), nil
+}
@@ -0,0 +1,270 @@
+package db
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	uriScheme    = "mongodb://"
+	srvURIScheme = "mongodb+srv://"
+	srvService   = "mongodb"
+)
+
+// Resolver is used to resolve the SRV and TXT records of mongodb+srv:// URIs.
+// It is an interface so tests can use a local DNS stand-in.
+type Resolver interface {
+	LookupSRV(service, proto, name string) (string, []*net.SRV, error)
+	LookupTXT(name string) ([]string, error)
+}
+
+type netResolver struct{}
+
+func (netResolver) LookupSRV(service, proto, name string) (string, []*net.SRV, error) {
+	return net.LookupSRV(service, proto, name)
+}
+
+func (netResolver) LookupTXT(name string) ([]string, error) {
+	return net.LookupTXT(name)
+}
+
+// DefaultResolver uses the system DNS resolver
+var DefaultResolver Resolver = netResolver{}
+
+// ConnectionString holds the parts of a mongodb:// or mongodb+srv:// URI
+// this tool knows how to use.
+// See https://docs.mongodb.com/manual/reference/connection-string/
+type ConnectionString struct {
+	Hosts          []string
+	Database       string
+	Username       string
+	Password       string
+	AuthSource     string
+	AuthMechanism  string
+	ReplicaSet     string
+	TLS            bool
+	ReadPreference string
+	ConnectTimeout time.Duration
+	AppName        string
+	// Ignored are the options in the URI this tool doesn't use, like
+	// retryWrites or w, which only apply to writes.
+	Ignored []string
+}
+
+// IsURI returns true if s looks like a connection string instead of a
+// plain host:port list
+func IsURI(s string) bool {
+	return strings.HasPrefix(s, uriScheme) || strings.HasPrefix(s, srvURIScheme)
+}
+
+// ParseURI parses a mongodb:// or mongodb+srv:// URI. SRV and TXT records
+// for mongodb+srv:// URIs are looked up using resolver.
+func ParseURI(uri string, resolver Resolver) (*ConnectionString, error) {
+	isSRV := false
+	switch {
+	case strings.HasPrefix(uri, srvURIScheme):
+		isSRV = true
+		uri = uri[len(srvURIScheme):]
+	case strings.HasPrefix(uri, uriScheme):
+		uri = uri[len(uriScheme):]
+	default:
+		return nil, fmt.Errorf("invalid connection string: scheme must be %s or %s", uriScheme, srvURIScheme)
+	}
+
+	cs := &ConnectionString{}
+
+	var query string
+	if i := strings.Index(uri, "?"); i != -1 {
+		query = uri[i+1:]
+		uri = uri[:i]
+	}
+
+	if i := strings.LastIndex(uri, "@"); i != -1 {
+		if err := cs.setCredentials(uri[:i]); err != nil {
+			return nil, err
+		}
+		uri = uri[i+1:]
+	}
+
+	if i := strings.Index(uri, "/"); i != -1 {
+		db, err := url.PathUnescape(uri[i+1:])
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot unescape database name")
+		}
+		cs.Database = db
+		uri = uri[:i]
+	}
+
+	if uri == "" {
+		return nil, fmt.Errorf("invalid connection string: no hosts")
+	}
+
+	if isSRV {
+		// TLS is on by default for SRV connection strings. An explicit
+		// tls=false in the query string below overrides it.
+		cs.TLS = true
+		txt, err := cs.resolveSRV(uri, resolver)
+		if err != nil {
+			return nil, err
+		}
+		if err := cs.setOptions(txt, true); err != nil {
+			return nil, errors.Wrap(err, "invalid TXT record")
+		}
+	} else {
+		cs.Hosts = strings.Split(uri, ",")
+	}
+
+	if err := cs.setOptions(query, false); err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+// Apply returns a copy of opts completed with the settings of the connection
+// string. Fields already set in opts (i.e. from command line flags) win.
+func (cs *ConnectionString) Apply(opts DialOptions) DialOptions {
+	if opts.Username == "" {
+		opts.Username = cs.Username
+	}
+	if opts.Password == "" {
+		opts.Password = cs.Password
+	}
+	if opts.AuthDB == "" {
+		opts.AuthDB = cs.AuthSource
+		if opts.AuthDB == "" {
+			opts.AuthDB = cs.Database
+		}
+	}
+	if opts.AuthMechanism == "" {
+		opts.AuthMechanism = cs.AuthMechanism
+	}
+	if opts.ReplicaSetName == "" {
+		opts.ReplicaSetName = cs.ReplicaSet
+	}
+	if opts.ReadPreference == "" {
+		opts.ReadPreference = cs.ReadPreference
+	}
+	if opts.ConnectTimeout == 0 {
+		opts.ConnectTimeout = cs.ConnectTimeout
+	}
+	if opts.AppName == "" {
+		opts.AppName = cs.AppName
+	}
+	opts.TLS = opts.TLS || cs.TLS
+	return opts
+}
+
+func (cs *ConnectionString) setCredentials(userinfo string) error {
+	var err error
+	parts := strings.SplitN(userinfo, ":", 2)
+	if parts[0] == "" {
+		return fmt.Errorf("invalid connection string: credentials must be provided as user:pass@host")
+	}
+	if cs.Username, err = url.PathUnescape(parts[0]); err != nil {
+		return errors.Wrap(err, "cannot unescape username")
+	}
+	if len(parts) > 1 {
+		if cs.Password, err = url.PathUnescape(parts[1]); err != nil {
+			return errors.New("cannot unescape password")
+		}
+	}
+	return nil
+}
+
+// resolveSRV sets the hosts list from the SRV records of hostname and
+// returns the content of its TXT record, if any.
+func (cs *ConnectionString) resolveSRV(hostname string, resolver Resolver) (string, error) {
+	if strings.Contains(hostname, ",") || strings.Contains(hostname, ":") {
+		return "", fmt.Errorf("mongodb+srv:// URIs must have exactly one hostname and no port")
+	}
+	parts := strings.Split(hostname, ".")
+	if len(parts) < 3 {
+		return "", fmt.Errorf("mongodb+srv:// hostname %q must have at least 3 parts", hostname)
+	}
+	parentDomain := "." + strings.Join(parts[1:], ".")
+
+	_, addrs, err := resolver.LookupSRV(srvService, "tcp", hostname)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot lookup SRV records for %s", hostname)
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("no SRV records found for %s", hostname)
+	}
+	for _, addr := range addrs {
+		target := strings.TrimSuffix(addr.Target, ".")
+		if !strings.HasSuffix(target, parentDomain) {
+			return "", fmt.Errorf("SRV record target %s is not in the %s domain", target, parentDomain[1:])
+		}
+		cs.Hosts = append(cs.Hosts, net.JoinHostPort(target, strconv.Itoa(int(addr.Port))))
+	}
+
+	txt, err := resolver.LookupTXT(hostname)
+	if err != nil {
+		// A missing TXT record is not an error
+		return "", nil
+	}
+	if len(txt) > 1 {
+		return "", fmt.Errorf("multiple TXT records found for %s", hostname)
+	}
+	if len(txt) == 0 {
+		return "", nil
+	}
+	return txt[0], nil
+}
+
+// setOptions parses a key=value&key=value string. TXT records are only
+// allowed to set authSource and replicaSet.
+func (cs *ConnectionString) setOptions(query string, fromTXT bool) error {
+	for _, pair := range strings.FieldsFunc(query, func(c rune) bool { return c == '&' || c == ';' }) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("connection option must be key=value: %s", pair)
+		}
+		key := strings.ToLower(kv[0])
+		value, err := url.PathUnescape(kv[1])
+		if err != nil {
+			return errors.Wrapf(err, "cannot unescape value for %s", kv[0])
+		}
+		if fromTXT && key != "authsource" && key != "replicaset" {
+			return fmt.Errorf("option %s is not allowed in a TXT record", kv[0])
+		}
+
+		switch key {
+		case "replicaset":
+			cs.ReplicaSet = value
+		case "authsource":
+			cs.AuthSource = value
+		case "authmechanism":
+			cs.AuthMechanism = value
+		case "tls", "ssl":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: %s", kv[0], value)
+			}
+			cs.TLS = b
+		case "readpreference":
+			if !readPreferenceModes[value] {
+				return fmt.Errorf("invalid read preference: %s", value)
+			}
+			cs.ReadPreference = value
+		case "connecttimeoutms":
+			ms, err := strconv.Atoi(value)
+			if err != nil || ms < 0 {
+				return fmt.Errorf("invalid value for %s: %s", kv[0], value)
+			}
+			cs.ConnectTimeout = time.Duration(ms) * time.Millisecond
+		case "appname":
+			cs.AppName = value
+		default:
+			cs.Ignored = append(cs.Ignored, kv[0])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"labix.org/v2/mgo/bson"
+
+	"github.com/percona/pt-mongodb-summary/db"
+	"github.com/percona/pt-mongodb-summary/proto"
+	"github.com/pkg/errors"
+)
+
+// seed is a host (or a list of hosts from one connection string) given in
+// --hosts and the options used to dial it
+type seed struct {
+	hostname string
+	dialOpts db.DialOptions
+}
+
+// deployment is a replica set, a sharded cluster or a standalone server. Each
+// deployment gets its own report, no matter how many seeds pointed to it.
+type deployment struct {
+	ID       string
+	Seeds    []string
+	hostname string
+	dialOpts db.DialOptions
+	cm       *db.ConnectionManager
+}
+
+// clusterVersion is the document in config.version
+type clusterVersion struct {
+	ClusterID bson.ObjectId `bson:"clusterId"`
+}
+
+// getSeeds returns the seeds in all the --hosts values. Lists of hosts are
+// split so each host is a seed on its own. Connection strings are kept as one
+// seed because their options apply to all their hosts.
+func getSeeds(opts options, resolver db.Resolver) ([]seed, error) {
+	var seeds []seed
+	seen := make(map[string]bool)
+	for _, value := range opts.Hosts {
+		hosts := []string{value}
+		if !db.IsURI(value) {
+			hosts = strings.Split(value, ",")
+		}
+		for _, host := range hosts {
+			hostname, dialOpts, err := connectionSettings(opts, strings.TrimSpace(host), resolver)
+			if err != nil {
+				return nil, err
+			}
+			if hostname == "" || seen[hostname] {
+				continue
+			}
+			seen[hostname] = true
+			seeds = append(seeds, seed{hostname: hostname, dialOpts: dialOpts})
+		}
+	}
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("no hosts to connect to")
+	}
+	return seeds, nil
+}
+
+// groupSeeds connects to every seed and merges the seeds that belong to the
+// same deployment. The first seed of each deployment is the one used to
+// collect its data. Seeds that cannot be reached or identified are logged and
+// returned as findings.
+func groupSeeds(seeds []seed, newManager func(seed) *db.ConnectionManager) ([]*deployment, []finding) {
+	var deployments []*deployment
+	var skipped []finding
+	byID := make(map[string]*deployment)
+
+	for _, s := range seeds {
+		cm := newManager(s)
+		conn, err := cm.Get(s.hostname)
+		if err != nil {
+			log.Printf("cannot connect to %s: %s", s.hostname, err)
+			cm.Close()
+			skipped = append(skipped, seedError(s, "cannot connect: %s", err))
+			continue
+		}
+
+		id := s.hostname
+		if !s.dialOpts.Direct {
+			id, err = deploymentID(conn, s.hostname)
+			if err != nil {
+				log.Printf("cannot identify the deployment of %s: %s", s.hostname, err)
+				cm.Close()
+				skipped = append(skipped, seedError(s, "cannot identify the deployment: %s", err))
+				continue
+			}
+		}
+
+		if d, ok := byID[id]; ok {
+			d.Seeds = append(d.Seeds, s.hostname)
+			cm.Close()
+			continue
+		}
+		d := &deployment{
+			ID:       id,
+			Seeds:    []string{s.hostname},
+			hostname: s.hostname,
+			dialOpts: s.dialOpts,
+			cm:       cm,
+		}
+		byID[id] = d
+		deployments = append(deployments, d)
+	}
+	return deployments, skipped
+}
+
+func seedError(s seed, format string, args ...interface{}) finding {
+	return finding{Severity: severityError, Section: "connection", Host: s.hostname, Message: fmt.Sprintf(format, args...)}
+}
+
+// deploymentID returns an identifier that is the same for all the members of
+// a replica set or all the mongos of a sharded cluster. Standalone servers
+// are identified by hostname.
+func deploymentID(conn db.MongoConnector, hostname string) (string, error) {
+	md, err := conn.IsMaster()
+	if err != nil {
+		return "", err
+	}
+
+	if md.Msg == "isdbgrid" {
+		var cv clusterVersion
+		err := conn.FindOne("config", "version", bson.M{}, nil, &cv)
+		if err != nil {
+			return "", errors.Wrap(err, "cannot get the cluster id")
+		}
+		return "cluster/" + cv.ClusterID.Hex(), nil
+	}
+
+	if md.SetName != nil || md.Hosts != nil {
+		// Different replica sets can have the same name so the members are
+		// part of the id too
+		return fmt.Sprintf("replset/%v/%s", md.SetName, strings.Join(replicasetHosts(md), ",")), nil
+	}
+
+	return "mongod/" + hostname, nil
+}
+
+// replicasetHosts returns all the members in the isMaster response, sorted
+func replicasetHosts(md proto.MasterDoc) []string {
+	hosts := append([]string{}, md.Hosts...)
+	hosts = append(hosts, md.Passives...)
+	hosts = append(hosts, md.Arbiters...)
+	sort.Strings(hosts)
+	return hosts
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/percona/pt-mongodb-summary/db"
+	"github.com/percona/pt-mongodb-summary/templates"
+	"github.com/pkg/errors"
+)
+
+// diffSchemaVersion is the version of the JSON output of diff. It follows the
+// same rules as reportSchemaVersion.
+const diffSchemaVersion = 1
+
+// minSizeChange is the smallest size change, in bytes, reported as a large
+// movement, so nearly empty collections doubling their size are not reported
+const minSizeChange = 1 << 20
+
+// diffOptions are the options of the diff command
+type diffOptions struct {
+	OutputFormat string
+	// SizeChange is the size change, in percent, reported as a large movement
+	SizeChange float64
+	NoColor    bool
+	Width      int
+	A, B       string
+}
+
+// change is a difference between two snapshots of a deployment. Old and New
+// are nil when something was added or removed.
+type change struct {
+	Deployment string      `json:"deployment"`
+	Section    string      `json:"section"`
+	Item       string      `json:"item"`
+	Old        interface{} `json:"old"`
+	New        interface{} `json:"new"`
+	// Message describes the change for people
+	Message string `json:"message"`
+}
+
+// diffReport is the result of comparing snapshot A with snapshot B
+type diffReport struct {
+	SchemaVersion int      `json:"schemaVersion"`
+	A             string   `json:"a"`
+	B             string   `json:"b"`
+	Changes       []change `json:"changes"`
+}
+
+func parseDiffArgs(args []string) (diffOptions, error) {
+	var opts diffOptions
+	fs := flag.NewFlagSet(toolName+" diff", flag.ContinueOnError)
+	fs.StringVar(&opts.OutputFormat, "output-format", "text", "Format of the changes: text, json")
+	fs.Float64Var(&opts.SizeChange, "size-change", 20, "Database and collection size changes, in percent, to report")
+	fs.BoolVar(&opts.NoColor, "no-color", false, "Don't color the output, even when writing to a terminal")
+	fs.IntVar(&opts.Width, "width", 0, fmt.Sprintf("Width of the text output. 0 means the terminal width, or %d if the output is not a terminal", defaultWidth))
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s diff: %s diff [flags] A B\n\n", toolName, toolName)
+		fmt.Fprintf(os.Stderr, "A and B are JSON reports or bundles saved with --save-dir or --bundle.\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return diffOptions{}, err
+	}
+	if opts.OutputFormat != "text" && opts.OutputFormat != "json" {
+		return diffOptions{}, fmt.Errorf("invalid output format %q. Valid formats are: text, json", opts.OutputFormat)
+	}
+	if opts.SizeChange < 0 {
+		return diffOptions{}, fmt.Errorf("invalid size change %v", opts.SizeChange)
+	}
+	if fs.NArg() != 2 {
+		return diffOptions{}, fmt.Errorf("diff needs two snapshots to compare. Usage: %s diff [flags] A B", toolName)
+	}
+	opts.A, opts.B = fs.Arg(0), fs.Arg(1)
+	return opts, nil
+}
+
+// diffMain runs the diff command. It exits with 0 if the snapshots are the
+// same and 1 if they are different, like diff(1). Errors exit with 3 like in
+// the rest of the tool, not with 2 like in diff(1), since 2 means a critical
+// finding.
+func diffMain(args []string) {
+	opts, err := parseDiffArgs(args)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+	a, err := loadSnapshot(opts.A)
+	if err != nil {
+		exitWithError(errors.Wrapf(err, "cannot read %s", opts.A))
+	}
+	b, err := loadSnapshot(opts.B)
+	if err != nil {
+		exitWithError(errors.Wrapf(err, "cannot read %s", opts.B))
+	}
+
+	d := diffReport{SchemaVersion: diffSchemaVersion, A: opts.A, B: opts.B, Changes: diffSnapshots(a, b, opts.SizeChange)}
+	if opts.OutputFormat == "json" {
+		err = writeDiffJSON(os.Stdout, d)
+	} else {
+		err = writeDiffText(os.Stdout, d, newTextStyle(options{NoColor: opts.NoColor, Width: opts.Width}, os.Stdout))
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+	if len(d.Changes) > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadSnapshot returns the reports in a JSON report or in a bundle, a
+// directory or a .tar.gz file
+func loadSnapshot(path string) ([]templateData, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		magic, _ := bufio.NewReader(f).Peek(2)
+		if string(magic) != "\x1f\x8b" {
+			return loadJSONReport(path)
+		}
+	}
+	return reportsFromBundle(path)
+}
+
+func loadJSONReport(filename string) ([]templateData, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var report jsonReport
+	if err := json.Unmarshal(buf, &report); err != nil {
+		return nil, errors.Wrap(err, "invalid JSON report")
+	}
+	if report.SchemaVersion != reportSchemaVersion {
+		return nil, fmt.Errorf("unsupported JSON report schema version %d", report.SchemaVersion)
+	}
+	return report.Deployments, nil
+}
+
+// reportsFromBundle collects the reports of all the deployments in a bundle
+func reportsFromBundle(path string) ([]templateData, error) {
+	bundle, err := db.OpenBundle(path)
+	if err != nil {
+		return nil, err
+	}
+	seeds := bundleSeeds(bundle, []seed{{hostname: "localhost:27017"}})
+	deployments, _ := groupSeeds(seeds, func(s seed) *db.ConnectionManager {
+		return db.NewConnectionManager(bundle.Factory())
+	})
+	var reports []templateData
+	for _, d := range deployments {
+		td, err := collectReport(context.Background(), d, reportSections, options{FromBundle: path})
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, td)
+	}
+	return reports, nil
+}
+
+// deploymentKey identifies the same deployment in two snapshots: a sharded
+// cluster by its cluster id, a replica set by its name, since its members can
+// change, and a standalone server by its host. The reports of older versions
+// have no deployment id and are identified by their seeds.
+func deploymentKey(td templateData) string {
+	if strings.HasPrefix(td.DeploymentID, "replset/") {
+		// replset/name/members
+		return "replset/" + strings.SplitN(td.DeploymentID, "/", 3)[1]
+	}
+	if td.DeploymentID != "" {
+		return td.DeploymentID
+	}
+	return deploymentName(td)
+}
+
+// deploymentName is the name of a deployment in the changes
+func deploymentName(td templateData) string {
+	if len(td.Seeds) > 0 {
+		return strings.Join(td.Seeds, ",")
+	}
+	return td.AnsweredBy
+}
+
+// diffSnapshots returns the changes from the reports in a to the ones in b.
+// Deployments are matched by deploymentKey. If each snapshot has only one
+// deployment, they are compared even if their keys are different.
+func diffSnapshots(a, b []templateData, sizeChange float64) []change {
+	if len(a) == 1 && len(b) == 1 {
+		return diffReports(deploymentName(b[0]), a[0], b[0], sizeChange)
+	}
+
+	var changes []change
+	inB := make(map[string]templateData)
+	for _, td := range b {
+		inB[deploymentKey(td)] = td
+	}
+	seen := make(map[string]bool)
+	for _, before := range a {
+		key := deploymentKey(before)
+		seen[key] = true
+		td, ok := inB[key]
+		if !ok {
+			name := deploymentName(before)
+			changes = append(changes, change{Deployment: name, Section: "deployment", Item: name, Message: "deployment removed"})
+			continue
+		}
+		changes = append(changes, diffReports(deploymentName(td), before, td, sizeChange)...)
+	}
+	for _, td := range b {
+		if !seen[deploymentKey(td)] {
+			name := deploymentName(td)
+			changes = append(changes, change{Deployment: name, Section: "deployment", Item: name, Message: "deployment added"})
+		}
+	}
+	return changes
+}
+
+// diffReports returns the changes from a to b, two snapshots of the same
+// deployment
+func diffReports(deployment string, a, b templateData, sizeChange float64) []change {
+	var changes []change
+	add := func(section, item string, before, after interface{}, format string, args ...interface{}) {
+		changes = append(changes, change{
+			Deployment: deployment,
+			Section:    section,
+			Item:       item,
+			Old:        before,
+			New:        after,
+			Message:    fmt.Sprintf(format, args...),
+		})
+	}
+	changed := func(section, item string, before, after interface{}) {
+		if before != after {
+			add(section, item, before, after, "%s changed from %s to %s", item, formatValue(before), formatValue(after))
+		}
+	}
+
+	changed("hostinfo", "version", a.ServerStatus.Version, b.ServerStatus.Version)
+	changed("hostinfo", "node type", a.NodeType, b.NodeType)
+
+	diffMembers(a, b, add, changed)
+	diffSettings(a, b, add)
+
+	if a.Security != nil && b.Security != nil {
+		changed("security", "users", a.Security.Users, b.Security.Users)
+		changed("security", "roles", a.Security.Roles, b.Security.Roles)
+		changed("security", "auth", a.Security.Auth, b.Security.Auth)
+		changed("security", "SSL", a.Security.SSL, b.Security.SSL)
+	}
+
+	if a.Oplog != nil && b.Oplog != nil && a.Oplog.Size != b.Oplog.Size {
+		oldSize, _ := humanMegabytes(a.Oplog.Size)
+		newSize, _ := humanMegabytes(b.Oplog.Size)
+		add("oplog", "oplog size", a.Oplog.Size, b.Oplog.Size, "oplog size changed from %s to %s", oldSize, newSize)
+	}
+
+	diffSizes(a, b, sizeChange, add)
+	return changes
+}
+
+type addChange func(section, item string, before, after interface{}, format string, args ...interface{})
+
+func diffMembers(a, b templateData, add addChange, changed func(section, item string, before, after interface{})) {
+	states := make(map[string]string)
+	for _, m := range a.ReplicaMembers {
+		states[m.Name] = m.StateStr
+	}
+	inB := make(map[string]bool)
+	for _, m := range b.ReplicaMembers {
+		inB[m.Name] = true
+		state, ok := states[m.Name]
+		if !ok {
+			add("instances", m.Name, nil, m.StateStr, "member %s added as %s", m.Name, m.StateStr)
+			continue
+		}
+		changed("instances", "state of "+m.Name, state, m.StateStr)
+	}
+	for _, m := range a.ReplicaMembers {
+		if !inB[m.Name] {
+			add("instances", m.Name, m.StateStr, nil, "member %s removed", m.Name)
+		}
+	}
+}
+
+// diffSettings compares the parsed command line options and security
+// settings. Values of settings that look like passwords are not shown.
+func diffSettings(a, b templateData, add addChange) {
+	before, after := settings(a), settings(b)
+	var names []string
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		o, inA := before[name]
+		n, inB := after[name]
+		if inA && inB && o == n {
+			continue
+		}
+		if strings.Contains(strings.ToLower(name), "password") {
+			add("settings", name, nil, nil, "%s changed", name)
+			continue
+		}
+		switch {
+		case !inA:
+			add("settings", name, nil, n, "%s added as %s", name, formatValue(n))
+		case !inB:
+			add("settings", name, o, nil, "%s removed, it was %s", name, formatValue(o))
+		default:
+			add("settings", name, o, n, "%s changed from %s to %s", name, formatValue(o), formatValue(n))
+		}
+	}
+}
+
+// settings returns the command line options, like parsed.net.port, with
+// their values
+func settings(td templateData) map[string]interface{} {
+	values := make(map[string]interface{})
+	buf, err := json.Marshal(td.CommandLineOptions.Parsed)
+	if err != nil {
+		return values
+	}
+	var doc interface{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return values
+	}
+	flatten("parsed", doc, values)
+	return values
+}
+
+func flatten(prefix string, v interface{}, values map[string]interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for name, value := range v {
+			flatten(prefix+"."+name, value, values)
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			buf, _ := json.Marshal(v)
+			values[prefix] = string(buf)
+		}
+	default:
+		values[prefix] = v
+	}
+}
+
+// diffSizes reports the databases added or removed and the databases and
+// collections whose size changed more than sizeChange percent
+func diffSizes(a, b templateData, sizeChange float64, add addChange) {
+	moved := func(item string, before, after int64) {
+		diff := after - before
+		if before == 0 || math.Abs(float64(diff)) < minSizeChange {
+			return
+		}
+		pct := float64(diff) * 100 / float64(before)
+		if math.Abs(pct) < sizeChange {
+			return
+		}
+		verb := "grew"
+		if diff < 0 {
+			verb = "shrank"
+		}
+		oldSize, _ := humanBytes(before)
+		newSize, _ := humanBytes(after)
+		add("collections", item, before, after, "%s %s %.0f%% from %s to %s", item, verb, math.Abs(pct), oldSize, newSize)
+	}
+
+	oldDBs := make(map[string]int)
+	for i, database := range a.Databases {
+		oldDBs[database.Name] = i
+	}
+	inB := make(map[string]bool)
+	for _, database := range b.Databases {
+		inB[database.Name] = true
+		i, ok := oldDBs[database.Name]
+		if !ok {
+			add("collections", database.Name, nil, database.SizeOnDisk, "database %s added", database.Name)
+			continue
+		}
+		oldDB := a.Databases[i]
+		moved("database "+database.Name, oldDB.SizeOnDisk, database.SizeOnDisk)
+
+		oldCols := make(map[string]int64)
+		for _, col := range oldDB.Collections {
+			oldCols[col.Name] = col.Size
+		}
+		for _, col := range database.Collections {
+			if size, ok := oldCols[col.Name]; ok {
+				moved("collection "+database.Name+"."+col.Name, size, col.Size)
+			}
+		}
+	}
+	for _, database := range a.Databases {
+		if !inB[database.Name] {
+			add("collections", database.Name, database.SizeOnDisk, nil, "database %s removed", database.Name)
+		}
+	}
+}
+
+func formatValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		if s == "" {
+			return `""`
+		}
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func writeDiffJSON(w io.Writer, d diffReport) error {
+	if d.Changes == nil {
+		d.Changes = []change{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(d)
+}
+
+func writeDiffText(w io.Writer, d diffReport, style textStyle) error {
+	t, err := template.New("diff").Funcs(withTemplateFuncs(style.funcs())).Parse(templates.Diff)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, d)
+}
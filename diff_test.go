@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+func diffSnapshotsForTest() (templateData, templateData) {
+	a := testReport()
+	a.ServerStatus.Version = "3.4.1"
+	a.ReplicaMembers = []proto.Members{
+		{Name: "db1:27017", StateStr: "PRIMARY"},
+		{Name: "db2:27017", StateStr: "SECONDARY"},
+	}
+	a.CommandLineOptions.Parsed.Storage.Engine = "mmapv1"
+	a.CommandLineOptions.Parsed.Net.SSL.PEMKeyPassword = "old secret"
+	a.Oplog = &OplogInfo{Size: 1024}
+	a.Databases = []proto.Database{
+		{Name: "shop", SizeOnDisk: 100 << 20, Collections: []proto.CollStats{
+			{Name: "orders", Size: 80 << 20},
+			{Name: "tiny", Size: 1 << 10},
+		}},
+		{Name: "old", SizeOnDisk: 1 << 20},
+	}
+
+	b := testReport()
+	b.ServerStatus.Version = "3.6.3"
+	b.ReplicaMembers = []proto.Members{
+		{Name: "db1:27017", StateStr: "SECONDARY"},
+		{Name: "db3:27017", StateStr: "PRIMARY"},
+	}
+	b.CommandLineOptions.Parsed.Storage.Engine = "wiredTiger"
+	b.CommandLineOptions.Parsed.Net.SSL.PEMKeyPassword = "new secret"
+	b.Security.Users = 5
+	b.Oplog = &OplogInfo{Size: 2048}
+	b.Databases = []proto.Database{
+		{Name: "shop", SizeOnDisk: 110 << 20, Collections: []proto.CollStats{
+			{Name: "orders", Size: 160 << 20},
+			{Name: "tiny", Size: 1 << 15},
+		}},
+		{Name: "new", SizeOnDisk: 1 << 20},
+	}
+	return a, b
+}
+
+func TestDiffReports(t *testing.T) {
+	a, b := diffSnapshotsForTest()
+	var messages []string
+	for _, c := range diffSnapshots([]templateData{a}, []templateData{b}, 20) {
+		if c.Deployment != "db1:27017" {
+			t.Errorf("invalid deployment %q", c.Deployment)
+		}
+		messages = append(messages, c.Section+": "+c.Message)
+	}
+	expect := []string{
+		"hostinfo: version changed from 3.4.1 to 3.6.3",
+		"instances: state of db1:27017 changed from PRIMARY to SECONDARY",
+		"instances: member db3:27017 added as PRIMARY",
+		"instances: member db2:27017 removed",
+		"settings: parsed.net.ssl.pemKeyPassword changed",
+		"settings: parsed.storage.engine changed from mmapv1 to wiredTiger",
+		"security: users changed from 3 to 5",
+		"oplog: oplog size changed from 1.00 GB to 2.00 GB",
+		// shop grew 10%, below the threshold, and tiny grew less than 1 MB
+		"collections: collection shop.orders grew 100% from 80.00 MB to 160.00 MB",
+		"collections: database new added",
+		"collections: database old removed",
+	}
+	if !reflect.DeepEqual(messages, expect) {
+		t.Errorf("invalid changes.\ngot:    %q\nexpect: %q", messages, expect)
+	}
+
+	if changes := diffSnapshots([]templateData{a}, []templateData{a}, 20); len(changes) != 0 {
+		t.Errorf("a snapshot must have no changes with itself: %v", changes)
+	}
+
+	// Deployments without an id are matched by their seeds
+	c := testReport()
+	c.Seeds = []string{"other:27017"}
+	changes := diffSnapshots([]templateData{a, c}, []templateData{a}, 20)
+	if len(changes) != 1 || changes[0].Message != "deployment removed" || changes[0].Deployment != "other:27017" {
+		t.Errorf("invalid changes for a removed deployment: %+v", changes)
+	}
+
+	// Replica sets are matched by their name, even if they were reached
+	// through another seed and their members changed
+	a.DeploymentID = "replset/rs0/db1:27017,db2:27017"
+	b.DeploymentID = "replset/rs0/db1:27017,db3:27017"
+	b.Seeds = []string{"db3:27017"}
+	c.DeploymentID = "cluster/58da66e5f1a8ff2d4a29ca8d"
+	changes = diffSnapshots([]templateData{a, c}, []templateData{c, b}, 20)
+	if len(changes) != len(expect) {
+		t.Errorf("invalid changes for the same deployments: %+v", changes)
+	}
+	for _, change := range changes {
+		if change.Deployment != "db3:27017" {
+			t.Errorf("invalid deployment %q", change.Deployment)
+		}
+	}
+}
+
+func TestDiffOutput(t *testing.T) {
+	a, b := diffSnapshotsForTest()
+	dir, err := ioutil.TempDir("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// JSON reports written by --output-format=json can be compared
+	var files []string
+	for i, td := range []templateData{a, b} {
+		buf := &bytes.Buffer{}
+		if err := renderers["json"].Render(buf, results{Reports: []templateData{td}}); err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(dir, []string{"a.json", "b.json"}[i])
+		if err := ioutil.WriteFile(file, buf.Bytes(), 0600); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	snapA, err := loadSnapshot(files[0])
+	if err != nil {
+		t.Fatalf("cannot load the JSON report: %s", err)
+	}
+	snapB, err := loadSnapshot(files[1])
+	if err != nil {
+		t.Fatalf("cannot load the JSON report: %s", err)
+	}
+	d := diffReport{SchemaVersion: diffSchemaVersion, A: "a.json", B: "b.json", Changes: diffSnapshots(snapA, snapB, 20)}
+	if len(d.Changes) != 11 {
+		t.Errorf("the JSON reports must have the same changes as the reports: %+v", d.Changes)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeDiffText(buf, d, textStyle{}); err != nil {
+		t.Fatalf("cannot write the text diff: %s", err)
+	}
+	for _, want := range []string{
+		"# Changes from a.json to b.json #",
+		"\nDeployment Section     Change\n",
+		"\ndb1:27017  instances   member db3:27017 added as PRIMARY\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, buf)
+		}
+	}
+
+	buf.Reset()
+	if err := writeDiffJSON(buf, d); err != nil {
+		t.Fatalf("cannot write the JSON diff: %s", err)
+	}
+	var doc struct {
+		SchemaVersion int `json:"schemaVersion"`
+		Changes       []struct {
+			Section string      `json:"section"`
+			Item    string      `json:"item"`
+			Old     interface{} `json:"old"`
+			New     interface{} `json:"new"`
+		} `json:"changes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+	if doc.SchemaVersion != diffSchemaVersion || doc.Changes[0].Item != "version" || doc.Changes[0].Old != "3.4.1" || doc.Changes[0].New != "3.6.3" {
+		t.Errorf("invalid JSON diff: %+v", doc)
+	}
+	if doc.Changes[4].Old != nil || doc.Changes[4].New != nil {
+		t.Errorf("passwords must not be written: %+v", doc.Changes[4])
+	}
+
+	buf.Reset()
+	d.Changes = nil
+	writeDiffText(buf, d, textStyle{})
+	if !strings.Contains(buf.String(), "\nNo changes\n") {
+		t.Errorf("invalid text diff without changes:\n%s", buf)
+	}
+}
+
+func TestParseDiffArgs(t *testing.T) {
+	opts, err := parseDiffArgs([]string{"--output-format", "json", "--size-change", "50", "a.json", "b.tar.gz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.OutputFormat != "json" || opts.SizeChange != 50 || opts.A != "a.json" || opts.B != "b.tar.gz" {
+		t.Errorf("invalid options: %+v", opts)
+	}
+	for _, args := range [][]string{
+		{"a.json"},
+		{"a.json", "b.json", "c.json"},
+		{"--output-format", "html", "a.json", "b.json"},
+		{"--size-change", "-1", "a.json", "b.json"},
+	} {
+		if _, err := parseDiffArgs(args); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
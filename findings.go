@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+// severity of a finding. The exit code is the worst severity found.
+type severity int
+
+const (
+	severityOK severity = iota
+	severityWarning
+	severityCritical
+	// severityError means some data could not be collected
+	severityError
+)
+
+var severityNames = []string{"ok", "warning", "critical", "error"}
+
+func (s severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+func (s severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *severity) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	for i, n := range severityNames {
+		if n == name {
+			*s = severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid severity %q", name)
+}
+
+// finding is the result of a check or an error collecting data
+type finding struct {
+	Severity severity `json:"severity"`
+	Section  string   `json:"section"`
+	Host     string   `json:"host"`
+	Message  string   `json:"message"`
+}
+
+// results are the reports of all the deployments and the findings that don't
+// belong to any of them, like seeds that could not be reached
+type results struct {
+	Run      *runInfo
+	Reports  []templateData
+	Findings []finding
+}
+
+// allFindings returns the findings of all the reports plus the ones of no
+// deployment, worst first
+func (r results) allFindings() []finding {
+	findings := append([]finding{}, r.Findings...)
+	for _, td := range r.Reports {
+		findings = append(findings, td.Findings...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity > findings[j].Severity
+	})
+	return findings
+}
+
+// exitCode is the worst severity of all the findings: 0 if there are none,
+// 1 for warnings, 2 for critical findings and 3 if data could not be
+// collected
+func (r results) exitCode() int {
+	worst := severityOK
+	for _, f := range r.allFindings() {
+		if f.Severity > worst {
+			worst = f.Severity
+		}
+	}
+	return int(worst)
+}
+
+// addFinding adds a finding to the report
+func (td *templateData) addFinding(s severity, section, host, format string, args ...interface{}) {
+	td.Findings = append(td.Findings, finding{Severity: s, Section: section, Host: host, Message: fmt.Sprintf(format, args...)})
+}
+
+// healthyMemberStates are the states of members working normally
+var healthyMemberStates = map[string]bool{
+	"PRIMARY":   true,
+	"SECONDARY": true,
+	"ARBITER":   true,
+}
+
+// minOplogWindow is the oplog length below which a member that falls behind
+// for a while needs a full resync
+const minOplogWindow = 24 * time.Hour
+
+// maxConnectionsUsage is the fraction of the available connections in use
+// above which new clients may be refused soon
+const maxConnectionsUsage = 0.8
+
+// checkReport adds the findings of the checks on the sections in the report
+func checkReport(td *templateData) {
+	for _, section := range td.sections() {
+		switch section {
+		case "instances":
+			checkMembers(td)
+		case "hostinfo":
+			checkConnections(td)
+		case "security":
+			checkSecurity(td)
+		case "oplog":
+			checkOplog(td)
+		}
+	}
+}
+
+func checkMembers(td *templateData) {
+	for _, m := range td.ReplicaMembers {
+		if s, message := memberSeverity(m); s != severityOK {
+			td.addFinding(s, "instances", m.Name, "%s", message)
+		}
+	}
+}
+
+// memberSeverity returns how bad the state of a replica set member is and why
+func memberSeverity(m proto.Members) (severity, string) {
+	switch {
+	case m.StateStr == memberTimedOut:
+		return severityCritical, "member did not answer in time"
+	case m.Health == 0:
+		return severityCritical, "member is down: " + m.StateStr
+	case !healthyMemberStates[m.StateStr]:
+		return severityWarning, "member is in state " + m.StateStr
+	}
+	return severityOK, ""
+}
+
+func checkConnections(td *templateData) {
+	c := td.ServerStatus.Connections
+	if c == nil || c.Current+c.Available == 0 {
+		return
+	}
+	usage := float64(c.Current) / float64(c.Current+c.Available)
+	if usage > maxConnectionsUsage {
+		td.addFinding(severityWarning, "hostinfo", td.AnsweredBy, "%.0f%% of the available connections are in use (%d of %d)",
+			usage*100, c.Current, c.Current+c.Available)
+	}
+}
+
+func checkSecurity(td *templateData) {
+	if td.Security == nil {
+		return
+	}
+	if td.Security.Auth == "disabled" {
+		td.addFinding(severityCritical, "security", td.AnsweredBy, "authentication is disabled")
+	}
+	if td.Security.SSL == "disabled" {
+		td.addFinding(severityWarning, "security", td.AnsweredBy, "TLS is disabled")
+	}
+}
+
+func checkOplog(td *templateData) {
+	if td.Oplog == nil {
+		return
+	}
+	window := time.Duration(td.Oplog.TimeDiff) * time.Second
+	if window < minOplogWindow {
+		td.addFinding(severityWarning, "oplog", td.AnsweredBy, "the oplog only covers %s, less than %.0f hours",
+			formatSeconds(td.Oplog.TimeDiff), minOplogWindow.Hours())
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+func TestCheckReport(t *testing.T) {
+	td := testReport()
+	td.ReplicaMembers = append(td.ReplicaMembers,
+		proto.Members{Name: "db2:27017", StateStr: "RECOVERING", Health: 1},
+		proto.Members{Name: "db3:27017", StateStr: "(not reachable/healthy)", Health: 0},
+		timedOutMember("db4:27017"),
+	)
+	td.ReplicaMembers[0].Health = 1
+	td.Security.Auth = "disabled"
+	td.Security.SSL = "requireSSL"
+	td.Oplog = &OplogInfo{TimeDiff: 3600}
+	td.ServerStatus.Connections = &proto.ConnectionStats{Current: 90, Available: 10}
+
+	checkReport(&td)
+
+	var got []string
+	for _, f := range td.Findings {
+		got = append(got, f.Severity.String()+" "+f.Section+" "+f.Host)
+	}
+	expect := []string{
+		"warning instances db2:27017",
+		"critical instances db3:27017",
+		"critical instances db4:27017",
+		"warning hostinfo db1:27017",
+		"critical security db1:27017",
+		"warning oplog db1:27017",
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("invalid findings.\ngot:    %v\nexpect: %v", got, expect)
+	}
+
+	// Sections that are not in the report are not checked
+	td.Findings = nil
+	td.Sections = []string{"oplog"}
+	checkReport(&td)
+	if len(td.Findings) != 1 || td.Findings[0].Section != "oplog" {
+		t.Errorf("only the selected sections must be checked: %v", td.Findings)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	warning := templateData{Findings: []finding{{Severity: severityWarning}}}
+	critical := templateData{Findings: []finding{{Severity: severityCritical}}}
+	tests := []struct {
+		res    results
+		expect int
+	}{
+		{results{}, 0},
+		{results{Reports: []templateData{testReport()}}, 0},
+		{results{Reports: []templateData{warning}}, 1},
+		{results{Reports: []templateData{warning, critical}}, 2},
+		{results{Reports: []templateData{warning}, Findings: []finding{{Severity: severityError}}}, 3},
+	}
+	for i, test := range tests {
+		if code := test.res.exitCode(); code != test.expect {
+			t.Errorf("%d: invalid exit code %d, expect %d", i, code, test.expect)
+		}
+	}
+}
+
+func TestFindingsOutput(t *testing.T) {
+	td := testReport()
+	td.addFinding(severityWarning, "security", "db1:27017", "TLS is disabled")
+	res := results{
+		Reports:  []templateData{td},
+		Findings: []finding{{Severity: severityError, Section: "connection", Host: "down:27017", Message: "cannot connect"}},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := renderers["json"].Render(buf, res); err != nil {
+		t.Fatalf("cannot write the JSON report: %s", err)
+	}
+	var doc struct {
+		Deployments []struct {
+			Findings []finding `json:"findings"`
+		} `json:"deployments"`
+		Findings []finding `json:"findings"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+	// All the findings, worst first
+	expect := []finding{res.Findings[0], td.Findings[0]}
+	if !reflect.DeepEqual(doc.Findings, expect) {
+		t.Errorf("invalid findings.\ngot:    %v\nexpect: %v", doc.Findings, expect)
+	}
+	if len(doc.Deployments) != 1 || !reflect.DeepEqual(doc.Deployments[0].Findings, td.Findings) {
+		t.Errorf("invalid deployment findings: %v", doc.Deployments)
+	}
+	if !strings.Contains(buf.String(), `"severity": "warning"`) {
+		t.Errorf("severities must be written by name:\n%s", buf)
+	}
+
+	buf.Reset()
+	if err := renderers["text"].Render(buf, res); err != nil {
+		t.Fatalf("cannot write the text report: %s", err)
+	}
+	for _, want := range []string{
+		"\nwarning   security     db1:27017 TLS is disabled\n",
+		"\nerror     connection   down:27017 cannot connect\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, buf)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+// templateFuncs are the functions available in all the built-in templates and
+// in the ones given with --template and --template-dir, besides the standard
+// text/template functions:
+//
+//	join LIST SEP        joins a list of strings with SEP
+//	upper STRING         STRING in upper case
+//	lower STRING         STRING in lower case
+//	repeat STRING N      STRING repeated N times
+//	default DEF VALUE    VALUE, or DEF if VALUE is empty
+//	json VALUE           VALUE as indented JSON
+//	bytes N              N bytes in a human readable unit: 1.50 GB
+//	megabytes N          N megabytes in a human readable unit
+//	duration N           N seconds, or a time.Duration, as 2 days, 3 hours, 4 minutes, 5 seconds
+//	time TIME            TIME as 2006-01-02 15:04:05 -0700 MST, or - if it is not set
+//	since TIME           the time elapsed since TIME: 3 days ago
+//	percent PART TOTAL   PART as a percentage of TOTAL: 12.50%
+//	replState N          the name of a replica set member state: PRIMARY
+//	padLeft WIDTH VALUE  VALUE right aligned in WIDTH columns
+//	padRight WIDTH VALUE VALUE left aligned in WIDTH columns
+//	colWidth HEADER LIST FIELD
+//	                     the width of a column: the longest of HEADER and FIELD in every LIST element
+//	title NAME           a section title as wide as the report: # NAME ####
+//	color SEVERITY TEXT  TEXT in the color of SEVERITY, if colors are enabled
+//	memberSeverity MEMBER
+//	                     the severity of the state of a replica set member
+//
+// Numeric arguments can be of any integer or float type.
+var templateFuncs = template.FuncMap{
+	"join":      strings.Join,
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"repeat":    strings.Repeat,
+	"default":   defaultValue,
+	"json":      toJSON,
+	"bytes":     humanBytes,
+	"megabytes": humanMegabytes,
+	"duration":  humanDuration,
+	"time":      formatTime,
+	"since":     since,
+	"percent":   percent,
+	"replState": replState,
+	"padLeft":   padLeft,
+	"padRight":  padRight,
+	"colWidth":  colWidth,
+	"title":     textStyle{}.title,
+	"color":     textStyle{}.colorize,
+	"memberSeverity": func(m proto.Members) severity {
+		s, _ := memberSeverity(m)
+		return s
+	},
+}
+
+// withTemplateFuncs returns templateFuncs plus the given functions, for
+// templates that need some of their own
+func withTemplateFuncs(funcs map[string]interface{}) map[string]interface{} {
+	all := make(map[string]interface{})
+	for name, f := range templateFuncs {
+		all[name] = f
+	}
+	for name, f := range funcs {
+		all[name] = f
+	}
+	return all
+}
+
+// timeNow is replaced in tests
+var timeNow = time.Now
+
+// timeLayout is the format of times in reports
+const timeLayout = "2006-01-02 15:04:05 -0700 MST"
+
+// replStates are the names of the replica set member states
+var replStates = map[int64]string{
+	0:  "STARTUP",
+	1:  "PRIMARY",
+	2:  "SECONDARY",
+	3:  "RECOVERING",
+	5:  "STARTUP2",
+	6:  "UNKNOWN",
+	7:  "ARBITER",
+	8:  "DOWN",
+	9:  "ROLLBACK",
+	10: "REMOVED",
+}
+
+// defaultValue returns value unless it is nil or the zero value of its type
+func defaultValue(def, value interface{}) interface{} {
+	if value == nil {
+		return def
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return def
+		}
+	default:
+		if v.IsZero() {
+			return def
+		}
+	}
+	return value
+}
+
+func toJSON(value interface{}) (string, error) {
+	buf, err := json.MarshalIndent(value, "", "    ")
+	return string(buf), err
+}
+
+// toFloat converts any integer or float to float64
+func toFloat(value interface{}) (float64, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	}
+	return 0, fmt.Errorf("%v (%T) is not a number", value, value)
+}
+
+func humanBytes(value interface{}) (string, error) {
+	n, err := toFloat(value)
+	if err != nil {
+		return "", err
+	}
+	units := []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	i := 0
+	for ; n >= 1024 && i < len(units)-1; i++ {
+		n /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f B", n), nil
+	}
+	return fmt.Sprintf("%.2f %s", n, units[i]), nil
+}
+
+func humanMegabytes(value interface{}) (string, error) {
+	n, err := toFloat(value)
+	if err != nil {
+		return "", err
+	}
+	return humanBytes(n * 1024 * 1024)
+}
+
+func humanDuration(value interface{}) (string, error) {
+	var seconds int64
+	if d, ok := value.(time.Duration); ok {
+		seconds = int64(d / time.Second)
+	} else {
+		n, err := toFloat(value)
+		if err != nil {
+			return "", err
+		}
+		seconds = int64(n)
+	}
+	return formatSeconds(seconds), nil
+}
+
+// formatSeconds formats a number of seconds as days, hours, minutes and
+// seconds, leaving out the leading units that are zero
+func formatSeconds(seconds int64) string {
+	if seconds < 0 {
+		seconds = -seconds
+	}
+	units := []struct {
+		name    string
+		seconds int64
+	}{
+		{"day", 86400},
+		{"hour", 3600},
+		{"minute", 60},
+		{"second", 1},
+	}
+	var parts []string
+	for _, unit := range units {
+		n := seconds / unit.seconds
+		seconds %= unit.seconds
+		if n == 0 && len(parts) == 0 && unit.seconds > 1 {
+			continue
+		}
+		parts = append(parts, plural(n, unit.name))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func plural(n int64, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(timeLayout)
+}
+
+// since returns the time elapsed since t, rounded to its largest unit
+func since(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := timeNow().Sub(t)
+	suffix := "ago"
+	if d < 0 {
+		d, suffix = -d, "from now"
+	}
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int64(d/time.Minute), "minute") + " " + suffix
+	case d < 24*time.Hour:
+		return plural(int64(d/time.Hour), "hour") + " " + suffix
+	}
+	return plural(int64(d/(24*time.Hour)), "day") + " " + suffix
+}
+
+func percent(part, total interface{}) (string, error) {
+	p, err := toFloat(part)
+	if err != nil {
+		return "", err
+	}
+	t, err := toFloat(total)
+	if err != nil {
+		return "", err
+	}
+	if t == 0 {
+		return "-", nil
+	}
+	return fmt.Sprintf("%.2f%%", p*100/t), nil
+}
+
+func replState(value interface{}) (string, error) {
+	n, err := toFloat(value)
+	if err != nil {
+		return "", err
+	}
+	if name, ok := replStates[int64(n)]; ok {
+		return name, nil
+	}
+	return fmt.Sprintf("UNKNOWN (%v)", value), nil
+}
+
+func padLeft(width int, value interface{}) string {
+	return fmt.Sprintf("%*v", width, value)
+}
+
+func padRight(width int, value interface{}) string {
+	return fmt.Sprintf("%-*v", width, value)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	now := time.Date(2017, 3, 28, 15, 35, 56, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	tests := []struct {
+		text   string
+		data   interface{}
+		expect string
+	}{
+		{`{{bytes .}}`, int64(512), "512 B"},
+		{`{{bytes .}}`, 1536, "1.50 KB"},
+		{`{{bytes .}}`, float64(3 << 30), "3.00 GB"},
+		{`{{megabytes .}}`, int64(1024), "1.00 GB"},
+		{`{{duration .}}`, int64(0), "0 seconds"},
+		{`{{duration .}}`, int64(3725), "1 hour, 2 minutes, 5 seconds"},
+		{`{{duration .}}`, float64(2*86400 + 60), "2 days, 0 hours, 1 minute, 0 seconds"},
+		{`{{duration .}}`, 90 * time.Second, "1 minute, 30 seconds"},
+		{`{{time .}}`, now, "2017-03-28 15:35:56 +0000 UTC"},
+		{`{{time .}}`, time.Time{}, "-"},
+		{`{{since .}}`, now.Add(-4 * time.Hour), "4 hours ago"},
+		{`{{since .}}`, now.Add(-49 * time.Hour), "2 days ago"},
+		{`{{since .}}`, now.Add(10 * time.Minute), "10 minutes from now"},
+		{`{{percent 1 8}}`, nil, "12.50%"},
+		{`{{percent 1 0}}`, nil, "-"},
+		{`{{replState .}}`, float64(1), "PRIMARY"},
+		{`{{replState .}}`, int64(7), "ARBITER"},
+		{`{{replState .}}`, 4, "UNKNOWN (4)"},
+		{`[{{padLeft 5 .}}]`, 42, "[   42]"},
+		{`[{{padRight 5 .}}]`, "ab", "[ab   ]"},
+		{`{{default "-" .}}`, "", "-"},
+		{`{{default "-" .}}`, []string{}, "-"},
+		{`{{default "-" .}}`, "x", "x"},
+	}
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		tmpl := template.Must(template.New("").Funcs(templateFuncs).Parse(test.text))
+		if err := tmpl.Execute(buf, test.data); err != nil {
+			t.Errorf("%s with %v: %s", test.text, test.data, err)
+			continue
+		}
+		if buf.String() != test.expect {
+			t.Errorf("%s with %v: got %q, expect %q", test.text, test.data, buf, test.expect)
+		}
+	}
+
+	tmpl := template.Must(template.New("").Funcs(templateFuncs).Parse(`{{bytes .}}`))
+	if err := tmpl.Execute(&bytes.Buffer{}, "12"); err == nil {
+		t.Errorf("expected error for a value that is not a number")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+	"github.com/pkg/errors"
+)
+
+// historyFormatVersion is the version of the points in --history files. Like
+// reportSchemaVersion, it only changes when fields are removed or change
+// their meaning.
+const historyFormatVersion = 1
+
+// historyPoint are the key metrics of a deployment in a run. A --history file
+// has a point per line, as JSON, appended by each run.
+type historyPoint struct {
+	FormatVersion int `json:"formatVersion"`
+	// Time is the local time of the server that answered, so points
+	// written from old bundles are in their place
+	Time       time.Time `json:"time"`
+	Deployment string    `json:"deployment"`
+	AnsweredBy string    `json:"answeredBy"`
+	// DatabaseSizes are the sizes on disk by database, in bytes. They are
+	// only collected with the collections section.
+	DatabaseSizes map[string]int64 `json:"databaseSizes,omitempty"`
+	// OplogWindow is the time covered by the oplog, in seconds
+	OplogWindow int64                  `json:"oplogWindow,omitempty"`
+	Connections *proto.ConnectionStats `json:"connections,omitempty"`
+	// Uptime and Opcounters are the uptime of the server, in seconds, and the
+	// operations run since it started. The rates are computed between points.
+	Uptime     int64               `json:"uptime"`
+	Opcounters *proto.OpcountStats `json:"opcounters,omitempty"`
+	// CacheFill is the percentage of the WiredTiger cache in use
+	CacheFill *float64 `json:"cacheFill,omitempty"`
+	// MemberStates are the states of the replica set members by name
+	MemberStates map[string]string `json:"memberStates,omitempty"`
+}
+
+// historyPoints returns the points of the reports in res
+func historyPoints(res results) []historyPoint {
+	var points []historyPoint
+	for _, td := range res.Reports {
+		p := historyPoint{
+			FormatVersion: historyFormatVersion,
+			Time:          td.ServerStatus.LocalTime.UTC(),
+			Deployment:    deploymentKey(td),
+			AnsweredBy:    td.AnsweredBy,
+			Connections:   td.ServerStatus.Connections,
+			Uptime:        td.ServerStatus.Uptime,
+			Opcounters:    td.ServerStatus.Opcounters,
+		}
+		if td.ServerStatus.LocalTime.IsZero() && res.Run != nil {
+			p.Time = res.Run.StartTime
+		}
+		if td.Databases != nil {
+			p.DatabaseSizes = make(map[string]int64)
+			for _, database := range td.Databases {
+				p.DatabaseSizes[database.Name] = database.SizeOnDisk
+			}
+		}
+		if td.Oplog != nil {
+			p.OplogWindow = td.Oplog.TimeDiff
+		}
+		if wt := td.ServerStatus.WiredTiger; wt != nil && wt.Cache.MaxBytesConfigured > 0 {
+			fill := float64(wt.Cache.CurrentCachedBytes) / float64(wt.Cache.MaxBytesConfigured) * 100
+			p.CacheFill = &fill
+		}
+		if len(td.ReplicaMembers) > 0 {
+			p.MemberStates = make(map[string]string)
+			for _, m := range td.ReplicaMembers {
+				p.MemberStates[m.Name] = m.StateStr
+			}
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// appendHistory appends points to the history file, creating it if it
+// doesn't exist
+func appendHistory(filename string, points []historyPoint) error {
+	var buf bytes.Buffer
+	for _, p := range points {
+		line, err := json.Marshal(p)
+		if err != nil {
+			return errors.Wrap(err, "cannot write the history")
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrap(err, "cannot open the history")
+	}
+	// A single write, so runs writing the file at the same time don't mix
+	// their lines
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return errors.Wrap(err, "cannot write the history")
+	}
+	return errors.Wrap(f.Close(), "cannot write the history")
+}
+
+// readHistory returns the points of a history file, in the order they were
+// written
+func readHistory(filename string) ([]historyPoint, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open the history")
+	}
+	defer f.Close()
+
+	var points []historyPoint
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var p historyPoint
+		if err := json.Unmarshal(line, &p); err != nil {
+			return nil, errors.Wrapf(err, "invalid history line %d", n)
+		}
+		if p.FormatVersion > historyFormatVersion {
+			return nil, fmt.Errorf("unsupported history format version %d in line %d", p.FormatVersion, n)
+		}
+		points = append(points, p)
+	}
+	return points, errors.Wrap(scanner.Err(), "cannot read the history")
+}
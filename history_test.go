@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+func TestHistory(t *testing.T) {
+	td := testReport()
+	td.ServerStatus.LocalTime = time.Date(2017, 3, 28, 15, 35, 56, 0, time.FixedZone("CEST", 2*3600))
+	td.ServerStatus.Uptime = 3600
+	td.ServerStatus.Connections = &proto.ConnectionStats{Current: 10, Available: 90}
+	td.ServerStatus.WiredTiger = &proto.WiredTiger{Cache: proto.CacheStats{CurrentCachedBytes: 300, MaxBytesConfigured: 1000}}
+	td.Oplog = &OplogInfo{TimeDiff: 7200}
+	td.Databases = []proto.Database{{Name: "shop", SizeOnDisk: 1 << 30}}
+	// Without databases, as when the collections section is skipped
+	other := testReport()
+	other.Seeds = []string{"db2:27017"}
+	start := time.Date(2017, 3, 28, 13, 40, 0, 0, time.UTC)
+
+	points := historyPoints(results{Run: &runInfo{StartTime: start}, Reports: []templateData{td, other}})
+	if len(points) != 2 {
+		t.Fatalf("expected a point per report, got %d", len(points))
+	}
+	p := points[0]
+	if !p.Time.Equal(td.ServerStatus.LocalTime) || p.Time.Location() != time.UTC || p.Deployment != "db1:27017" {
+		t.Errorf("invalid point %+v", p)
+	}
+	if p.OplogWindow != 7200 || p.Uptime != 3600 || *p.CacheFill != 30 || p.Connections.Current != 10 {
+		t.Errorf("invalid metrics %+v", p)
+	}
+	if !reflect.DeepEqual(p.DatabaseSizes, map[string]int64{"shop": 1 << 30}) || !reflect.DeepEqual(p.MemberStates, map[string]string{"db1:27017": "PRIMARY"}) {
+		t.Errorf("invalid sizes or states %+v", p)
+	}
+	if !points[1].Time.Equal(start) || points[1].DatabaseSizes != nil || points[1].CacheFill != nil {
+		t.Errorf("invalid point without server time and databases %+v", points[1])
+	}
+
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "history.jsonl")
+	if err := appendHistory(file, points[:1]); err != nil {
+		t.Fatalf("cannot write the history: %s", err)
+	}
+	if err := appendHistory(file, points[1:]); err != nil {
+		t.Fatalf("cannot append to the history: %s", err)
+	}
+	got, err := readHistory(file)
+	if err != nil {
+		t.Fatalf("cannot read the history: %s", err)
+	}
+	if !reflect.DeepEqual(got, points) {
+		t.Errorf("invalid history.\ngot:    %+v\nexpect: %+v", got, points)
+	}
+
+	ioutil.WriteFile(file, []byte(`{"formatVersion": 1}`+"\n"+`{"formatVersion": 99}`+"\n"), 0600)
+	if _, err := readHistory(file); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error for the unsupported version in line 2, got %v", err)
+	}
+}
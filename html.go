@@ -0,0 +1,36 @@
+package main
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+	"github.com/percona/pt-mongodb-summary/templates"
+)
+
+var htmlFuncs = withTemplateFuncs(template.FuncMap{
+	// memberWarning returns true for members that are down or in a
+	// transitional or error state
+	"memberWarning": func(m proto.Members) bool {
+		s, _ := memberSeverity(m)
+		return s != severityOK
+	},
+	// findingWarning returns true for the findings worse than ok
+	"findingWarning": func(f finding) bool {
+		return f.Severity != severityOK
+	},
+	// sections returns the sections of a report, in order
+	"sections": func(td templateData) []string {
+		return td.sections()
+	},
+})
+
+var htmlTemplate = template.Must(template.New("html").Funcs(htmlFuncs).Parse(templates.HTML))
+
+// htmlRenderer writes one self-contained page, with embedded styles and
+// scripts, with the reports of all the deployments
+type htmlRenderer struct{}
+
+func (htmlRenderer) Render(w io.Writer, r results) error {
+	return htmlTemplate.Execute(w, r)
+}
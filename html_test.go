@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+func TestHTMLOutput(t *testing.T) {
+	td := testReport()
+	td.ReplicaMembers = append(td.ReplicaMembers,
+		proto.Members{Id: 1, Name: "db2:27017", StateStr: "(not reachable/healthy)", Health: 0, Set: "rs0"},
+		proto.Members{Id: 2, Name: "<script>alert(1)</script>", StateStr: "SECONDARY", Health: 1, Set: "rs0"},
+	)
+	td.ReplicaMembers[0].Health = 1
+	td.Security.Auth = "disabled"
+	td.Databases = []proto.Database{
+		{Name: "app", Collections: []proto.CollStats{{Name: "users", Count: 42, Size: 4096}}},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := renderers["html"].Render(buf, results{Reports: []templateData{td}}); err != nil {
+		t.Fatalf("cannot write the HTML report: %s", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		"<style>",
+		`<tr><td class="num">0</td><td>db1:27017</td><td>PRIMARY</td>`,
+		`<tr class="warn"><td class="num">1</td><td>db2:27017</td>`,
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		`<th>Auth</th><td class="warn">disabled</td>`,
+		`<tr><td>app</td><td>users</td><td class="num">42</td><td class="num" data-value="4096">4.00 KB</td>`,
+		`<table class="sortable">`,
+		"<summary>Oplog</summary>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in the HTML report", want)
+		}
+	}
+	// Everything must be embedded
+	for _, external := range []string{"<link", "src=", "@import", "url("} {
+		if strings.Contains(got, external) {
+			t.Errorf("the HTML report must not load external resources: found %q", external)
+		}
+	}
+}
@@ -1,10 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"html/template"
 	"log"
 	"os"
 	"strings"
@@ -15,45 +15,96 @@ import (
 
 	"github.com/percona/pt-mongodb-summary/db"
 	"github.com/percona/pt-mongodb-summary/proto"
-	"github.com/percona/pt-mongodb-summary/templates"
 	"github.com/pkg/errors"
 	"github.com/shirou/gopsutil/process"
 )
 
 type options struct {
-	Host     string
-	User     string
-	Password string
-	Debug    bool
+	Hosts         []string
+	User          string
+	Password      string
+	AuthDB        string
+	AuthMechanism string
+	Direct        bool
+	Proxy         string
+	OutputFormat  string
+	TemplateDir   string
+	Template      string
+	Sections      string
+	SkipSections  string
+	NoColor       bool
+	Width         int
+	SaveDir       string
+	Bundle        string
+	FromBundle    string
+	Anonymize     bool
+	History       string
+	Debug         bool
+
+	ConnectTimeout time.Duration
+	CommandTimeout time.Duration
+	MaxRuntime     time.Duration
+
+	TLS                           bool
+	TLSCAFile                     string
+	TLSCertificateKeyFile         string
+	TLSCertificateKeyFilePassword string
+	TLSAllowInvalidHostnames      bool
+	TLSAllowInvalidCertificates   bool
+}
+
+func (o options) dialOptions() db.DialOptions {
+	return db.DialOptions{
+		Username:      o.User,
+		Password:      o.Password,
+		AuthDB:        o.AuthDB,
+		AuthMechanism: o.AuthMechanism,
+		Direct:        o.Direct,
+		Proxy:         o.Proxy,
+
+		ConnectTimeout: o.ConnectTimeout,
+		CommandTimeout: o.CommandTimeout,
+
+		TLS:                           o.TLS,
+		TLSCAFile:                     o.TLSCAFile,
+		TLSCertificateKeyFile:         o.TLSCertificateKeyFile,
+		TLSCertificateKeyFilePassword: o.TLSCertificateKeyFilePassword,
+		TLSAllowInvalidHostnames:      o.TLSAllowInvalidHostnames,
+		TLSAllowInvalidCertificates:   o.TLSAllowInvalidCertificates,
+	}
 }
 
 type procInfo struct {
-	CreateTime time.Time
-	Path       string
-	UserName   string
+	CreateTime time.Time `json:"createTime"`
+	Path       string    `json:"path"`
+	UserName   string    `json:"userName"`
 }
 
 type security struct {
-	Users int
-	Roles int
-	Auth  string
-	SSL   string
+	Users int    `json:"users"`
+	Roles int    `json:"roles"`
+	Auth  string `json:"auth"`
+	SSL   string `json:"ssl"`
+	// Authentication used by this tool
+	AuthMechanism string   `json:"authMechanism"`
+	AuthUser      string   `json:"authUser"`
+	AuthUserRoles []string `json:"authUserRoles"`
 }
 
 type timedStats struct {
-	Min   int64
-	Max   int64
-	Total int64
-	Avg   int64
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max"`
+	Total int64 `json:"total"`
+	Avg   int64 `json:"avg"`
 }
 
 type opCounters struct {
-	Insert  timedStats
-	Query   timedStats
-	Update  timedStats
-	Delete  timedStats
-	GetMore timedStats
-	Command timedStats
+	Insert  timedStats `json:"insert"`
+	Query   timedStats `json:"query"`
+	Update  timedStats `json:"update"`
+	Delete  timedStats `json:"delete"`
+	GetMore timedStats `json:"getMore"`
+	Command timedStats `json:"command"`
 }
 
 type databases struct {
@@ -69,19 +120,45 @@ type databases struct {
 }
 
 type templateData struct {
-	BuildInfo          mgo.BuildInfo
-	CommandLineOptions proto.CommandLineOptions
-	HostInfo           proto.HostInfo
-	ServerStatus       proto.ServerStatus
-	ReplicaSetStatus   proto.ReplicaSetStatus
-	NodeType           string
-	ProcInfo           procInfo
-	ThisHostID         int64
-	ProcessCount       int64
-	Security           *security
-	RunningOps         opCounters
-	SampleRate         time.Duration
-	ReplicaMembers     []proto.Members
+	BuildInfo          proto.BuildInfo          `json:"buildInfo"`
+	CommandLineOptions proto.CommandLineOptions `json:"commandLineOptions"`
+	HostInfo           proto.HostInfo           `json:"hostInfo"`
+	ServerStatus       proto.ServerStatus       `json:"serverStatus"`
+	ReplicaSetStatus   proto.ReplicaSetStatus   `json:"replicaSetStatus"`
+	NodeType           string                   `json:"nodeType"`
+	ProcInfo           procInfo                 `json:"procInfo"`
+	ThisHostID         int64                    `json:"thisHostID"`
+	ProcessCount       int64                    `json:"processCount"`
+	Security           *security                `json:"security"`
+	RunningOps         opCounters               `json:"runningOps"`
+	SampleRate         time.Duration            `json:"sampleRate"` // nanoseconds in JSON
+	ReplicaMembers     []proto.Members          `json:"replicaMembers"`
+	// Findings are the results of the checks and the errors collecting data
+	Findings []finding `json:"findings"`
+	// Direct is true if all commands were pinned to the seed host
+	Direct bool `json:"direct"`
+	// AnsweredBy is the host:port of the server that answered the commands
+	AnsweredBy string `json:"answeredBy"`
+	// Seeds are the --hosts entries that belong to this deployment
+	Seeds []string `json:"seeds"`
+	// DeploymentID identifies the deployment by its cluster id, replica set
+	// or host. It is empty in the reports of older versions.
+	DeploymentID string `json:"deploymentId,omitempty"`
+	// Oplog is only set for replica set members
+	Oplog *OplogInfo `json:"oplog"`
+	// Databases is empty if they could not be listed
+	Databases []proto.Database `json:"databases"`
+	// Sections are the sections collected, in the order they are written
+	Sections []string `json:"sections"`
+}
+
+// sections returns the sections to write. Reports without a list have all of
+// them.
+func (td templateData) sections() []string {
+	if td.Sections == nil {
+		return reportSections
+	}
+	return td.Sections
 }
 
 type DB struct {
@@ -113,75 +190,278 @@ type chunk struct {
 
 var Debug = false
 
+// memberTimedOut is the state reported for hosts that didn't answer in time
+const memberTimedOut = "timed out"
+
 func main() {
-	var opts options
-	flag.StringVar(&opts.Host, "hosts", "localhost:27017", "List of host:port to connect to")
-	flag.BoolVar(&opts.Debug, "debug", false, "debug mode")
-	flag.Parse()
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		diffMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "trend" {
+		trendMain(os.Args[2:])
+		return
+	}
 
-	templateData, err := getTemplateData(opts.Host)
+	start := timeNow()
+	opts, err := parseArgs(os.Args[1:], os.Getenv, readPasswordFromTerminal)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+	sections, err := selectSections(opts.Sections, opts.SkipSections)
+	if err != nil {
+		exitWithError(err)
+	}
+	// Invalid templates are reported before connecting to any server
+	r, err := newRenderer(opts, newTextStyle(opts, os.Stdout))
 	if err != nil {
-		panic(err)
+		exitWithError(err)
 	}
 
-	t := template.Must(template.New("replicas").Parse(templates.Replicas))
-	t.Execute(os.Stdout, templateData)
+	seeds, err := getSeeds(opts, db.DefaultResolver)
+	if err != nil {
+		exitWithError(err)
+	}
+	var bundle *db.Bundle
+	if opts.FromBundle != "" {
+		if bundle, err = db.OpenBundle(opts.FromBundle); err != nil {
+			exitWithError(err)
+		}
+		seeds = bundleSeeds(bundle, seeds)
+	}
 
-	t = template.Must(template.New("hosttemplateData").Parse(templates.HostInfo))
-	t.Execute(os.Stdout, templateData)
+	ctx := context.Background()
+	if opts.MaxRuntime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.MaxRuntime)
+		defer cancel()
+	}
 
-	t = template.Must(template.New("runningOps").Parse(templates.RunningOps))
-	t.Execute(os.Stdout, templateData)
+	run := newRunInfo(os.Args, seeds, start)
+	run.Bundle = opts.FromBundle
+	// All the managers, to count the hosts contacted
+	var managers []*db.ConnectionManager
+	var rec *db.Recorder
+	if opts.SaveDir != "" || opts.Bundle != "" {
+		rec = db.NewRecorder()
+	}
 
-	t = template.Must(template.New("ssl").Parse(templates.Security))
-	t.Execute(os.Stdout, templateData)
+	// Seeds that cannot be reached don't stop the reports for the other ones
+	// but they are findings and the exit code tells that something failed
+	deployments, skipped := groupSeeds(seeds, func(s seed) *db.ConnectionManager {
+		factory := connectorFactory(ctx, s.hostname, s.dialOpts)
+		if bundle != nil {
+			factory = bundle.Factory()
+		}
+		if rec != nil {
+			factory = rec.Factory(factory)
+		}
+		cm := db.NewConnectionManager(factory)
+		managers = append(managers, cm)
+		return cm
+	})
+	res := results{Run: run, Findings: skipped}
+
+	for _, d := range deployments {
+		td, err := collectReport(ctx, d, sections, opts)
+		if err != nil {
+			log.Printf("cannot get the summary for %s: %s", strings.Join(d.Seeds, ","), err)
+			res.Findings = append(res.Findings, finding{
+				Severity: severityError,
+				Host:     strings.Join(d.Seeds, ","),
+				Message:  "cannot get the summary: " + err.Error(),
+			})
+			continue
+		}
+		res.Reports = append(res.Reports, td)
+	}
+	run.finish(managers)
 
-	//oplogInfo, err := getOplogInfo(hostnames, db.NewMongoConnector)
-	//if oplogInfo != nil && len(oplogInfo) > 0 {
-	//	t = template.Must(template.New("oplogInfo").Parse(templates.Oplog))
-	//	t.Execute(os.Stdout, oplogInfo[0])
-	//}
+	if opts.History != "" {
+		if err := appendHistory(opts.History, historyPoints(res)); err != nil {
+			log.Printf("cannot save the history: %s", err)
+			res.Findings = append(res.Findings, finding{Severity: severityError, Section: "history", Message: err.Error()})
+		}
+	}
 
-	//doSomething(conn.Session())
+	// The names are learned from everything collected, so they have the
+	// same hash in the report and in the bundle
+	var anon *anonymizer
+	if opts.Anonymize {
+		if anon, err = newAnonymizer(nil); err != nil {
+			exitWithError(err)
+		}
+		anon.learn(res, managers)
+		if rec != nil {
+			rec.Anonymize(anon)
+		}
+	}
+
+	if rec != nil {
+		if err := saveBundle(opts, rec, run); err != nil {
+			log.Printf("cannot save the bundle: %s", err)
+			res.Findings = append(res.Findings, finding{Severity: severityError, Section: "bundle", Message: err.Error()})
+		}
+	}
+
+	if anon != nil {
+		if err := anon.results(&res); err != nil {
+			exitWithError(err)
+		}
+	}
+
+	if err := r.Render(os.Stdout, res); err != nil {
+		exitWithError(err)
+	}
+	os.Exit(res.exitCode())
 }
 
-func getTemplateData(hostname string) (templateData, error) {
-	td := templateData{}
-	hostnames, err := getHostnames(hostname)
+// exitWithError logs err and exits with the exit code of collection errors
+func exitWithError(err error) {
+	log.Print(err)
+	os.Exit(int(severityError))
+}
+
+// collectReport collects the data of a deployment and closes its connections
+func collectReport(ctx context.Context, d *deployment, sections []string, opts options) (templateData, error) {
+	defer d.cm.Close()
+
+	td, err := getTemplateData(ctx, d.cm, d.hostname, d.dialOpts, sections)
 	if err != nil {
 		return templateData{}, err
 	}
+	// The process info is read from the local host, so it is not in bundles
+	if hasSection(sections, "hostinfo") && opts.FromBundle == "" {
+		if err := getProcInfo(int32(td.ServerStatus.Pid), &td.ProcInfo); err != nil {
+			return templateData{}, err
+		}
+	}
+	td.Seeds = d.Seeds
+	td.DeploymentID = d.ID
+	checkReport(&td)
+	if opts.Debug {
+		log.Printf("%d connections opened to %d hosts", d.cm.Dials(), len(d.cm.Hosts()))
+	}
 
-	session, err := mgo.Dial(hostname)
-	if err != nil {
-		return templateData{}, err
+	return td, nil
+}
+
+// connectionSettings returns the hosts and dial options to use for one of the
+// --hosts values. If it is a connection string, its settings are used for
+// everything not given as a command line flag.
+func connectionSettings(opts options, host string, resolver db.Resolver) (string, db.DialOptions, error) {
+	hostname := host
+	dialOpts := opts.dialOptions()
+
+	if db.IsURI(host) {
+		cs, err := db.ParseURI(host, resolver)
+		if err != nil {
+			return "", db.DialOptions{}, errors.Wrap(err, "invalid connection string")
+		}
+		if len(cs.Ignored) > 0 {
+			log.Printf("ignoring connection string options not used by %s: %s", toolName, strings.Join(cs.Ignored, ", "))
+		}
+		hostname = strings.Join(cs.Hosts, ",")
+		dialOpts = cs.Apply(dialOpts)
 	}
-	defer session.Close()
 
-	//
-	td.BuildInfo, err = session.BuildInfo()
+	if err := dialOpts.Validate(); err != nil {
+		return "", db.DialOptions{}, err
+	}
+	if dialOpts.Direct && strings.Contains(hostname, ",") {
+		return "", db.DialOptions{}, fmt.Errorf("direct connections need exactly one host")
+	}
+	return hostname, dialOpts, nil
+}
+
+// connectorFactory returns a factory that uses dialOpts for the seed host and
+// dialOpts.ForDiscoveredHost() for the shards and members found during
+// discovery.
+func connectorFactory(ctx context.Context, seed string, dialOpts db.DialOptions) db.ConnectorFactory {
+	seedFactory := db.NewConnectorFactory(ctx, dialOpts)
+	discoveredFactory := db.NewConnectorFactory(ctx, dialOpts.ForDiscoveredHost())
+	return func(host string) db.MongoConnector {
+		if host == seed {
+			return seedFactory(host)
+		}
+		return discoveredFactory(host)
+	}
+}
+
+// getTemplateData collects the report data for the given sections. Data only
+// used by other sections is not collected. Connections are taken from cm so
+// each host is dialed only once. Collection is aborted when ctx is done.
+func getTemplateData(ctx context.Context, cm *db.ConnectionManager, hostname string, dialOpts db.DialOptions, sections []string) (templateData, error) {
+	td := templateData{
+		Direct:   dialOpts.Direct,
+		Sections: sections,
+	}
+
+	conn, err := cm.Get(hostname)
 	if err != nil {
 		return templateData{}, err
 	}
 
 	//
-	td.NodeType, err = getNodeType(session)
+	bi, err := conn.BuildInfo()
 	if err != nil {
 		return templateData{}, err
 	}
+	td.BuildInfo = buildInfo(bi)
 
 	//
-	td.ReplicaMembers, err = getReplicasetMembers(hostnames)
+	td.NodeType, err = getNodeType(conn)
 	if err != nil {
 		return templateData{}, err
 	}
 
+	if hasSection(sections, "instances") {
+		// In direct mode only the given host is examined
+		hostnames := []string{hostname}
+		if !dialOpts.Direct {
+			hostnames, err = getHostnames(cm, hostname, td.NodeType)
+			if err != nil {
+				return templateData{}, err
+			}
+		}
+		td.ReplicaMembers, err = getReplicasetMembers(cm, hostnames)
+		if err != nil {
+			return templateData{}, err
+		}
+	}
+
+	// The state of the member that answered, for the host info
+	if td.NodeType == "replset" && hasSection(sections, "hostinfo") {
+		td.ReplicaSetStatus, err = conn.ReplicaSetGetStatus()
+		if err != nil {
+			log.Printf("cannot get the replica set status of %s: %s", hostname, err)
+			td.addFinding(severityError, "hostinfo", hostname, "cannot get the replica set status: %s", err)
+		}
+	}
+
+	// The oplog is not essential for the report so errors are not fatal
+	if td.NodeType == "replset" && hasSection(sections, "oplog") {
+		oplogInfo, err := getOplogInfo(cm, []string{hostname})
+		if err != nil {
+			log.Printf("cannot get the oplog info of %s: %s", hostname, err)
+			td.addFinding(severityError, "oplog", hostname, "cannot get the oplog info: %s", err)
+		} else if len(oplogInfo) > 0 {
+			td.Oplog = &oplogInfo[0]
+		}
+	}
+
 	//
-	err = session.DB("admin").Run(bson.D{{"serverStatus", 1}, {"recordStats", 1}}, &td.ServerStatus)
-	write("serverstatus", td.ServerStatus)
+	td.ServerStatus, err = conn.ServerStatus()
 	if err != nil {
 		return templateData{}, err
 	}
+	td.AnsweredBy = td.ServerStatus.Host
+	if td.ServerStatus.Repl != nil && td.ServerStatus.Repl.Me != "" {
+		td.AnsweredBy = td.ServerStatus.Repl.Me
+	}
 
 	// Sample Running Ops
 	//var sampleCount int64 = 5
@@ -191,39 +471,88 @@ func getTemplateData(hostname string) (templateData, error) {
 	//templateData.RunningOps = <-osChan
 
 	//
-	err = session.Run(bson.M{"hostInfo": 1}, &td.HostInfo)
-	write("hostinfo", td.HostInfo)
+	td.HostInfo, err = conn.HostInfo()
 	if err != nil {
 		return templateData{}, err
 	}
 
-	td.Security, err = getSecuritySettings(session)
+	// The command line options only describe the server so errors are not
+	// fatal
+	td.CommandLineOptions, err = conn.GetCmdLineOpts()
+	if err != nil {
+		log.Printf("cannot get the command line options of %s: %s", hostname, err)
+		td.addFinding(severityError, "hostinfo", hostname, "cannot get the command line options: %s", err)
+	}
 
-	//fillMissingInfo(conn, &templateData)
+	// Listing every collection needs more privileges than the rest of the
+	// report so errors are not fatal. It runs a command per collection so
+	// the database and collection counts are only filled when the
+	// collections section is selected.
+	if hasSection(sections, "collections") {
+		td.Databases, err = getDatabases(conn)
+		if err != nil {
+			log.Printf("cannot get the databases of %s: %s", hostname, err)
+			td.addFinding(severityError, "collections", hostname, "cannot get the databases: %s", err)
+		} else {
+			td.HostInfo.DatabasesCount = len(td.Databases)
+			for _, database := range td.Databases {
+				td.HostInfo.CollectionsCount += len(database.Collections)
+			}
+		}
+	}
 
-	err = getProcInfo(int32(td.ServerStatus.Pid), &td.ProcInfo)
-	if err != nil {
-		return templateData{}, err
+	if hasSection(sections, "security") {
+		td.Security, err = getSecuritySettings(conn, dialOpts)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("cannot get the security settings of %s: %s", hostname, err)
+			td.addFinding(severityError, "security", hostname, "cannot get the security settings: %s", err)
+		}
+	}
+	if ctx.Err() != nil {
+		return templateData{}, errors.Wrap(ctx.Err(), "max runtime exceeded")
 	}
 
+	//fillMissingInfo(conn, &templateData)
+
 	return td, nil
 }
 
-func getHostnames(hostname string) ([]string, error) {
+// buildInfo converts the driver build info into the type used in reports
+func buildInfo(bi mgo.BuildInfo) proto.BuildInfo {
+	versionArray := make([]int32, len(bi.VersionArray))
+	for i, v := range bi.VersionArray {
+		versionArray[i] = int32(v)
+	}
+	return proto.BuildInfo{
+		Version:       bi.Version,
+		VersionArray:  versionArray,
+		GitVersion:    bi.GitVersion,
+		SysInfo:       bi.SysInfo,
+		Bits:          int32(bi.Bits),
+		Debug:         bi.Debug,
+		MaxObjectSize: int64(bi.MaxObjectSize),
+	}
+}
 
-	session, err := mgo.Dial(hostname)
+// getHostnames returns the hosts whose replica set status has the members of
+// the deployment: the host itself and, behind a mongos, a host of each shard.
+// Only a mongos can list the shards.
+func getHostnames(cm *db.ConnectionManager, hostname, nodeType string) ([]string, error) {
+	hostnames := []string{hostname}
+	if nodeType != "mongos" {
+		return hostnames, nil
+	}
+
+	conn, err := cm.Get(hostname)
 	if err != nil {
 		return nil, err
 	}
-	defer session.Close()
 
-	shardsInfo := &proto.ShardsInfo{}
-	err = session.Run("listShards", shardsInfo)
+	shardsInfo, err := conn.ListShards()
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot list shards")
+		return nil, err
 	}
 
-	hostnames := []string{hostname}
 	if shardsInfo != nil {
 		for _, shardInfo := range shardsInfo.Shards {
 			m := strings.Split(shardInfo.Host, "/")
@@ -311,18 +640,27 @@ func doSomething(session *mgo.Session) {
 	fmt.Printf("Partitioned: %d, not part: %d\n", partitionedCount, notPartiionedCount)
 }
 
-func getReplicasetMembers(hostnames []string) ([]proto.Members, error) {
+// getReplicasetMembers returns the members of the replica sets the hosts
+// belong to. Hosts that don't answer in time are reported as a member in
+// the "timed out" state.
+func getReplicasetMembers(cm *db.ConnectionManager, hostnames []string) ([]proto.Members, error) {
 	replicaMembers := []proto.Members{}
 
 	for _, hostname := range hostnames {
-		session, err := mgo.Dial(hostname)
+		conn, err := cm.Get(hostname)
+		if db.IsTimeout(err) {
+			replicaMembers = append(replicaMembers, timedOutMember(hostname))
+			continue
+		}
 		if err != nil {
 			return nil, errors.Wrap(err, "cannot get ReplicaSetStatus")
 		}
-		defer session.Close()
 
-		rss := proto.ReplicaSetStatus{}
-		err = session.Run(bson.M{"replSetGetStatus": 1}, &rss)
+		rss, err := conn.ReplicaSetGetStatus()
+		if db.IsTimeout(err) {
+			replicaMembers = append(replicaMembers, timedOutMember(hostname))
+			continue
+		}
 		if err != nil {
 			continue // If a host is a mongos we cannot get info but is not a real error
 		}
@@ -335,35 +673,66 @@ func getReplicasetMembers(hostnames []string) ([]proto.Members, error) {
 	return replicaMembers, nil
 }
 
-func getSecuritySettings(session *mgo.Session) (*security, error) {
+func timedOutMember(hostname string) proto.Members {
+	return proto.Members{
+		Name:     hostname,
+		StateStr: memberTimedOut,
+	}
+}
+
+func getSecuritySettings(conn db.MongoConnector, dialOpts db.DialOptions) (*security, error) {
 	s := security{
-		Auth: "disabled",
-		SSL:  "disabled",
+		Auth:          "disabled",
+		SSL:           "disabled",
+		AuthMechanism: "none",
 	}
 
-	cmdOpts := proto.CommandLineOptions{}
-	err := session.DB("admin").Run(bson.D{{"getCmdLineOpts", 1}, {"recordStats", 1}}, &cmdOpts)
+	cmdOpts, err := conn.GetCmdLineOpts()
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot get command line options")
+		return nil, err
 	}
 
-	if cmdOpts.Security.Authorization != "" || cmdOpts.Security.KeyFile != "" {
+	if cmdOpts.Parsed.Security.Authorization != "" || cmdOpts.Parsed.Security.KeyFile != "" {
 		s.Auth = "enabled"
 	}
-	if cmdOpts.Parsed.Net.SSL.Mode != "" && cmdOpts.Parsed.Net.SSL.Mode != "disabled" {
-		s.SSL = cmdOpts.Parsed.Net.SSL.Mode
+	// Since MongoDB 4.2 the net.ssl options are reported as net.tls
+	tlsMode := cmdOpts.Parsed.Net.TLS.Mode
+	if tlsMode == "" {
+		tlsMode = cmdOpts.Parsed.Net.SSL.Mode
+	}
+	if tlsMode != "" && tlsMode != "disabled" {
+		s.SSL = tlsMode
 	}
 
-	s.Users, err = session.DB("admin").C("system.users").Count()
+	s.Users, err = conn.UsersCount()
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot get users count")
 	}
 
-	s.Roles, err = session.DB("admin").C("system.roles").Count()
+	s.Roles, err = conn.RolesCount()
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot get roles count")
 	}
 
+	if dialOpts.Username != "" || dialOpts.AuthMechanism == db.AuthMechanismX509 {
+		s.AuthMechanism = dialOpts.AuthMechanism
+		if s.AuthMechanism == "" {
+			s.AuthMechanism = "default"
+		}
+	}
+
+	cs := proto.ConnectionStatus{}
+	err = conn.SessionRun("connectionStatus", &cs)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get connection status")
+	}
+	for _, u := range cs.AuthInfo.AuthenticatedUsers {
+		s.AuthUser = u.User + "@" + u.DB
+	}
+	for _, r := range cs.AuthInfo.AuthenticatedUserRoles {
+		s.AuthUserRoles = append(s.AuthUserRoles, r.Role+"@"+r.DB)
+	}
+
 	return &s, nil
 }
 
@@ -373,21 +742,8 @@ func format(title string, templateData interface{}) string {
 	return title + "\n" + string(txt)
 }
 
-func write(title string, templateData interface{}) {
-	//d := os.Getenv("BASEDIR")
-	//if d == "" {
-	//	log.Printf("cannot get BASEDIR env var")
-	//	return
-	//}
-	//txt, _ := json.MarshalIndent(templateData, "", "    ")
-	//f, _ := os.Create("test/sample/" + title + ".json")
-	//f.Write(txt)
-	//f.Close()
-}
-
-func getNodeType(session *mgo.Session) (string, error) {
-	md := proto.MasterDoc{}
-	err := session.Run("isMaster", &md)
+func getNodeType(conn db.MongoConnector) (string, error) {
+	md, err := conn.IsMaster()
 	if err != nil {
 		return "", err
 	}
@@ -534,23 +890,47 @@ func getProcInfo(pid int32, templateData *procInfo) error {
 	return nil
 }
 
-func getDbsAndCollectionsCount(hostnames []string) (int, int, error) {
+// getDatabases returns all the databases with the stats of their collections
+func getDatabases(conn db.MongoConnector) ([]proto.Database, error) {
+	var dbs proto.Databases
+	if err := conn.SessionRun(bson.M{"listDatabases": 1}, &dbs); err != nil {
+		return nil, errors.Wrap(err, "cannot list databases")
+	}
+
+	for i, database := range dbs.Databases {
+		cols, err := conn.CollectionNames(database.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range cols {
+			stats := proto.CollStats{}
+			if err := conn.DbRun(database.Name, bson.D{{"collStats", col}}, &stats); err != nil {
+				return nil, errors.Wrapf(err, "cannot get the stats of %s.%s", database.Name, col)
+			}
+			stats.Name = col
+			dbs.Databases[i].Collections = append(dbs.Databases[i].Collections, stats)
+		}
+	}
+	return dbs.Databases, nil
+}
+
+func getDbsAndCollectionsCount(cm *db.ConnectionManager, hostnames []string) (int, int, error) {
 	dbnames := make(map[string]bool)
 	colnames := make(map[string]bool)
 
 	for _, hostname := range hostnames {
-		session, err := mgo.Dial(hostname)
+		conn, err := cm.Get(hostname)
 		if err != nil {
 			continue
 		}
-		dbs, err := session.DatabaseNames()
+		dbs, err := conn.DatabaseNames()
 		if err != nil {
 			continue
 		}
 
 		for _, dbname := range dbs {
 			dbnames[dbname] = true
-			cols, err := session.DB(dbname).CollectionNames()
+			cols, err := conn.CollectionNames(dbname)
 			if err != nil {
 				continue
 			}
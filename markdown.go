@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/percona/pt-mongodb-summary/templates"
+)
+
+var markdownCellReplacer = strings.NewReplacer("|", `\|`, "\r", "", "\n", " ")
+
+var markdownFuncs = withTemplateFuncs(template.FuncMap{
+	// cell formats a value for a table cell
+	"cell": func(v interface{}) string {
+		return markdownCellReplacer.Replace(fmt.Sprint(v))
+	},
+})
+
+// markdownSections are the templates of the run, the header, each report
+// section and the findings
+var markdownSections = func() map[string]*template.Template {
+	sections := make(map[string]*template.Template)
+	for name, text := range map[string]string{
+		"run":         templates.MarkdownRun,
+		"header":      templates.MarkdownHeader,
+		"instances":   templates.MarkdownReplicas,
+		"hostinfo":    templates.MarkdownHostInfo,
+		"collections": templates.MarkdownCollections,
+		"runningops":  templates.MarkdownRunningOps,
+		"security":    templates.MarkdownSecurity,
+		"oplog":       templates.MarkdownOplog,
+		"findings":    templates.MarkdownFindings,
+		"other":       templates.MarkdownOtherFindings,
+	} {
+		sections[name] = template.Must(template.New(name).Funcs(markdownFuncs).Parse(text))
+	}
+	return sections
+}()
+
+// markdownRenderer writes each section as a Markdown heading followed by a
+// table, ready to be pasted into tickets
+type markdownRenderer struct{}
+
+func (markdownRenderer) Render(w io.Writer, r results) error {
+	if r.Run != nil {
+		if err := markdownSections["run"].Execute(w, r.Run); err != nil {
+			return err
+		}
+	}
+	for i, td := range r.Reports {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if err := markdownSections["header"].Execute(w, td); err != nil {
+			return err
+		}
+		for _, name := range td.sections() {
+			if err := markdownSections[name].Execute(w, td); err != nil {
+				return err
+			}
+		}
+		if err := markdownSections["findings"].Execute(w, td); err != nil {
+			return err
+		}
+	}
+	// Findings that don't belong to any report, like unreachable seeds
+	return markdownSections["other"].Execute(w, templateData{Findings: r.Findings})
+}
@@ -12,16 +12,16 @@ import (
 )
 
 type OplogInfo struct {
-	Hostname      string
-	Size          int64
-	UsedMB        int64
-	TimeDiff      int64
-	TimeDiffHours float64
-	Running       string // TimeDiffHours in human readable format
-	TFirst        time.Time
-	TLast         time.Time
-	Now           time.Time
-	ElectionTime  time.Time
+	Hostname      string    `json:"hostname"`
+	Size          int64     `json:"size"` // MB
+	UsedMB        int64     `json:"usedMB"`
+	TimeDiff      int64     `json:"timeDiff"` // seconds
+	TimeDiffHours float64   `json:"timeDiffHours"`
+	Running       string    `json:"running"` // TimeDiffHours in human readable format
+	TFirst        time.Time `json:"tFirst"`
+	TLast         time.Time `json:"tLast"`
+	Now           time.Time `json:"now"`
+	ElectionTime  time.Time `json:"electionTime"`
 }
 
 type OpLogs []OplogInfo
@@ -69,7 +69,7 @@ type ColStats struct {
 	Count          int64
 }
 
-func getOplogInfo(hostnames []string, newMongoConnector db.ConnectorFactory) ([]OplogInfo, error) {
+func getOplogInfo(cm *db.ConnectionManager, hostnames []string) ([]OplogInfo, error) {
 
 	results := OpLogs{}
 
@@ -77,30 +77,24 @@ func getOplogInfo(hostnames []string, newMongoConnector db.ConnectorFactory) ([]
 		result := OplogInfo{
 			Hostname: hostname,
 		}
-		conn := newMongoConnector(hostname)
-		err := conn.Connect()
+		conn, err := cm.Get(hostname)
 		if err != nil {
 			return nil, errors.Wrapf(err, "cannot connect to %s", hostname)
 		}
-		defer conn.Close()
 
 		oplogCol, err := conn.GetOplogCollection()
 		if err != nil {
 			return nil, err
 		}
 
-		olEntry, err := conn.GetOplogEntry(oplogCol)
-		if err != nil {
-			return nil, errors.Wrap(err, "getOplogInfo -> GetOplogEntry")
-		}
-		result.Size = olEntry.Options.Size / (1024 * 1024)
-
 		var colStats ColStats
 		err = conn.DbRun("local", bson.M{"collStats": oplogCol}, &colStats)
 		if err != nil {
 			return nil, errors.Wrapf(err, "cannot get collStats for collection %s", oplogCol)
 		}
 
+		// maxSize is the configured size of the capped collection with every storage engine
+		result.Size = colStats.MaxSize / (1024 * 1024)
 		result.UsedMB = colStats.Size / (1024 * 1024)
 
 		var firstRow, lastRow OplogRow
@@ -124,7 +118,7 @@ func getOplogInfo(hostnames []string, newMongoConnector db.ConnectorFactory) ([]
 		result.TLast = time.Unix(tlast, 0)
 		result.Now = time.Now().UTC()
 		if result.TimeDiffHours > 24 {
-			result.Running = fmt.Sprintf("%d days", result.TimeDiffHours/24)
+			result.Running = fmt.Sprintf("%0.2f days", result.TimeDiffHours/24)
 		} else {
 			result.Running = fmt.Sprintf("%0.2f hours", result.TimeDiffHours)
 		}
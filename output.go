@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/percona/pt-mongodb-summary/templates"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// reportSchemaVersion is the version of the JSON and YAML output. It must be
+// increased when fields are renamed, removed or change their meaning. Adding
+// fields doesn't change the version.
+const reportSchemaVersion = 1
+
+// renderer writes the reports of all the deployments in one output format
+type renderer interface {
+	Render(w io.Writer, r results) error
+}
+
+var renderers = map[string]renderer{
+	"text":       textRenderer{},
+	"json":       jsonRenderer{},
+	"yaml":       yamlRenderer{},
+	"markdown":   markdownRenderer{},
+	"html":       htmlRenderer{},
+	"prometheus": prometheusRenderer{},
+}
+
+// outputFormats are the renderer names, in the order shown in the help
+var outputFormats = []string{"text", "json", "yaml", "markdown", "html", "prometheus"}
+
+// jsonReport is the document written by --output-format=json and yaml.
+// Findings has the findings of all the deployments, worst first, plus the ones
+// that don't belong to any deployment.
+type jsonReport struct {
+	SchemaVersion int            `json:"schemaVersion"`
+	Run           *runInfo       `json:"run"`
+	Deployments   []templateData `json:"deployments"`
+	Findings      []finding      `json:"findings"`
+}
+
+func newJSONReport(r results) jsonReport {
+	reports := r.Reports
+	if reports == nil {
+		reports = []templateData{}
+	}
+	return jsonReport{SchemaVersion: reportSchemaVersion, Run: r.Run, Deployments: reports, Findings: r.allFindings()}
+}
+
+func isOutputFormat(format string) bool {
+	_, ok := renderers[format]
+	return ok
+}
+
+func errInvalidOutputFormat(format string) error {
+	return fmt.Errorf("invalid output format %q. Valid formats are: %s", format, strings.Join(outputFormats, ", "))
+}
+
+// newRenderer returns the renderer for the output format and template options.
+// The text renderers use style for the section titles and severity colors.
+func newRenderer(opts options, style textStyle) (renderer, error) {
+	if opts.Template != "" || opts.TemplateDir != "" {
+		if opts.OutputFormat != "text" {
+			return nil, fmt.Errorf("--template and --template-dir can only be used with the text output format")
+		}
+	}
+	if opts.Template != "" {
+		return newCustomRenderer(opts.Template, style)
+	}
+	if opts.OutputFormat == "text" {
+		return newTextRenderer(opts.TemplateDir, style)
+	}
+	r, ok := renderers[opts.OutputFormat]
+	if !ok {
+		return nil, errInvalidOutputFormat(opts.OutputFormat)
+	}
+	return r, nil
+}
+
+// textSections are the templates of the run metadata, the header and each
+// report section. A file named <name>.tmpl in --template-dir replaces the
+// built-in template of the section.
+var textSections = []struct {
+	name string
+	text string
+}{
+	{"run", templates.Run},
+	{"header", templates.Header},
+	{"instances", templates.Replicas},
+	{"hostinfo", templates.HostInfo},
+	{"collections", templates.Collections},
+	{"runningops", templates.RunningOps},
+	{"security", templates.Security},
+	{"oplog", templates.Oplog},
+	{"findings", templates.Findings},
+}
+
+// textRenderer renders the text templates, by section name. The zero value
+// uses the built-in ones.
+type textRenderer struct {
+	sections map[string]*template.Template
+}
+
+// newTextRenderer returns a textRenderer that uses the templates in dir for
+// the sections that have one and the built-in templates for the rest. An
+// empty dir means only the built-in templates.
+func newTextRenderer(dir string, style textStyle) (textRenderer, error) {
+	overrides := make(map[string]string)
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return textRenderer{}, errors.Wrap(err, "invalid template dir")
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return textRenderer{}, err
+		}
+		for _, file := range files {
+			buf, err := ioutil.ReadFile(file)
+			if err != nil {
+				return textRenderer{}, errors.Wrap(err, "cannot read template")
+			}
+			overrides[strings.TrimSuffix(filepath.Base(file), ".tmpl")] = string(buf)
+		}
+	}
+	funcs := withTemplateFuncs(style.funcs())
+
+	r := textRenderer{sections: make(map[string]*template.Template)}
+	for _, section := range textSections {
+		text := section.text
+		if override, ok := overrides[section.name]; ok {
+			text = override
+			delete(overrides, section.name)
+		}
+		t, err := template.New(section.name).Funcs(funcs).Parse(text)
+		if err != nil {
+			return textRenderer{}, errors.Wrapf(err, "invalid template for section %s", section.name)
+		}
+		r.sections[section.name] = t
+	}
+	for name := range overrides {
+		return textRenderer{}, fmt.Errorf("%s: unknown section %q. Valid sections are: %s",
+			filepath.Join(dir, name+".tmpl"), name, strings.Join(textSectionNames(), ", "))
+	}
+	return r, nil
+}
+
+func textSectionNames() []string {
+	var names []string
+	for _, section := range textSections {
+		names = append(names, section.name)
+	}
+	return names
+}
+
+var defaultTextSections = func() map[string]*template.Template {
+	sections := make(map[string]*template.Template)
+	for _, section := range textSections {
+		sections[section.name] = template.Must(template.New(section.name).Funcs(templateFuncs).Parse(section.text))
+	}
+	return sections
+}()
+
+func (r textRenderer) Render(w io.Writer, res results) error {
+	sections := r.sections
+	if sections == nil {
+		sections = defaultTextSections
+	}
+	if res.Run != nil {
+		if err := sections["run"].Execute(w, res.Run); err != nil {
+			return err
+		}
+	}
+	for i, td := range res.Reports {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		for _, name := range append(append([]string{"header"}, td.sections()...), "findings") {
+			if err := sections[name].Execute(w, td); err != nil {
+				return err
+			}
+		}
+	}
+	// Findings that don't belong to any report, like unreachable seeds
+	if len(res.Findings) > 0 {
+		if len(res.Reports) > 0 {
+			fmt.Fprintln(w)
+		}
+		return sections["findings"].Execute(w, templateData{Findings: res.Findings})
+	}
+	return nil
+}
+
+// customRenderer renders each report with a user template given in --template
+type customRenderer struct {
+	t *template.Template
+}
+
+func newCustomRenderer(filename string, style textStyle) (customRenderer, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return customRenderer{}, errors.Wrap(err, "cannot read template")
+	}
+	t, err := template.New(filepath.Base(filename)).Funcs(withTemplateFuncs(style.funcs())).Parse(string(buf))
+	if err != nil {
+		return customRenderer{}, errors.Wrap(err, "invalid template")
+	}
+	return customRenderer{t: t}, nil
+}
+
+func (r customRenderer) Render(w io.Writer, res results) error {
+	for _, td := range res.Reports {
+		if err := r.t.Execute(w, td); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) Render(w io.Writer, r results) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(newJSONReport(r))
+}
+
+// yamlRenderer writes the same document as jsonRenderer, with the same field
+// names, as YAML
+type yamlRenderer struct{}
+
+func (yamlRenderer) Render(w io.Writer, r results) error {
+	buf, err := yaml.Marshal(newJSONReport(r))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+func testReport() templateData {
+	return templateData{
+		BuildInfo:    proto.BuildInfo{Version: "3.4.1"},
+		ServerStatus: proto.ServerStatus{Host: "db1:27017", Opcounters: &proto.OpcountStats{Query: 10}},
+		ReplicaMembers: []proto.Members{
+			{Id: 0, Name: "db1:27017", StateStr: "PRIMARY", Set: "rs0"},
+		},
+		Security:   &security{Users: 3, Roles: 2, AuthMechanism: "SCRAM-SHA-1"},
+		RunningOps: opCounters{Insert: timedStats{Min: 1, Max: 5}},
+		NodeType:   "replset",
+		AnsweredBy: "db1:27017",
+		Seeds:      []string{"db1:27017"},
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := renderers["json"].Render(buf, results{Reports: []templateData{testReport()}}); err != nil {
+		t.Fatalf("cannot write the JSON report: %s", err)
+	}
+
+	var doc struct {
+		SchemaVersion int `json:"schemaVersion"`
+		Deployments   []map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+	if doc.SchemaVersion != reportSchemaVersion {
+		t.Errorf("invalid schema version %d", doc.SchemaVersion)
+	}
+	if len(doc.Deployments) != 1 {
+		t.Fatalf("expected 1 deployment, got %d", len(doc.Deployments))
+	}
+
+	// Field names are part of the schema
+	expect := map[string]string{
+		"buildInfo":      `{"version":"3.4.1"`,
+		"serverStatus":   `{"host":"db1:27017"`,
+		"replicaMembers": `[{"optime":null,"optimeDate":"","infoMessage":"","id":0,"name":"db1:27017"`,
+		"security":       `{"users":3,"roles":2,"auth":"","ssl":"","authMechanism":"SCRAM-SHA-1"`,
+		"runningOps":     `{"insert":{"min":1,"max":5,"total":0,"avg":0}`,
+		"nodeType":       `"replset"`,
+		"answeredBy":     `"db1:27017"`,
+	}
+	for field, prefix := range expect {
+		compact := &bytes.Buffer{}
+		json.Compact(compact, doc.Deployments[0][field])
+		if !strings.HasPrefix(compact.String(), prefix) {
+			t.Errorf("invalid %s field.\ngot:    %s\nexpect: %s...", field, compact, prefix)
+		}
+	}
+	for _, field := range []string{"hostInfo", "commandLineOptions", "replicaSetStatus", "procInfo", "sampleRate", "seeds"} {
+		if _, ok := doc.Deployments[0][field]; !ok {
+			t.Errorf("missing field %s", field)
+		}
+	}
+}
+
+func TestJSONOutputNoReports(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := renderers["json"].Render(buf, results{}); err != nil {
+		t.Fatalf("cannot write the JSON report: %s", err)
+	}
+	if !strings.Contains(buf.String(), `"deployments": []`) {
+		t.Errorf("deployments must be an empty list if there are no reports: %s", buf)
+	}
+}
+
+func TestNoReportsOutput(t *testing.T) {
+	// When no deployment could be collected the run and the findings tell why
+	res := results{
+		Run:      &runInfo{ToolVersion: "3.0.0", CommandLine: "pt-mongodb-summary --hosts db1:27017", Seeds: []string{"db1:27017"}, HostsContacted: 1},
+		Findings: []finding{{Severity: severityError, Section: "connection", Host: "db1:27017", Message: "cannot connect: no reachable servers"}},
+	}
+	expect := map[string][]string{
+		"markdown": {
+			"# Run\n",
+			"| Command line | pt-mongodb-summary --hosts db1:27017 |\n",
+			"| Severity | Section | Host | Message |\n",
+			"| error | connection | db1:27017 | cannot connect: no reachable servers |\n",
+		},
+		"html": {
+			"<summary>Run</summary>",
+			"<tr><th>Command line</th><td>pt-mongodb-summary --hosts db1:27017</td></tr>",
+			"<summary>Findings</summary>",
+			`<tr class="warn"><td>error</td><td>connection</td><td>db1:27017</td><td>cannot connect: no reachable servers</td></tr>`,
+		},
+	}
+	for format, wants := range expect {
+		buf := &bytes.Buffer{}
+		if err := renderers[format].Render(buf, res); err != nil {
+			t.Fatalf("%s: cannot write the report: %s", format, err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s: missing %q in:\n%s", format, want, buf)
+			}
+		}
+	}
+}
+
+func TestTextOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := renderers["text"].Render(buf, results{Reports: []templateData{testReport(), testReport()}}); err != nil {
+		t.Fatalf("cannot write the text report: %s", err)
+	}
+	// The built-in templates work on reports without data
+	if err := renderers["text"].Render(&bytes.Buffer{}, results{Reports: []templateData{{}}}); err != nil {
+		t.Errorf("cannot write an empty text report: %s", err)
+	}
+	if n := strings.Count(buf.String(), "# Summary #"); n != 2 {
+		t.Errorf("expected a summary per deployment, got %d", n)
+	}
+
+	if _, err := newRenderer(options{OutputFormat: "xml"}, textStyle{}); err == nil {
+		t.Errorf("expected error for an invalid format")
+	}
+}
+
+func TestYAMLOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := renderers["yaml"].Render(buf, results{Reports: []templateData{testReport()}}); err != nil {
+		t.Fatalf("cannot write the YAML report: %s", err)
+	}
+	// Same field names as the JSON output
+	for _, line := range []string{
+		"schemaVersion: 1",
+		"deployments:",
+		"- answeredBy: db1:27017",
+		"    version: 3.4.1",
+		"    authMechanism: SCRAM-SHA-1",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("missing line %q in:\n%s", line, buf)
+		}
+	}
+}
+
+func TestMarkdownOutput(t *testing.T) {
+	td := testReport()
+	td.ReplicaMembers = append(td.ReplicaMembers, proto.Members{Id: 1, Name: "db|2:27017", StateStr: "SECONDARY", Set: "rs0"})
+	td.Oplog = &OplogInfo{Size: 1024, UsedMB: 12, Running: "3.50 hours"}
+
+	buf := &bytes.Buffer{}
+	if err := renderers["markdown"].Render(buf, results{Reports: []templateData{td, {}}}); err != nil {
+		t.Fatalf("cannot write the Markdown report: %s", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		"# Summary for db1:27017\n\nSeeds: db1:27017\n",
+		"\n## Instances\n\n| ID | Host | Type | ReplSet |\n|---:|------|------|---------|\n" +
+			"| 0 | db1:27017 | PRIMARY | rs0 |\n| 1 | db\\|2:27017 | SECONDARY | rs0 |\n",
+		"\n## This host\n",
+		"| Query | 0 | 0 | 0/0s |\n",
+		"| Auth mechanism | SCRAM-SHA-1 |\n| User roles | - |\n",
+		"| Oplog Size | 1.00 GB |\n| Oplog Used | 12.00 MB (1.17%) |\n",
+		// The second report has no data
+		"No replica sets found\n",
+		"No collections information\n",
+		"No security information\n",
+		"No oplog information\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "\n## "); n != 12 {
+		t.Errorf("expected 6 sections per report, got %d headings", n)
+	}
+}
+
+func TestTemplateDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pt-mongodb-summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "header.tmpl"), []byte("== {{upper .AnsweredBy}} ==\n"), 0600)
+	r, err := newRenderer(options{OutputFormat: "text", TemplateDir: dir}, textStyle{})
+	if err != nil {
+		t.Fatalf("cannot load the templates: %s", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf, results{Reports: []templateData{testReport()}}); err != nil {
+		t.Fatalf("cannot write the report: %s", err)
+	}
+	if !strings.HasPrefix(buf.String(), "== DB1:27017 ==\n") {
+		t.Errorf("the header template was not replaced:\n%s", buf)
+	}
+	// The other sections keep the built-in templates
+	if !strings.Contains(buf.String(), "# Running Ops #") {
+		t.Errorf("missing built-in sections:\n%s", buf)
+	}
+
+	// Templates failing on the data fail the report
+	ioutil.WriteFile(filepath.Join(dir, "header.tmpl"), []byte("{{.AnsweredBy.Missing}}\n"), 0600)
+	if r, err = newRenderer(options{OutputFormat: "text", TemplateDir: dir}, textStyle{}); err != nil {
+		t.Fatalf("cannot load the templates: %s", err)
+	}
+	if err := r.Render(&bytes.Buffer{}, results{Reports: []templateData{testReport()}}); err == nil {
+		t.Errorf("expected error for a template failing on the report")
+	}
+	os.Remove(filepath.Join(dir, "header.tmpl"))
+
+	ioutil.WriteFile(filepath.Join(dir, "hedaer.tmpl"), []byte(""), 0600)
+	if _, err := newRenderer(options{OutputFormat: "text", TemplateDir: dir}, textStyle{}); err == nil {
+		t.Errorf("expected error for an unknown section")
+	}
+	os.Remove(filepath.Join(dir, "hedaer.tmpl"))
+
+	ioutil.WriteFile(filepath.Join(dir, "oplog.tmpl"), []byte("{{.Oplog"), 0600)
+	if _, err := newRenderer(options{OutputFormat: "text", TemplateDir: dir}, textStyle{}); err == nil {
+		t.Errorf("expected error for an invalid template")
+	}
+
+	if _, err := newRenderer(options{OutputFormat: "json", TemplateDir: dir}, textStyle{}); err == nil {
+		t.Errorf("expected error for templates with a non text format")
+	}
+}
+
+func TestCustomTemplate(t *testing.T) {
+	file, err := ioutil.TempFile("", "pt-mongodb-summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`{{.AnsweredBy}} {{join .Seeds ","}} {{default "none" .Oplog}} {{default "-" .ReplicaSetStatus.Set}}` + "\n")
+	file.Close()
+
+	r, err := newRenderer(options{OutputFormat: "text", Template: file.Name()}, textStyle{})
+	if err != nil {
+		t.Fatalf("cannot load the template: %s", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf, results{Reports: []templateData{testReport(), testReport()}}); err != nil {
+		t.Fatalf("cannot write the report: %s", err)
+	}
+	expect := "db1:27017 db1:27017 none -\ndb1:27017 db1:27017 none -\n"
+	if buf.String() != expect {
+		t.Errorf("invalid report.\ngot:    %q\nexpect: %q", buf, expect)
+	}
+}
+
+func TestSectionsOrder(t *testing.T) {
+	td := testReport()
+	td.Sections = []string{"oplog", "security", "instances"}
+	td.Oplog = &OplogInfo{Size: 1024}
+
+	headings := map[string][]string{
+		"text":     {"# Oplog #", "# Security #", "# Instances #"},
+		"markdown": {"## Oplog", "## Security", "## Instances"},
+		"html":     {"<summary>Oplog</summary>", "<summary>Security</summary>", "<summary>Instances</summary>"},
+	}
+	skipped := map[string]string{
+		"text":     "# Running Ops #",
+		"markdown": "## Running Ops",
+		"html":     "<summary>Running Ops</summary>",
+	}
+	for format, expect := range headings {
+		buf := &bytes.Buffer{}
+		if err := renderers[format].Render(buf, results{Reports: []templateData{td}}); err != nil {
+			t.Fatalf("%s: cannot write the report: %s", format, err)
+		}
+		last := -1
+		for _, heading := range expect {
+			i := strings.Index(buf.String(), heading)
+			if i <= last {
+				t.Errorf("%s: %q is missing or out of order", format, heading)
+			}
+			last = i
+		}
+		if strings.Contains(buf.String(), skipped[format]) {
+			t.Errorf("%s: skipped sections must not be written", format)
+		}
+	}
+}
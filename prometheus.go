@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+// promFamily is a metric and its samples for all the reports. Prometheus
+// needs all the samples of a metric together, after its HELP and TYPE lines.
+type promFamily struct {
+	name    string
+	typ     string
+	help    string
+	samples []promSample
+}
+
+type promSample struct {
+	labels []string // name, value pairs
+	value  float64
+}
+
+// promMetrics collects the metrics of all the reports, keeping the order in
+// which they were first added
+type promMetrics struct {
+	families []*promFamily
+	byName   map[string]*promFamily
+}
+
+func (m *promMetrics) add(name, typ, help string, value float64, labels ...string) {
+	f, ok := m.byName[name]
+	if !ok {
+		f = &promFamily{name: name, typ: typ, help: help}
+		m.byName[name] = f
+		m.families = append(m.families, f)
+	}
+	f.samples = append(f.samples, promSample{labels: labels, value: value})
+}
+
+// prometheusRenderer writes the server status of each report in the
+// Prometheus text exposition format, ready for node_exporter's textfile
+// collector. Every sample is labeled with the host, replica set and shard of
+// the server that answered.
+type prometheusRenderer struct{}
+
+func (prometheusRenderer) Render(w io.Writer, r results) error {
+	m := &promMetrics{byName: make(map[string]*promFamily)}
+	for _, td := range r.Reports {
+		addServerStatusMetrics(m, td)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, f := range m.families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.typ)
+		for _, s := range f.samples {
+			fmt.Fprintf(bw, "%s{%s} %s\n", f.name, promLabels(s.labels), strconv.FormatFloat(s.value, 'f', -1, 64))
+		}
+	}
+	return bw.Flush()
+}
+
+// addServerStatusMetrics adds the serverStatus metrics of a report. Sections
+// missing in the server status, like wiredTiger on other storage engines,
+// are skipped.
+func addServerStatusMetrics(m *promMetrics, td templateData) {
+	ss := td.ServerStatus
+	var replset, shard string
+	if ss.Repl != nil {
+		replset = ss.Repl.SetName
+	}
+	if td.CommandLineOptions.Parsed.Sharding.ClusterRole == "shardsvr" {
+		shard = replset
+	}
+	add := func(name, typ, help string, value float64, labels ...string) {
+		labels = append([]string{"host", td.AnsweredBy, "replset", replset, "shard", shard}, labels...)
+		m.add(name, typ, help, value, labels...)
+	}
+
+	add("mongodb_info", "gauge", "Server version and process type. Always 1.", 1,
+		"version", ss.Version, "process", ss.Process)
+	add("mongodb_uptime_seconds", "gauge", "Time since the server started.", float64(ss.Uptime))
+
+	opcounters := func(name, help string, ops *proto.OpcountStats) {
+		if ops == nil {
+			return
+		}
+		add(name, "counter", help, float64(ops.Insert), "type", "insert")
+		add(name, "counter", help, float64(ops.Query), "type", "query")
+		add(name, "counter", help, float64(ops.Update), "type", "update")
+		add(name, "counter", help, float64(ops.Delete), "type", "delete")
+		add(name, "counter", help, float64(ops.GetMore), "type", "getmore")
+		add(name, "counter", help, float64(ops.Command), "type", "command")
+	}
+	opcounters("mongodb_op_counters_total", "Operations received since the server started, by type.", ss.Opcounters)
+	opcounters("mongodb_op_counters_repl_total", "Replicated operations applied since the server started, by type.", ss.OpcountersRepl)
+
+	if c := ss.Connections; c != nil {
+		add("mongodb_connections", "gauge", "Incoming connections, by state.", float64(c.Current), "state", "current")
+		add("mongodb_connections", "gauge", "Incoming connections, by state.", float64(c.Available), "state", "available")
+		add("mongodb_connections_created_total", "counter", "Incoming connections created since the server started.", float64(c.TotalCreated))
+	}
+
+	if wt := ss.WiredTiger; wt != nil {
+		add("mongodb_wiredtiger_cache_bytes", "gauge", "Data in the WiredTiger cache, by type.", float64(wt.Cache.CurrentCachedBytes), "type", "total")
+		add("mongodb_wiredtiger_cache_bytes", "gauge", "Data in the WiredTiger cache, by type.", float64(wt.Cache.TrackedDirtyBytes), "type", "dirty")
+		add("mongodb_wiredtiger_cache_max_bytes", "gauge", "Maximum size of the WiredTiger cache.", float64(wt.Cache.MaxBytesConfigured))
+		add("mongodb_wiredtiger_concurrent_transactions_out", "gauge", "WiredTiger read and write tickets in use.", float64(wt.Concurrent.Read.Out), "type", "read")
+		add("mongodb_wiredtiger_concurrent_transactions_out", "gauge", "WiredTiger read and write tickets in use.", float64(wt.Concurrent.Write.Out), "type", "write")
+	}
+
+	if gl := ss.GlobalLock; gl != nil {
+		if q := gl.CurrentQueue; q != nil {
+			add("mongodb_global_lock_current_queue", "gauge", "Operations waiting for the global lock, by type.", float64(q.Readers), "type", "reader")
+			add("mongodb_global_lock_current_queue", "gauge", "Operations waiting for the global lock, by type.", float64(q.Writers), "type", "writer")
+		}
+		if c := gl.ActiveClients; c != nil {
+			add("mongodb_global_lock_active_clients", "gauge", "Clients running read or write operations, by type.", float64(c.Readers), "type", "reader")
+			add("mongodb_global_lock_active_clients", "gauge", "Clients running read or write operations, by type.", float64(c.Writers), "type", "writer")
+		}
+	}
+
+	var asserts []string
+	for name := range ss.Asserts {
+		asserts = append(asserts, name)
+	}
+	sort.Strings(asserts)
+	for _, name := range asserts {
+		add("mongodb_asserts_total", "counter", "Assertions raised since the server started, by type.", float64(ss.Asserts[name]), "type", name)
+	}
+
+	if mem := ss.Mem; mem != nil {
+		// serverStatus reports memory in MB
+		add("mongodb_memory_bytes", "gauge", "Memory used by the server process, by type.", float64(mem.Resident)*1024*1024, "type", "resident")
+		add("mongodb_memory_bytes", "gauge", "Memory used by the server process, by type.", float64(mem.Virtual)*1024*1024, "type", "virtual")
+		if mem.Mapped > 0 {
+			add("mongodb_memory_bytes", "gauge", "Memory used by the server process, by type.", float64(mem.Mapped)*1024*1024, "type", "mapped")
+		}
+	}
+
+	if n := ss.Network; n != nil {
+		add("mongodb_network_bytes_total", "counter", "Network traffic since the server started, by direction.", float64(n.BytesIn), "direction", "in")
+		add("mongodb_network_bytes_total", "counter", "Network traffic since the server started, by direction.", float64(n.BytesOut), "direction", "out")
+		add("mongodb_network_requests_total", "counter", "Requests received since the server started.", float64(n.NumRequests))
+	}
+}
+
+// promLabels formats name, value pairs as name="value",...
+func promLabels(labels []string) string {
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+promLabelReplacer.Replace(labels[i+1])+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+var promLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+func TestPrometheusOutput(t *testing.T) {
+	td := testReport()
+	td.ServerStatus.Version = "3.4.1"
+	td.ServerStatus.Process = "mongod"
+	td.ServerStatus.Uptime = 3600
+	td.ServerStatus.Repl = &proto.ReplStatus{SetName: "rs0"}
+	td.ServerStatus.Connections = &proto.ConnectionStats{Current: 12, Available: 800, TotalCreated: 40}
+	td.ServerStatus.WiredTiger = &proto.WiredTiger{Cache: proto.CacheStats{CurrentCachedBytes: 2048, MaxBytesConfigured: 1 << 30}}
+	td.ServerStatus.GlobalLock = &proto.GlobalLockStats{CurrentQueue: &proto.QueueStats{Readers: 2, Writers: 1}}
+	td.ServerStatus.Asserts = map[string]int64{"warning": 1, "regular": 0}
+	td.ServerStatus.Mem = &proto.MemStats{Resident: 100, Virtual: 200}
+	td.ServerStatus.Network = &proto.NetworkStats{BytesIn: 1e9, BytesOut: 5000, NumRequests: 30}
+	td.CommandLineOptions.Parsed.Sharding.ClusterRole = "shardsvr"
+
+	// A mongos, without replica set nor storage engine stats
+	mongos := templateData{AnsweredBy: `mongos"1:27017`, ServerStatus: proto.ServerStatus{Process: "mongos"}}
+
+	buf := &bytes.Buffer{}
+	if err := renderers["prometheus"].Render(buf, results{Reports: []templateData{td, mongos}}); err != nil {
+		t.Fatalf("cannot write the metrics: %s", err)
+	}
+	got := buf.String()
+
+	labels := `host="db1:27017",replset="rs0",shard="rs0"`
+	for _, want := range []string{
+		"# HELP mongodb_op_counters_total Operations received since the server started, by type.\n" +
+			"# TYPE mongodb_op_counters_total counter\n" +
+			"mongodb_op_counters_total{" + labels + `,type="insert"} 0` + "\n" +
+			"mongodb_op_counters_total{" + labels + `,type="query"} 10` + "\n",
+		"mongodb_info{" + labels + `,version="3.4.1",process="mongod"} 1` + "\n" +
+			`mongodb_info{host="mongos\"1:27017",replset="",shard="",version="",process="mongos"} 1` + "\n",
+		"mongodb_uptime_seconds{" + labels + "} 3600\n",
+		"mongodb_connections{" + labels + `,state="current"} 12` + "\n",
+		"mongodb_wiredtiger_cache_max_bytes{" + labels + "} 1073741824\n",
+		"mongodb_global_lock_current_queue{" + labels + `,type="reader"} 2` + "\n",
+		"mongodb_asserts_total{" + labels + `,type="regular"} 0` + "\n" +
+			"mongodb_asserts_total{" + labels + `,type="warning"} 1` + "\n",
+		"mongodb_memory_bytes{" + labels + `,type="resident"} 104857600` + "\n",
+		"mongodb_network_bytes_total{" + labels + `,direction="in"} 1000000000` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+
+	// Each metric is described once, before all its samples
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(got), "\n") {
+		if !strings.HasPrefix(line, "# TYPE ") {
+			continue
+		}
+		name := strings.Fields(line)[2]
+		if seen[name] {
+			t.Errorf("metric %s is described more than once", name)
+		}
+		seen[name] = true
+	}
+}
@@ -1,9 +1,9 @@
 package proto
 
 type Asserts struct {
-	User      float64 `bson:"user"`
-	Warning   float64 `bson:"warning"`
-	Msg       float64 `bson:"msg"`
-	Regular   float64 `bson:"regular"`
-	Rollovers float64 `bson:"rollovers"`
+	User      float64 `bson:"user" json:"user"`
+	Warning   float64 `bson:"warning" json:"warning"`
+	Msg       float64 `bson:"msg" json:"msg"`
+	Regular   float64 `bson:"regular" json:"regular"`
+	Rollovers float64 `bson:"rollovers" json:"rollovers"`
 }
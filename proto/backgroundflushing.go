@@ -1,9 +1,9 @@
 package proto
 
 type BackgroundFlushing struct {
-	AverageMs    float64 `bson:"average_ms"`
-	Flushes      float64 `bson:"flushes"`
-	LastFinished string  `bson:"last_finished"`
-	LastMs       float64 `bson:"last_ms"`
-	TotalMs      float64 `bson:"total_ms"`
+	AverageMs    float64 `bson:"average_ms" json:"averageMs"`
+	Flushes      float64 `bson:"flushes" json:"flushes"`
+	LastFinished string  `bson:"last_finished" json:"lastFinished"`
+	LastMs       float64 `bson:"last_ms" json:"lastMs"`
+	TotalMs      float64 `bson:"total_ms" json:"totalMs"`
 }
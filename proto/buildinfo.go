@@ -2,12 +2,12 @@ package proto
 
 // BuildInfo Struct to store results of calling session.BuildInfo()
 type BuildInfo struct {
-	Version        string
-	VersionArray   []int32
-	GitVersion     string
-	OpenSSLVersion string
-	SysInfo        string
-	Bits           int32
-	Debug          bool
-	MaxObjectSize  int64
+	Version        string  `json:"version"`
+	VersionArray   []int32 `json:"versionArray"`
+	GitVersion     string  `json:"gitVersion"`
+	OpenSSLVersion string  `json:"openSSLVersion"`
+	SysInfo        string  `json:"sysInfo"`
+	Bits           int32   `json:"bits"`
+	Debug          bool    `json:"debug"`
+	MaxObjectSize  int64   `json:"maxObjectSize"`
 }
@@ -1,94 +1,106 @@
 package proto
 
 type ProcessManagement struct {
-	Fork bool `bson:"fork"`
+	Fork bool `bson:"fork" json:"fork"`
 }
 
 type Replication struct {
-	ReplSet string `bson:"replSet"`
+	ReplSet string `bson:"replSet" json:"replSet"`
 }
 
 type Sharding struct {
-	ClusterRole string `bson:"clusterRole"`
+	ClusterRole string `bson:"clusterRole" json:"clusterRole"`
 }
 
 type CloStorage struct {
-	DbPath string `bson:"dbPath"`
-	Engine string `bson:"engine"`
+	DbPath string `bson:"dbPath" json:"dbPath"`
+	Engine string `bson:"engine" json:"engine"`
 }
 
 type CloSystemLog struct {
-	Destination string `bson:"destination"`
-	Path        string `bson:"path"`
+	Destination string `bson:"destination" json:"destination"`
+	Path        string `bson:"path" json:"path"`
 }
 
 type Parsed struct {
-	Sharding          Sharding          `bson:"sharding"`
-	Storage           CloStorage        `bson:"storage"`
-	SystemLog         CloSystemLog      `bson:"systemLog"`
-	Net               Net               `bson:"net"`
-	ProcessManagement ProcessManagement `bson:"processManagement"`
-	Replication       Replication       `bson:"replication"`
+	Sharding          Sharding          `bson:"sharding" json:"sharding"`
+	Storage           CloStorage        `bson:"storage" json:"storage"`
+	SystemLog         CloSystemLog      `bson:"systemLog" json:"systemLog"`
+	Net               Net               `bson:"net" json:"net"`
+	ProcessManagement ProcessManagement `bson:"processManagement" json:"processManagement"`
+	Replication       Replication       `bson:"replication" json:"replication"`
+	Security          Security          `bson:"security" json:"security"`
 }
 
 // Security is a struct to hold security related configs
 type Security struct {
-	KeyFile           string `bson:"keyFile"`
-	ClusterAuthMode   string `bson:"clusterAuthMode"`
-	Authorization     string `bson:"authorization"`
-	JavascriptEnabled bool   `bson:javascriptEnabled"`
+	KeyFile           string `bson:"keyFile" json:"keyFile"`
+	ClusterAuthMode   string `bson:"clusterAuthMode" json:"clusterAuthMode"`
+	Authorization     string `bson:"authorization" json:"authorization"`
+	JavascriptEnabled bool   `bson:"javascriptEnabled" json:"javascriptEnabled"`
 	Sasl              struct {
-		HostName            string `bson:"hostName"`
-		ServiceName         string `bson:"serverName"`
-		SaslauthdSocketPath string `bson:"saslauthdSocketPath"`
-	} `bson:"sasl"`
-	EnableEncryption     bool   `bson:"enableEncryption"`
-	EncryptionCipherMode string `bson:"encryptionCipherMode"`
-	EncryptionKeyFile    string `bson:"encryptionKeyFile"`
+		HostName            string `bson:"hostName" json:"hostName"`
+		ServiceName         string `bson:"serverName" json:"serviceName"`
+		SaslauthdSocketPath string `bson:"saslauthdSocketPath" json:"saslauthdSocketPath"`
+	} `bson:"sasl" json:"sasl"`
+	EnableEncryption     bool   `bson:"enableEncryption" json:"enableEncryption"`
+	EncryptionCipherMode string `bson:"encryptionCipherMode" json:"encryptionCipherMode"`
+	EncryptionKeyFile    string `bson:"encryptionKeyFile" json:"encryptionKeyFile"`
 	Kmip                 struct {
-		KeyIdentifier             string `bson:"keyIdentifier"`
-		RotateMasterKey           bool   `bson:"rotateMasterKey"`
-		ServerName                string `bson:"serverName"`
-		Port                      string `bson:"port"`
-		ClientCertificateFile     string `bson:"clientCertificateFile"`
-		ClientCertificatePassword string `bson:"clientCertificatePassword"`
-		ServerCAFile              string `bson:"serverCAFile"`
-	} `bson:"kmip"`
+		KeyIdentifier             string `bson:"keyIdentifier" json:"keyIdentifier"`
+		RotateMasterKey           bool   `bson:"rotateMasterKey" json:"rotateMasterKey"`
+		ServerName                string `bson:"serverName" json:"serverName"`
+		Port                      string `bson:"port" json:"port"`
+		ClientCertificateFile     string `bson:"clientCertificateFile" json:"clientCertificateFile"`
+		ClientCertificatePassword string `bson:"clientCertificatePassword" json:"clientCertificatePassword"`
+		ServerCAFile              string `bson:"serverCAFile" json:"serverCAFile"`
+	} `bson:"kmip" json:"kmip"`
 }
 
 // NET config options. See https://docs.mongodb.com/manual/reference/configuration-options/#net-options
 type Net struct {
-	HTTP HTTP `bson:"http"`
-	SSL  SSL  `bson:"ssl"`
+	HTTP HTTP `bson:"http" json:"http"`
+	SSL  SSL  `bson:"ssl" json:"ssl"`
+	TLS  TLS  `bson:"tls" json:"tls"`
 }
 
 type HTTP struct {
-	Enabled              bool    `bson:"enabled"`
-	Port                 float64 `bson:"port"`
-	JSONPEnabled         bool    `bson:"JSONPEnabled"`
-	RESTInterfaceEnabled bool    `bson:"RESTInterfaceEnabled"`
+	Enabled              bool    `bson:"enabled" json:"enabled"`
+	Port                 float64 `bson:"port" json:"port"`
+	JSONPEnabled         bool    `bson:"JSONPEnabled" json:"jsonpEnabled"`
+	RESTInterfaceEnabled bool    `bson:"RESTInterfaceEnabled" json:"restInterfaceEnabled"`
 }
 
 // SSL config options. See https://docs.mongodb.com/manual/reference/configuration-options/#net-ssl-options
 type SSL struct {
-	SSLOnNormalPorts                    bool   `bson:"sslOnNormalPorts"` // deprecated since 2.6
-	Mode                                string `bson:"mode"`             // disabled, allowSSL, preferSSL, requireSSL
-	PEMKeyFile                          string `bson:"PEMKeyFile"`
-	PEMKeyPassword                      string `bson:"PEMKeyPassword"`
-	ClusterFile                         string `bson:"clusterFile"`
-	ClusterPassword                     string `bson:"clusterPassword"`
-	CAFile                              string `bson:"CAFile"`
-	CRLFile                             string `bson:"CRLFile"`
-	AllowConnectionsWithoutCertificates bool   `bson:"allowConnectionsWithoutCertificates"`
-	AllowInvalidCertificates            bool   `bson:"allowInvalidCertificates"`
-	AllowInvalidHostnames               bool   `bson:"allowInvalidHostnames"`
-	DisabledProtocols                   string `bson:"disabledProtocols"`
-	FIPSMode                            bool   `bson:"FIPSMode"`
+	SSLOnNormalPorts                    bool   `bson:"sslOnNormalPorts" json:"sslOnNormalPorts"` // deprecated since 2.6
+	Mode                                string `bson:"mode" json:"mode"`                         // disabled, allowSSL, preferSSL, requireSSL
+	PEMKeyFile                          string `bson:"PEMKeyFile" json:"pemKeyFile"`
+	PEMKeyPassword                      string `bson:"PEMKeyPassword" json:"pemKeyPassword"`
+	ClusterFile                         string `bson:"clusterFile" json:"clusterFile"`
+	ClusterPassword                     string `bson:"clusterPassword" json:"clusterPassword"`
+	CAFile                              string `bson:"CAFile" json:"caFile"`
+	CRLFile                             string `bson:"CRLFile" json:"crlFile"`
+	AllowConnectionsWithoutCertificates bool   `bson:"allowConnectionsWithoutCertificates" json:"allowConnectionsWithoutCertificates"`
+	AllowInvalidCertificates            bool   `bson:"allowInvalidCertificates" json:"allowInvalidCertificates"`
+	AllowInvalidHostnames               bool   `bson:"allowInvalidHostnames" json:"allowInvalidHostnames"`
+	DisabledProtocols                   string `bson:"disabledProtocols" json:"disabledProtocols"`
+	FIPSMode                            bool   `bson:"FIPSMode" json:"fipsMode"`
+}
+
+// TLS config options, replacing the SSL ones since MongoDB 4.2. See
+// https://docs.mongodb.com/manual/reference/configuration-options/#net-tls-options
+type TLS struct {
+	Mode                       string `bson:"mode" json:"mode"` // disabled, allowTLS, preferTLS, requireTLS
+	CertificateKeyFile         string `bson:"certificateKeyFile" json:"certificateKeyFile"`
+	CertificateKeyFilePassword string `bson:"certificateKeyFilePassword" json:"certificateKeyFilePassword"`
+	ClusterFile                string `bson:"clusterFile" json:"clusterFile"`
+	ClusterPassword            string `bson:"clusterPassword" json:"clusterPassword"`
+	CAFile                     string `bson:"CAFile" json:"caFile"`
 }
 
 type CommandLineOptions struct {
-	Argv     []string `bson:"argv"`
-	Ok       float64  `bson:"ok"`
-	Parsed   Parsed   `bson:"parsed"`
-	Security Security `bson:"security"`
+	Argv   []string `bson:"argv" json:"argv"`
+	Ok     float64  `bson:"ok" json:"ok"`
+	Parsed Parsed   `bson:"parsed" json:"parsed"`
 }
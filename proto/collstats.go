@@ -0,0 +1,14 @@
+package proto
+
+// CollStats has the fields of the collStats command output used in reports
+type CollStats struct {
+	Name           string  `bson:"-" json:"name"`
+	Ns             string  `bson:"ns" json:"ns"`
+	Count          int64   `bson:"count" json:"count"`
+	Size           int64   `bson:"size" json:"size"`
+	AvgObjSize     float64 `bson:"avgObjSize" json:"avgObjSize"`
+	StorageSize    int64   `bson:"storageSize" json:"storageSize"`
+	Nindexes       int64   `bson:"nindexes" json:"nindexes"`
+	TotalIndexSize int64   `bson:"totalIndexSize" json:"totalIndexSize"`
+	Capped         bool    `bson:"capped" json:"capped"`
+}
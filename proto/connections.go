@@ -1,7 +1,7 @@
 package proto
 
 type Connections struct {
-	Available    float64 `bson:"available"`
-	Current      float64 `bson:"current"`
-	TotalCreated float64 `bson:"totalCreated"`
+	Available    float64 `bson:"available" json:"available"`
+	Current      float64 `bson:"current" json:"current"`
+	TotalCreated float64 `bson:"totalCreated" json:"totalCreated"`
 }
@@ -0,0 +1,20 @@
+package proto
+
+type AuthUser struct {
+	User string `bson:"user" json:"user"`
+	DB   string `bson:"db" json:"db"`
+}
+
+type AuthUserRole struct {
+	Role string `bson:"role" json:"role"`
+	DB   string `bson:"db" json:"db"`
+}
+
+// ConnectionStatus is the output of the connectionStatus command
+type ConnectionStatus struct {
+	AuthInfo struct {
+		AuthenticatedUsers     []AuthUser     `bson:"authenticatedUsers" json:"authenticatedUsers"`
+		AuthenticatedUserRoles []AuthUserRole `bson:"authenticatedUserRoles" json:"authenticatedUserRoles"`
+	} `bson:"authInfo" json:"authInfo"`
+	Ok float64 `bson:"ok" json:"ok"`
+}
@@ -1,68 +1,68 @@
 package proto
 
 type Query struct {
-	CurrentOp float64 `bson:"currentOp"`
+	CurrentOp float64 `bson:"currentOp" json:"currentOp"`
 }
 
 type Progress struct {
-	Done  float64 `bson:"done"`
-	Total float64 `bson:"total"`
+	Done  float64 `bson:"done" json:"done"`
+	Total float64 `bson:"total" json:"total"`
 }
 
 type AcquireCount struct {
-	Rr float64 `bson:"r"`
-	Ww float64 `bson:"w"`
-	R  float64 `bson:"R"`
-	W  float64 `bson:"W"`
+	Rr float64 `bson:"r" json:"rr"`
+	Ww float64 `bson:"w" json:"ww"`
+	R  float64 `bson:"R" json:"r"`
+	W  float64 `bson:"W" json:"w"`
 }
 
 type Global struct {
-	DeadlockCount       AcquireCount `bson:"deadlockCount"`
-	AcquireCount        AcquireCount `bson:"acquireCount"`
-	AcquireWaitCount    AcquireCount `bson:"acquireWaitCount"`
-	TimeAcquiringMicros AcquireCount `bson:"timeAcquiringMicros"`
+	DeadlockCount       AcquireCount `bson:"deadlockCount" json:"deadlockCount"`
+	AcquireCount        AcquireCount `bson:"acquireCount" json:"acquireCount"`
+	AcquireWaitCount    AcquireCount `bson:"acquireWaitCount" json:"acquireWaitCount"`
+	TimeAcquiringMicros AcquireCount `bson:"timeAcquiringMicros" json:"timeAcquiringMicros"`
 }
 
 type CurrentOpLockStats struct {
-	Global        Global      `bson:"Global"`
-	MMAPV1Journal interface{} `bson:"MMAPV1Journal"`
-	Database      interface{} `bson:"Database"`
+	Global        Global      `bson:"Global" json:"global"`
+	MMAPV1Journal interface{} `bson:"MMAPV1Journal" json:"mMAPV1Journal"`
+	Database      interface{} `bson:"Database" json:"database"`
 }
 
 type Locks struct {
-	Global        string `bson:"Global"`
-	MMAPV1Journal string `bson:"MMAPV1Journal"`
-	Database      string `bson:"Database"`
-	Collection    string `bson:"Collection"`
-	Metadata      string `bson:"Metadata"`
-	Oplog         string `bson:"oplog"`
+	Global        string `bson:"Global" json:"global"`
+	MMAPV1Journal string `bson:"MMAPV1Journal" json:"mMAPV1Journal"`
+	Database      string `bson:"Database" json:"database"`
+	Collection    string `bson:"Collection" json:"collection"`
+	Metadata      string `bson:"Metadata" json:"metadata"`
+	Oplog         string `bson:"oplog" json:"oplog"`
 }
 
 type Inprog struct {
-	Desc             string             `bson:"desc"`
-	ConnectionId     float64            `bson:"connectionId"`
-	Opid             float64            `bson:"opid"`
-	Msg              string             `bson:"msg"`
-	NumYields        float64            `bson:"numYields"`
-	Locks            Locks              `bson:"locks"`
-	WaitingForLock   float64            `bson:"waitingForLock"`
-	ThreadId         string             `bson:"threadId"`
-	Active           float64            `bson:"active"`
-	MicrosecsRunning float64            `bson:"microsecs_running"`
-	SecsRunning      float64            `bson:"secs_running"`
-	Op               string             `bson:"op"`
-	Ns               string             `bson:"ns"`
-	Insert           interface{}        `bson:"insert"`
-	PlanSummary      string             `bson:"planSummary"`
-	Client           string             `bson:"client"`
-	Query            Query              `bson:"query"`
-	Progress         Progress           `bson:"progress"`
-	KillPending      float64            `bson:"killPending"`
-	LockStats        CurrentOpLockStats `bson:"lockStats"`
+	Desc             string             `bson:"desc" json:"desc"`
+	ConnectionId     float64            `bson:"connectionId" json:"connectionId"`
+	Opid             float64            `bson:"opid" json:"opid"`
+	Msg              string             `bson:"msg" json:"msg"`
+	NumYields        float64            `bson:"numYields" json:"numYields"`
+	Locks            Locks              `bson:"locks" json:"locks"`
+	WaitingForLock   float64            `bson:"waitingForLock" json:"waitingForLock"`
+	ThreadId         string             `bson:"threadId" json:"threadId"`
+	Active           float64            `bson:"active" json:"active"`
+	MicrosecsRunning float64            `bson:"microsecs_running" json:"microsecsRunning"`
+	SecsRunning      float64            `bson:"secs_running" json:"secsRunning"`
+	Op               string             `bson:"op" json:"op"`
+	Ns               string             `bson:"ns" json:"ns"`
+	Insert           interface{}        `bson:"insert" json:"insert"`
+	PlanSummary      string             `bson:"planSummary" json:"planSummary"`
+	Client           string             `bson:"client" json:"client"`
+	Query            Query              `bson:"query" json:"query"`
+	Progress         Progress           `bson:"progress" json:"progress"`
+	KillPending      float64            `bson:"killPending" json:"killPending"`
+	LockStats        CurrentOpLockStats `bson:"lockStats" json:"lockStats"`
 }
 
 type CurrentOp struct {
-	Info      string   `bson:"info"`
-	Inprog    []Inprog `bson:"inprog"`
-	FsyncLock float64  `bson:"fsyncLock"`
+	Info      string   `bson:"info" json:"info"`
+	Inprog    []Inprog `bson:"inprog" json:"inprog"`
+	FsyncLock float64  `bson:"fsyncLock" json:"fsyncLock"`
 }
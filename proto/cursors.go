@@ -1,10 +1,10 @@
 package proto
 
 type Cursors struct {
-	ClientCursorsSize float64 `bson:"clientCursors_size"`
-	Note              string  `bson:"note"`
-	Pinned            float64 `bson:"pinned"`
-	TimedOut          float64 `bson:"timedOut"`
-	TotalNoTimeout    float64 `bson:"totalNoTimeout"`
-	TotalOpen         float64 `bson:"totalOpen"`
+	ClientCursorsSize float64 `bson:"clientCursors_size" json:"clientCursorsSize"`
+	Note              string  `bson:"note" json:"note"`
+	Pinned            float64 `bson:"pinned" json:"pinned"`
+	TimedOut          float64 `bson:"timedOut" json:"timedOut"`
+	TotalNoTimeout    float64 `bson:"totalNoTimeout" json:"totalNoTimeout"`
+	TotalOpen         float64 `bson:"totalOpen" json:"totalOpen"`
 }
@@ -2,12 +2,14 @@ package proto
 
 // Database item plus struct to hold collections stats
 type Database struct {
-	Name       string `bson:"name"`
-	SizeOnDisk int64  `bson:"sizeOnDisk"`
-	Empty      bool   `bson:"empty"`
+	Name       string `bson:"name" json:"name"`
+	SizeOnDisk int64  `bson:"sizeOnDisk" json:"sizeOnDisk"`
+	Empty      bool   `bson:"empty" json:"empty"`
+	// Collections is filled with one collStats call per collection
+	Collections []CollStats `bson:"-" json:"collections"`
 }
 
 // Database struct for listDatabases command
 type Databases struct {
-	Databases []Database `bson:"databases"`
+	Databases []Database `bson:"databases" json:"databases"`
 }
@@ -1,21 +1,21 @@
 package proto
 
 type TimeMs struct {
-	WriteToDataFiles   float64 `bson:"writeToDataFiles"`
-	WriteToJournal     float64 `bson:"writeToJournal"`
-	Commits            float64 `bson:"commits"`
-	CommitsInWriteLock float64 `bson:"commitsInWriteLock"`
-	Dt                 float64 `bson:"dt"`
-	PrepLogBuffer      float64 `bson:"prepLogBuffer"`
-	RemapPrivateView   float64 `bson:"remapPrivateView"`
+	WriteToDataFiles   float64 `bson:"writeToDataFiles" json:"writeToDataFiles"`
+	WriteToJournal     float64 `bson:"writeToJournal" json:"writeToJournal"`
+	Commits            float64 `bson:"commits" json:"commits"`
+	CommitsInWriteLock float64 `bson:"commitsInWriteLock" json:"commitsInWriteLock"`
+	Dt                 float64 `bson:"dt" json:"dt"`
+	PrepLogBuffer      float64 `bson:"prepLogBuffer" json:"prepLogBuffer"`
+	RemapPrivateView   float64 `bson:"remapPrivateView" json:"remapPrivateView"`
 }
 
 type Dur struct {
-	TimeMs             *TimeMs `bson:"timeMs"`
-	WriteToDataFilesMB float64 `bson:"writeToDataFilesMB"`
-	Commits            float64 `bson:"commits"`
-	CommitsInWriteLock float64 `bson:"commitsInWriteLock"`
-	Compression        float64 `bson:"compression"`
-	EarlyCommits       float64 `bson:"earlyCommits"`
-	JournaledMB        float64 `bson:"journaledMB"`
+	TimeMs             *TimeMs `bson:"timeMs" json:"timeMs"`
+	WriteToDataFilesMB float64 `bson:"writeToDataFilesMB" json:"writeToDataFilesMB"`
+	Commits            float64 `bson:"commits" json:"commits"`
+	CommitsInWriteLock float64 `bson:"commitsInWriteLock" json:"commitsInWriteLock"`
+	Compression        float64 `bson:"compression" json:"compression"`
+	EarlyCommits       float64 `bson:"earlyCommits" json:"earlyCommits"`
+	JournaledMB        float64 `bson:"journaledMB" json:"journaledMB"`
 }
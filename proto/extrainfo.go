@@ -1,7 +1,7 @@
 package proto
 
 type ExtraInfo struct {
-	PageFaults     float64 `bson:"page_faults"`
-	HeapUsageBytes float64 `bson:"heap_usage_bytes"`
-	Note           string  `bson:"note"`
+	PageFaults     float64 `bson:"page_faults" json:"pageFaults"`
+	HeapUsageBytes float64 `bson:"heap_usage_bytes" json:"heapUsageBytes"`
+	Note           string  `bson:"note" json:"note"`
 }
@@ -1,19 +1,19 @@
 package proto
 
 type GlobalLock struct {
-	ActiveClients *ActiveClients `bson:"activeClients"`
-	CurrentQueue  *CurrentQueue  `bson:"currentQueue"`
-	TotalTime     int64          `bson:"totalTime"`
+	ActiveClients *ActiveClients `bson:"activeClients" json:"activeClients"`
+	CurrentQueue  *CurrentQueue  `bson:"currentQueue" json:"currentQueue"`
+	TotalTime     int64          `bson:"totalTime" json:"totalTime"`
 }
 
 type ActiveClients struct {
-	Readers int64 `bson:"readers"`
-	Total   int64 `bson:"total"`
-	Writers int64 `bson:"writers"`
+	Readers int64 `bson:"readers" json:"readers"`
+	Total   int64 `bson:"total" json:"total"`
+	Writers int64 `bson:"writers" json:"writers"`
 }
 
 type CurrentQueue struct {
-	Writers int64 `bson:"writers"`
-	Readers int64 `bson:"readers"`
-	Total   int64 `bson:"total"`
+	Writers int64 `bson:"writers" json:"writers"`
+	Readers int64 `bson:"readers" json:"readers"`
+	Total   int64 `bson:"total" json:"total"`
 }
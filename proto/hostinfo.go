@@ -1,41 +1,41 @@
 package proto
 
 type Extra struct {
-	LibcVersion      string  `bson:"libcVersion"`
-	PageSize         float64 `bson:"pageSize"`
-	VersionSignature string  `bson:"versionSignature"`
-	NumPages         float64 `bson:"numPages"`
-	VersionString    string  `bson:"versionString"`
-	CpuFeatures      string  `bson:"cpuFeatures"`
-	CpuFrequencyMHz  string  `bson:"cpuFrequencyMHz"`
-	KernelVersion    string  `bson:"kernelVersion"`
-	MaxOpenFiles     float64 `bson:"maxOpenFiles"`
+	LibcVersion      string  `bson:"libcVersion" json:"libcVersion"`
+	PageSize         float64 `bson:"pageSize" json:"pageSize"`
+	VersionSignature string  `bson:"versionSignature" json:"versionSignature"`
+	NumPages         float64 `bson:"numPages" json:"numPages"`
+	VersionString    string  `bson:"versionString" json:"versionString"`
+	CpuFeatures      string  `bson:"cpuFeatures" json:"cpuFeatures"`
+	CpuFrequencyMHz  string  `bson:"cpuFrequencyMHz" json:"cpuFrequencyMHz"`
+	KernelVersion    string  `bson:"kernelVersion" json:"kernelVersion"`
+	MaxOpenFiles     float64 `bson:"maxOpenFiles" json:"maxOpenFiles"`
 }
 
 type Os struct {
-	Type    string `bson:"type"`
-	Version string `bson:"version"`
-	Name    string `bson:"name"`
+	Type    string `bson:"type" json:"type"`
+	Version string `bson:"version" json:"version"`
+	Name    string `bson:"name" json:"name"`
 }
 
 type System struct {
-	CurrentTime string  `bson:"currentTime"`
-	Hostname    string  `bson:"hostname"`
-	MemSizeMB   float64 `bson:"memSizeMB"`
-	NumCores    float64 `bson:"numCores"`
-	NumaEnabled bool    `bson:"numaEnabled"`
-	CpuAddrSize float64 `bson:"cpuAddrSize"`
-	CpuArch     string  `bson:"cpuArch"`
+	CurrentTime string  `bson:"currentTime" json:"currentTime"`
+	Hostname    string  `bson:"hostname" json:"hostname"`
+	MemSizeMB   float64 `bson:"memSizeMB" json:"memSizeMB"`
+	NumCores    float64 `bson:"numCores" json:"numCores"`
+	NumaEnabled bool    `bson:"numaEnabled" json:"numaEnabled"`
+	CpuAddrSize float64 `bson:"cpuAddrSize" json:"cpuAddrSize"`
+	CpuArch     string  `bson:"cpuArch" json:"cpuArch"`
 }
 
 // HostInfo has exported field for the 'hostInfo' command plus some other
 // fields like Database/Collections count. We are setting those fields into
 // a separated function
 type HostInfo struct {
-	Extra            *Extra  `bson:"extra"`
-	Os               *Os     `bson:"os"`
-	System           *System `bson:"system"`
-	DatabasesCount   int
-	CollectionsCount int
-	ID               int
+	Extra            *Extra  `bson:"extra" json:"extra"`
+	Os               *Os     `bson:"os" json:"os"`
+	System           *System `bson:"system" json:"system"`
+	DatabasesCount   int     `json:"databasesCount"`
+	CollectionsCount int     `json:"collectionsCount"`
+	ID               int     `json:"id"`
 }
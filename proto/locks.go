@@ -1,7 +1,7 @@
 package proto
 
 type AcquiredLocks struct {
-	AcquireCount        *AcquireCount `bson:"acquireCount"`
-	AcquireWaitCount    float64       `bson:"acquireWaitCount.W"`
-	TimeAcquiringMicros float64       `bson:"timeAcquiringMicros.W"`
+	AcquireCount        *AcquireCount `bson:"acquireCount" json:"acquireCount"`
+	AcquireWaitCount    float64       `bson:"acquireWaitCount.W" json:"acquireWaitCount"`
+	TimeAcquiringMicros float64       `bson:"timeAcquiringMicros.W" json:"timeAcquiringMicros"`
 }
@@ -1,7 +1,10 @@
 package proto
 
 type MasterDoc struct {
-	SetName interface{} `bson:"setName"`
-	Hosts   interface{} `bson:"hosts"`
-	Msg     string      `bson:"msg"`
+	SetName  interface{} `bson:"setName" json:"setName"`
+	Hosts    []string    `bson:"hosts" json:"hosts"`
+	Passives []string    `bson:"passives" json:"passives"`
+	Arbiters []string    `bson:"arbiters" json:"arbiters"`
+	Me       string      `bson:"me" json:"me"`
+	Msg      string      `bson:"msg" json:"msg"`
 }
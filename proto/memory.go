@@ -1,10 +1,10 @@
 package proto
 
 type Mem struct {
-	Bits              float64 `bson:"bits"`
-	Mapped            float64 `bson:"mapped"`
-	MappedWithJournal float64 `bson:"mappedWithJournal"`
-	Resident          float64 `bson:"resident"`
-	Supported         bool    `bson:"supported"`
-	Virtual           float64 `bson:"virtual"`
+	Bits              float64 `bson:"bits" json:"bits"`
+	Mapped            float64 `bson:"mapped" json:"mapped"`
+	MappedWithJournal float64 `bson:"mappedWithJournal" json:"mappedWithJournal"`
+	Resident          float64 `bson:"resident" json:"resident"`
+	Supported         bool    `bson:"supported" json:"supported"`
+	Virtual           float64 `bson:"virtual" json:"virtual"`
 }
@@ -1,85 +1,85 @@
 package proto
 
 type Metrics struct {
-	Commands      map[string]CommandStats `bson:"commands"`
-	Cursor        *Cursor                 `bson:"cursor"`
-	Document      *Document               `bson:"document"`
-	GetLastError  *GetLastError           `bson:"getLastError"`
-	Moves         float64                 `bson:"record.moves"`
-	Operation     *Operation              `bson:"operation"`
-	QueryExecutor *QueryExecutor          `bson:"queryExecutor"`
-	Repl          *ReplMetrics            `bson:"repl"`
-	Storage       *Storage                `bson:"storage"`
-	Ttl           *Ttl                    `bson:"ttl"`
+	Commands      map[string]CommandStats `bson:"commands" json:"commands"`
+	Cursor        *Cursor                 `bson:"cursor" json:"cursor"`
+	Document      *Document               `bson:"document" json:"document"`
+	GetLastError  *GetLastError           `bson:"getLastError" json:"getLastError"`
+	Moves         float64                 `bson:"record.moves" json:"moves"`
+	Operation     *Operation              `bson:"operation" json:"operation"`
+	QueryExecutor *QueryExecutor          `bson:"queryExecutor" json:"queryExecutor"`
+	Repl          *ReplMetrics            `bson:"repl" json:"repl"`
+	Storage       *Storage                `bson:"storage" json:"storage"`
+	Ttl           *Ttl                    `bson:"ttl" json:"ttl"`
 }
 
 type CommandStats struct {
-	Failed float64 `bson:"failed"`
-	Total  float64 `bson:"total"`
+	Failed float64 `bson:"failed" json:"failed"`
+	Total  float64 `bson:"total" json:"total"`
 }
 
 type Cursor struct {
-	NoTimeout float64 `bson:"open.noTimeout"`
-	Pinned    float64 `bson:"open.pinned"`
-	TimedOut  float64 `bson:"timedOut"`
-	Total     float64 `bson:"open.total"`
+	NoTimeout float64 `bson:"open.noTimeout" json:"noTimeout"`
+	Pinned    float64 `bson:"open.pinned" json:"pinned"`
+	TimedOut  float64 `bson:"timedOut" json:"timedOut"`
+	Total     float64 `bson:"open.total" json:"total"`
 }
 
 type Document struct {
-	Deleted  float64 `bson:"deleted"`
-	Inserted float64 `bson:"inserted"`
-	Returned float64 `bson:"returned"`
-	Updated  float64 `bson:"updated"`
+	Deleted  float64 `bson:"deleted" json:"deleted"`
+	Inserted float64 `bson:"inserted" json:"inserted"`
+	Returned float64 `bson:"returned" json:"returned"`
+	Updated  float64 `bson:"updated" json:"updated"`
 }
 
 type GetLastError struct {
-	Wtimeouts   float64 `bson:"wtimeouts"`
-	Num         float64 `bson:"wtime.num"`
-	TotalMillis float64 `bson:"wtime.totalMillis"`
+	Wtimeouts   float64 `bson:"wtimeouts" json:"wtimeouts"`
+	Num         float64 `bson:"wtime.num" json:"num"`
+	TotalMillis float64 `bson:"wtime.totalMillis" json:"totalMillis"`
 }
 
 type ReplMetrics struct {
-	Batches            *MetricStats `bson:"apply.batches"`
-	BufferSizeBytes    float64      `bson:"buffer.sizeBytes"`
-	BufferCount        float64      `bson:"buffer.count"`
-	BufferMaxSizeBytes float64      `bson:"buffer.maxSizeBytes"`
-	Network            *ReplNetwork `bson:"network"`
-	Ops                float64      `bson:"apply.ops"`
-	PreloadDocs        *MetricStats `bson:"preload.docs"`
-	PreloadIndexes     *MetricStats `bson:"preload.indexes"`
+	Batches            *MetricStats `bson:"apply.batches" json:"batches"`
+	BufferSizeBytes    float64      `bson:"buffer.sizeBytes" json:"bufferSizeBytes"`
+	BufferCount        float64      `bson:"buffer.count" json:"bufferCount"`
+	BufferMaxSizeBytes float64      `bson:"buffer.maxSizeBytes" json:"bufferMaxSizeBytes"`
+	Network            *ReplNetwork `bson:"network" json:"network"`
+	Ops                float64      `bson:"apply.ops" json:"ops"`
+	PreloadDocs        *MetricStats `bson:"preload.docs" json:"preloadDocs"`
+	PreloadIndexes     *MetricStats `bson:"preload.indexes" json:"preloadIndexes"`
 }
 
 type Storage struct {
-	BucketExhausted float64 `bson:"freelist.search.bucketExhausted"`
-	Requests        float64 `bson:"freelist.search.requests"`
-	Scanned         float64 `bson:"freelist.search.scanned"`
+	BucketExhausted float64 `bson:"freelist.search.bucketExhausted" json:"bucketExhausted"`
+	Requests        float64 `bson:"freelist.search.requests" json:"requests"`
+	Scanned         float64 `bson:"freelist.search.scanned" json:"scanned"`
 }
 
 type MetricStats struct {
-	Num         float64 `bson:"num"`
-	TotalMillis float64 `bson:"totalMillis"`
+	Num         float64 `bson:"num" json:"num"`
+	TotalMillis float64 `bson:"totalMillis" json:"totalMillis"`
 }
 
 type ReplNetwork struct {
-	Getmores       *MetricStats `bson:"getmores"`
-	Ops            float64      `bson:"ops"`
-	ReadersCreated float64      `bson:"readersCreated"`
-	Bytes          float64      `bson:"bytes"`
+	Getmores       *MetricStats `bson:"getmores" json:"getmores"`
+	Ops            float64      `bson:"ops" json:"ops"`
+	ReadersCreated float64      `bson:"readersCreated" json:"readersCreated"`
+	Bytes          float64      `bson:"bytes" json:"bytes"`
 }
 
 type Operation struct {
-	Fastmod        float64 `bson:"fastmod"`
-	Idhack         float64 `bson:"idhack"`
-	ScanAndOrder   float64 `bson:"scanAndOrder"`
-	WriteConflicts float64 `bson:"writeConflicts"`
+	Fastmod        float64 `bson:"fastmod" json:"fastmod"`
+	Idhack         float64 `bson:"idhack" json:"idhack"`
+	ScanAndOrder   float64 `bson:"scanAndOrder" json:"scanAndOrder"`
+	WriteConflicts float64 `bson:"writeConflicts" json:"writeConflicts"`
 }
 
 type QueryExecutor struct {
-	Scanned        float64 `bson:"scanned"`
-	ScannedObjects float64 `bson:"scannedObjects"`
+	Scanned        float64 `bson:"scanned" json:"scanned"`
+	ScannedObjects float64 `bson:"scannedObjects" json:"scannedObjects"`
 }
 
 type Ttl struct {
-	DeletedDocuments float64 `bson:"deletedDocuments"`
-	Passes           float64 `bson:"passes"`
+	DeletedDocuments float64 `bson:"deletedDocuments" json:"deletedDocuments"`
+	Passes           float64 `bson:"passes" json:"passes"`
 }
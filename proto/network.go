@@ -1,7 +1,7 @@
 package proto
 
 type Network struct {
-	BytesIn     float64 `bson:"bytesIn"`
-	BytesOut    float64 `bson:"bytesOut"`
-	NumRequests float64 `bson:"numRequests"`
+	BytesIn     float64 `bson:"bytesIn" json:"bytesIn"`
+	BytesOut    float64 `bson:"bytesOut" json:"bytesOut"`
+	NumRequests float64 `bson:"numRequests" json:"numRequests"`
 }
@@ -1,10 +1,10 @@
 package proto
 
 type Opcounters struct {
-	Command float64 `bson:"command"`
-	Delete  float64 `bson:"delete"`
-	Getmore float64 `bson:"getmore"`
-	Insert  float64 `bson:"insert"`
-	Query   float64 `bson:"query"`
-	Update  float64 `bson:"update"`
+	Command float64 `bson:"command" json:"command"`
+	Delete  float64 `bson:"delete" json:"delete"`
+	Getmore float64 `bson:"getmore" json:"getmore"`
+	Insert  float64 `bson:"insert" json:"insert"`
+	Query   float64 `bson:"query" json:"query"`
+	Update  float64 `bson:"update" json:"update"`
 }
@@ -1,13 +1,13 @@
 package proto
 
 type Repl struct {
-	Rbid       float64  `bson:"rbid"`
-	SetVersion float64  `bson:"setVersion"`
-	ElectionId string   `bson:"electionId"`
-	Primary    string   `bson:"primary"`
-	Me         string   `bson:"me"`
-	Secondary  bool     `bson:"secondary"`
-	SetName    string   `bson:"setName"`
-	Hosts      []string `bson:"hosts"`
-	Ismaster   bool     `bson:"ismaster"`
+	Rbid       float64  `bson:"rbid" json:"rbid"`
+	SetVersion float64  `bson:"setVersion" json:"setVersion"`
+	ElectionId string   `bson:"electionId" json:"electionId"`
+	Primary    string   `bson:"primary" json:"primary"`
+	Me         string   `bson:"me" json:"me"`
+	Secondary  bool     `bson:"secondary" json:"secondary"`
+	SetName    string   `bson:"setName" json:"setName"`
+	Hosts      []string `bson:"hosts" json:"hosts"`
+	Ismaster   bool     `bson:"ismaster" json:"ismaster"`
 }
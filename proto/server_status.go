@@ -3,187 +3,187 @@ package proto
 import "time"
 
 type ServerStatus struct {
-	Host               string                 `bson:"host"`
-	Version            string                 `bson:"version"`
-	Process            string                 `bson:"process"`
-	Pid                int64                  `bson:"pid"`
-	Uptime             int64                  `bson:"uptime"`
-	UptimeMillis       int64                  `bson:"uptimeMillis"`
-	UptimeEstimate     int64                  `bson:"uptimeEstimate"`
-	LocalTime          time.Time              `bson:"localTime"`
-	Asserts            map[string]int64       `bson:"asserts"`
-	BackgroundFlushing *FlushStats            `bson:"backgroundFlushing"`
-	ExtraInfo          *ExtraInfo             `bson:"extra_info"`
-	Connections        *ConnectionStats       `bson:"connections"`
-	Dur                *DurStats              `bson:"dur"`
-	GlobalLock         *GlobalLockStats       `bson:"globalLock"`
-	Locks              map[string]LockStats   `bson:"locks,omitempty"`
-	Network            *NetworkStats          `bson:"network"`
-	Opcounters         *OpcountStats          `bson:"opcounters"`
-	OpcountersRepl     *OpcountStats          `bson:"opcountersRepl"`
-	RecordStats        *DBRecordStats         `bson:"recordStats"`
-	Mem                *MemStats              `bson:"mem"`
-	Repl               *ReplStatus            `bson:"repl"`
-	ShardCursorType    map[string]interface{} `bson:"shardCursorType"`
-	StorageEngine      map[string]string      `bson:"storageEngine"`
-	WiredTiger         *WiredTiger            `bson:"wiredTiger"`
+	Host               string                 `bson:"host" json:"host"`
+	Version            string                 `bson:"version" json:"version"`
+	Process            string                 `bson:"process" json:"process"`
+	Pid                int64                  `bson:"pid" json:"pid"`
+	Uptime             int64                  `bson:"uptime" json:"uptime"`
+	UptimeMillis       int64                  `bson:"uptimeMillis" json:"uptimeMillis"`
+	UptimeEstimate     int64                  `bson:"uptimeEstimate" json:"uptimeEstimate"`
+	LocalTime          time.Time              `bson:"localTime" json:"localTime"`
+	Asserts            map[string]int64       `bson:"asserts" json:"asserts"`
+	BackgroundFlushing *FlushStats            `bson:"backgroundFlushing" json:"backgroundFlushing"`
+	ExtraInfo          *ExtraInfo             `bson:"extra_info" json:"extraInfo"`
+	Connections        *ConnectionStats       `bson:"connections" json:"connections"`
+	Dur                *DurStats              `bson:"dur" json:"dur"`
+	GlobalLock         *GlobalLockStats       `bson:"globalLock" json:"globalLock"`
+	Locks              map[string]LockStats   `bson:"locks,omitempty" json:"locks,omitempty"`
+	Network            *NetworkStats          `bson:"network" json:"network"`
+	Opcounters         *OpcountStats          `bson:"opcounters" json:"opcounters"`
+	OpcountersRepl     *OpcountStats          `bson:"opcountersRepl" json:"opcountersRepl"`
+	RecordStats        *DBRecordStats         `bson:"recordStats" json:"recordStats"`
+	Mem                *MemStats              `bson:"mem" json:"mem"`
+	Repl               *ReplStatus            `bson:"repl" json:"repl"`
+	ShardCursorType    map[string]interface{} `bson:"shardCursorType" json:"shardCursorType"`
+	StorageEngine      map[string]string      `bson:"storageEngine" json:"storageEngine"`
+	WiredTiger         *WiredTiger            `bson:"wiredTiger" json:"wiredTiger"`
 }
 
 // WiredTiger stores information related to the WiredTiger storage engine.
 type WiredTiger struct {
-	Transaction TransactionStats       `bson:"transaction"`
-	Concurrent  ConcurrentTransactions `bson:"concurrentTransactions"`
-	Cache       CacheStats             `bson:"cache"`
+	Transaction TransactionStats       `bson:"transaction" json:"transaction"`
+	Concurrent  ConcurrentTransactions `bson:"concurrentTransactions" json:"concurrent"`
+	Cache       CacheStats             `bson:"cache" json:"cache"`
 }
 
 type ConcurrentTransactions struct {
-	Write ConcurrentTransStats `bson:"write"`
-	Read  ConcurrentTransStats `bson:"read"`
+	Write ConcurrentTransStats `bson:"write" json:"write"`
+	Read  ConcurrentTransStats `bson:"read" json:"read"`
 }
 
 type ConcurrentTransStats struct {
-	Out int64 `bson:"out"`
+	Out int64 `bson:"out" json:"out"`
 }
 
 // CacheStats stores cache statistics for WiredTiger.
 type CacheStats struct {
-	TrackedDirtyBytes  int64 `bson:"tracked dirty bytes in the cache"`
-	CurrentCachedBytes int64 `bson:"bytes currently in the cache"`
-	MaxBytesConfigured int64 `bson:"maximum bytes configured"`
+	TrackedDirtyBytes  int64 `bson:"tracked dirty bytes in the cache" json:"trackedDirtyBytes"`
+	CurrentCachedBytes int64 `bson:"bytes currently in the cache" json:"currentCachedBytes"`
+	MaxBytesConfigured int64 `bson:"maximum bytes configured" json:"maxBytesConfigured"`
 }
 
 // TransactionStats stores transaction checkpoints in WiredTiger.
 type TransactionStats struct {
-	TransCheckpoints int64 `bson:"transaction checkpoints"`
+	TransCheckpoints int64 `bson:"transaction checkpoints" json:"transCheckpoints"`
 }
 
 // ReplStatus stores data related to replica sets.
 type ReplStatus struct {
-	SetName      string      `bson:"setName"`
-	IsMaster     interface{} `bson:"ismaster"`
-	Secondary    interface{} `bson:"secondary"`
-	IsReplicaSet interface{} `bson:"isreplicaset"`
-	ArbiterOnly  interface{} `bson:"arbiterOnly"`
-	Hosts        []string    `bson:"hosts"`
-	Passives     []string    `bson:"passives"`
-	Me           string      `bson:"me"`
+	SetName      string      `bson:"setName" json:"setName"`
+	IsMaster     interface{} `bson:"ismaster" json:"isMaster"`
+	Secondary    interface{} `bson:"secondary" json:"secondary"`
+	IsReplicaSet interface{} `bson:"isreplicaset" json:"isReplicaSet"`
+	ArbiterOnly  interface{} `bson:"arbiterOnly" json:"arbiterOnly"`
+	Hosts        []string    `bson:"hosts" json:"hosts"`
+	Passives     []string    `bson:"passives" json:"passives"`
+	Me           string      `bson:"me" json:"me"`
 }
 
 // DBRecordStats stores data related to memory operations across databases.
 type DBRecordStats struct {
-	AccessesNotInMemory       int64                     `bson:"accessesNotInMemory"`
-	PageFaultExceptionsThrown int64                     `bson:"pageFaultExceptionsThrown"`
-	DBRecordAccesses          map[string]RecordAccesses `bson:",inline"`
+	AccessesNotInMemory       int64                     `bson:"accessesNotInMemory" json:"accessesNotInMemory"`
+	PageFaultExceptionsThrown int64                     `bson:"pageFaultExceptionsThrown" json:"pageFaultExceptionsThrown"`
+	DBRecordAccesses          map[string]RecordAccesses `bson:",inline" json:"dbRecordAccesses"`
 }
 
 // RecordAccesses stores data related to memory operations scoped to a database.
 type RecordAccesses struct {
-	AccessesNotInMemory       int64 `bson:"accessesNotInMemory"`
-	PageFaultExceptionsThrown int64 `bson:"pageFaultExceptionsThrown"`
+	AccessesNotInMemory       int64 `bson:"accessesNotInMemory" json:"accessesNotInMemory"`
+	PageFaultExceptionsThrown int64 `bson:"pageFaultExceptionsThrown" json:"pageFaultExceptionsThrown"`
 }
 
 // MemStats stores data related to memory statistics.
 type MemStats struct {
-	Bits              int64       `bson:"bits"`
-	Resident          int64       `bson:"resident"`
-	Virtual           int64       `bson:"virtual"`
-	Supported         interface{} `bson:"supported"`
-	Mapped            int64       `bson:"mapped"`
-	MappedWithJournal int64       `bson:"mappedWithJournal"`
+	Bits              int64       `bson:"bits" json:"bits"`
+	Resident          int64       `bson:"resident" json:"resident"`
+	Virtual           int64       `bson:"virtual" json:"virtual"`
+	Supported         interface{} `bson:"supported" json:"supported"`
+	Mapped            int64       `bson:"mapped" json:"mapped"`
+	MappedWithJournal int64       `bson:"mappedWithJournal" json:"mappedWithJournal"`
 }
 
 // FlushStats stores information about memory flushes.
 type FlushStats struct {
-	Flushes      int64     `bson:"flushes"`
-	TotalMs      int64     `bson:"total_ms"`
-	AverageMs    float64   `bson:"average_ms"`
-	LastMs       int64     `bson:"last_ms"`
-	LastFinished time.Time `bson:"last_finished"`
+	Flushes      int64     `bson:"flushes" json:"flushes"`
+	TotalMs      int64     `bson:"total_ms" json:"totalMs"`
+	AverageMs    float64   `bson:"average_ms" json:"averageMs"`
+	LastMs       int64     `bson:"last_ms" json:"lastMs"`
+	LastFinished time.Time `bson:"last_finished" json:"lastFinished"`
 }
 
 // ConnectionStats stores information related to incoming database connections.
 type ConnectionStats struct {
-	Current      int64 `bson:"current"`
-	Available    int64 `bson:"available"`
-	TotalCreated int64 `bson:"totalCreated"`
+	Current      int64 `bson:"current" json:"current"`
+	Available    int64 `bson:"available" json:"available"`
+	TotalCreated int64 `bson:"totalCreated" json:"totalCreated"`
 }
 
 // DurTiming stores information related to journaling.
 type DurTiming struct {
-	Dt               int64 `bson:"dt"`
-	PrepLogBuffer    int64 `bson:"prepLogBuffer"`
-	WriteToJournal   int64 `bson:"writeToJournal"`
-	WriteToDataFiles int64 `bson:"writeToDataFiles"`
-	RemapPrivateView int64 `bson:"remapPrivateView"`
+	Dt               int64 `bson:"dt" json:"dt"`
+	PrepLogBuffer    int64 `bson:"prepLogBuffer" json:"prepLogBuffer"`
+	WriteToJournal   int64 `bson:"writeToJournal" json:"writeToJournal"`
+	WriteToDataFiles int64 `bson:"writeToDataFiles" json:"writeToDataFiles"`
+	RemapPrivateView int64 `bson:"remapPrivateView" json:"remapPrivateView"`
 }
 
 // DurStats stores information related to journaling statistics.
 type DurStats struct {
-	Commits            int64 `bson:"commits"`
-	JournaledMB        int64 `bson:"journaledMB"`
-	WriteToDataFilesMB int64 `bson:"writeToDataFilesMB"`
-	Compression        int64 `bson:"compression"`
-	CommitsInWriteLock int64 `bson:"commitsInWriteLock"`
-	EarlyCommits       int64 `bson:"earlyCommits"`
-	TimeMs             DurTiming
+	Commits            int64     `bson:"commits" json:"commits"`
+	JournaledMB        int64     `bson:"journaledMB" json:"journaledMB"`
+	WriteToDataFilesMB int64     `bson:"writeToDataFilesMB" json:"writeToDataFilesMB"`
+	Compression        int64     `bson:"compression" json:"compression"`
+	CommitsInWriteLock int64     `bson:"commitsInWriteLock" json:"commitsInWriteLock"`
+	EarlyCommits       int64     `bson:"earlyCommits" json:"earlyCommits"`
+	TimeMs             DurTiming `json:"timeMs"`
 }
 
 // QueueStats stores the number of queued read/write operations.
 type QueueStats struct {
-	Total   int64 `bson:"total"`
-	Readers int64 `bson:"readers"`
-	Writers int64 `bson:"writers"`
+	Total   int64 `bson:"total" json:"total"`
+	Readers int64 `bson:"readers" json:"readers"`
+	Writers int64 `bson:"writers" json:"writers"`
 }
 
 // ClientStats stores the number of active read/write operations.
 type ClientStats struct {
-	Total   int64 `bson:"total"`
-	Readers int64 `bson:"readers"`
-	Writers int64 `bson:"writers"`
+	Total   int64 `bson:"total" json:"total"`
+	Readers int64 `bson:"readers" json:"readers"`
+	Writers int64 `bson:"writers" json:"writers"`
 }
 
 // GlobalLockStats stores information related locks in the MMAP storage engine.
 type GlobalLockStats struct {
-	TotalTime     int64        `bson:"totalTime"`
-	LockTime      int64        `bson:"lockTime"`
-	CurrentQueue  *QueueStats  `bson:"currentQueue"`
-	ActiveClients *ClientStats `bson:"activeClients"`
+	TotalTime     int64        `bson:"totalTime" json:"totalTime"`
+	LockTime      int64        `bson:"lockTime" json:"lockTime"`
+	CurrentQueue  *QueueStats  `bson:"currentQueue" json:"currentQueue"`
+	ActiveClients *ClientStats `bson:"activeClients" json:"activeClients"`
 }
 
 // NetworkStats stores information related to network traffic.
 type NetworkStats struct {
-	BytesIn     int64 `bson:"bytesIn"`
-	BytesOut    int64 `bson:"bytesOut"`
-	NumRequests int64 `bson:"numRequests"`
+	BytesIn     int64 `bson:"bytesIn" json:"bytesIn"`
+	BytesOut    int64 `bson:"bytesOut" json:"bytesOut"`
+	NumRequests int64 `bson:"numRequests" json:"numRequests"`
 }
 
 // OpcountStats stores information related to comamnds and basic CRUD operations.
 type OpcountStats struct {
-	Insert  int64 `bson:"insert"`
-	Query   int64 `bson:"query"`
-	Update  int64 `bson:"update"`
-	Delete  int64 `bson:"delete"`
-	GetMore int64 `bson:"getmore"`
-	Command int64 `bson:"command"`
+	Insert  int64 `bson:"insert" json:"insert"`
+	Query   int64 `bson:"query" json:"query"`
+	Update  int64 `bson:"update" json:"update"`
+	Delete  int64 `bson:"delete" json:"delete"`
+	GetMore int64 `bson:"getmore" json:"getMore"`
+	Command int64 `bson:"command" json:"command"`
 }
 
 // ReadWriteLockTimes stores time spent holding read/write locks.
 type ReadWriteLockTimes struct {
-	Read       int64 `bson:"R"`
-	Write      int64 `bson:"W"`
-	ReadLower  int64 `bson:"r"`
-	WriteLower int64 `bson:"w"`
+	Read       int64 `bson:"R" json:"read"`
+	Write      int64 `bson:"W" json:"write"`
+	ReadLower  int64 `bson:"r" json:"readLower"`
+	WriteLower int64 `bson:"w" json:"writeLower"`
 }
 
 // LockStats stores information related to time spent acquiring/holding locks
 // for a given database.
 type LockStats struct {
-	TimeLockedMicros    ReadWriteLockTimes `bson:"timeLockedMicros"`
-	TimeAcquiringMicros ReadWriteLockTimes `bson:"timeAcquiringMicros"`
+	TimeLockedMicros    ReadWriteLockTimes `bson:"timeLockedMicros" json:"timeLockedMicros"`
+	TimeAcquiringMicros ReadWriteLockTimes `bson:"timeAcquiringMicros" json:"timeAcquiringMicros"`
 
 	// AcquireCount and AcquireWaitCount are new fields of the lock stats only populated on 3.0 or newer.
 	// Typed as a pointer so that if it is nil, mongostat can assume the field is not populated
 	// with real namespace data.
-	AcquireCount     *ReadWriteLockTimes `bson:"acquireCount,omitempty"`
-	AcquireWaitCount *ReadWriteLockTimes `bson:"acquireWaitCount,omitempty"`
+	AcquireCount     *ReadWriteLockTimes `bson:"acquireCount,omitempty" json:"acquireCount,omitempty"`
+	AcquireWaitCount *ReadWriteLockTimes `bson:"acquireWaitCount,omitempty" json:"acquireWaitCount,omitempty"`
 }
@@ -1,11 +1,11 @@
 package proto
 
 type Shard struct {
-	ID   string `bson:"_id"`
-	Host string `bson:"host"`
+	ID   string `bson:"_id" json:"id"`
+	Host string `bson:"host" json:"host"`
 }
 
 type ShardsInfo struct {
-	Shards []Shard `bson:"shards"`
-	OK     int     `bson:"ok"`
+	Shards []Shard `bson:"shards" json:"shards"`
+	OK     int     `bson:"ok" json:"ok"`
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/percona/pt-mongodb-summary/db"
+)
+
+// Version and GitCommit identify the build in the reports. They are set when
+// building:
+//
+//	go build -ldflags "-X main.Version=3.0.0 -X main.GitCommit=$(git rev-parse --short HEAD)"
+var (
+	Version   = "dev"
+	GitCommit = ""
+)
+
+// runInfo tells when, how and by which version of the tool the reports were
+// produced
+type runInfo struct {
+	ToolVersion string    `json:"toolVersion"`
+	GitCommit   string    `json:"gitCommit"`
+	StartTime   time.Time `json:"startTime"` // UTC
+	// CommandLine has the passwords replaced by xxxxx
+	CommandLine string `json:"commandLine"`
+	// Seeds are the hosts given in --hosts, without credentials
+	Seeds          []string      `json:"seeds"`
+	Duration       time.Duration `json:"duration"` // nanoseconds in JSON
+	HostsContacted int           `json:"hostsContacted"`
+	// FailedCommands is the number of connections and commands to the
+	// servers that failed
+	FailedCommands int `json:"failedCommands"`
+	// Bundle is the bundle given in --from-bundle, if the reports were
+	// written from one instead of from the servers
+	Bundle string `json:"bundle,omitempty"`
+}
+
+// newRunInfo returns the run metadata known when the tool starts. args are
+// the program name and the command line arguments.
+func newRunInfo(args []string, seeds []seed, start time.Time) *runInfo {
+	run := &runInfo{
+		ToolVersion: Version,
+		GitCommit:   GitCommit,
+		StartTime:   start.UTC(),
+		CommandLine: commandLine(args),
+	}
+	for _, s := range seeds {
+		run.Seeds = append(run.Seeds, s.hostname)
+	}
+	return run
+}
+
+// finish sets the metadata known once all the reports are collected
+func (run *runInfo) finish(managers []*db.ConnectionManager) {
+	run.Duration = timeNow().Sub(run.StartTime)
+
+	hosts := make(map[string]bool)
+	for _, cm := range managers {
+		for _, host := range cm.Hosts() {
+			hosts[host] = true
+		}
+	}
+	run.HostsContacted = len(hosts)
+
+	run.FailedCommands = 0
+	for _, cm := range managers {
+		run.FailedCommands += cm.Failures()
+	}
+}
+
+// secretFlags are the flags whose values are not written in the reports
+var secretFlags = map[string]bool{
+	"password":                      true,
+	"tlsCertificateKeyFilePassword": true,
+}
+
+// commandLine returns args as a shell command line without the passwords
+// given in flags, connection strings and proxy URLs
+func commandLine(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	words := []string{filepath.Base(args[0])}
+	for _, arg := range db.RedactArgs(args[1:], secretFlags) {
+		words = append(words, shellQuote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// shellQuote quotes s if it has characters the shell would interpret
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=+.,:/@%") == "" {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
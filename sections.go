@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// reportSections are the sections that can be selected with --sections and
+// --skip-sections, in their default order. The header, which identifies the
+// deployment, is always written.
+var reportSections = []string{"instances", "hostinfo", "collections", "runningops", "security", "oplog"}
+
+// selectSections returns the sections to collect and write, in order, given
+// the comma separated lists in --sections and --skip-sections. An empty
+// --sections means all the sections in their default order.
+func selectSections(sections, skip string) ([]string, error) {
+	selected := reportSections
+	if sections != "" {
+		var err error
+		if selected, err = parseSections(sections); err != nil {
+			return nil, err
+		}
+	}
+	skipped, err := parseSections(skip)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for _, name := range selected {
+		if !hasSection(skipped, name) && !hasSection(result, name) {
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
+
+func parseSections(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !hasSection(reportSections, name) {
+			return nil, fmt.Errorf("invalid section %q. Valid sections are: %s", name, strings.Join(reportSections, ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func hasSection(sections []string, name string) bool {
+	for _, section := range sections {
+		if section == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectSections(t *testing.T) {
+	tests := []struct {
+		sections string
+		skip     string
+		expect   []string
+		wantErr  bool
+	}{
+		{"", "", reportSections, false},
+		{"", "collections", []string{"instances", "hostinfo", "runningops", "security", "oplog"}, false},
+		{"oplog, instances,security", "", []string{"oplog", "instances", "security"}, false},
+		{"oplog,instances,oplog", "instances", []string{"oplog"}, false},
+		{"", "instances,hostinfo,collections,runningops,security,oplog", []string{}, false},
+		{"replicas", "", nil, true},
+		{"", "oplgo", nil, true},
+	}
+	for _, test := range tests {
+		got, err := selectSections(test.sections, test.skip)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q/%q: got error %v, want error: %v", test.sections, test.skip, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expect) {
+			t.Errorf("%q/%q: invalid sections. got %v, expect %v", test.sections, test.skip, got, test.expect)
+		}
+	}
+}
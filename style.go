@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// defaultWidth is the width of the text report when it is not written to a
+// terminal and --width is not given
+const defaultWidth = 96
+
+// minWidth is the narrowest width the section titles are adapted to
+const minWidth = 40
+
+// severityColors are the ANSI colors of the severity markers
+var severityColors = map[severity]string{
+	severityWarning:  "\x1b[33m",
+	severityCritical: "\x1b[31m",
+	severityError:    "\x1b[1;31m",
+}
+
+const colorReset = "\x1b[0m"
+
+// textStyle adapts the text templates to where they are written. The zero
+// value is plain text of the default width.
+type textStyle struct {
+	color bool
+	width int
+}
+
+// newTextStyle returns the style for writing to out. Colors and the terminal
+// width are only used if out is a terminal. --no-color and --width override
+// them.
+func newTextStyle(opts options, out *os.File) textStyle {
+	style := textStyle{width: opts.Width}
+	fd := int(out.Fd())
+	if !terminal.IsTerminal(fd) {
+		return style
+	}
+	style.color = !opts.NoColor
+	if style.width == 0 {
+		if width, _, err := terminal.GetSize(fd); err == nil {
+			style.width = width
+		}
+	}
+	return style
+}
+
+// funcs returns the style dependent template functions
+func (s textStyle) funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"color": s.colorize,
+		"title": s.title,
+	}
+}
+
+// colorize wraps text in the color of the severity
+func (s textStyle) colorize(sev severity, text string) string {
+	color, ok := severityColors[sev]
+	if !s.color || !ok {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// title returns a section title as wide as the report: # Title ######
+func (s textStyle) title(name string) string {
+	width := s.width
+	if width == 0 {
+		width = defaultWidth
+	}
+	if width < minWidth {
+		width = minWidth
+	}
+	title := "# " + name + " "
+	if n := width - utf8.RuneCountInString(title); n > 0 {
+		return title + strings.Repeat("#", n)
+	}
+	return title + "###"
+}
+
+// colWidth returns the width of a column: the longest of the header and the
+// field of every element in list
+func colWidth(header string, list interface{}, field string) (int, error) {
+	width := utf8.RuneCountInString(header)
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return 0, fmt.Errorf("colWidth: %T is not a list", list)
+	}
+	for i := 0; i < v.Len(); i++ {
+		elem := reflect.Indirect(v.Index(i))
+		if elem.Kind() != reflect.Struct {
+			return 0, fmt.Errorf("colWidth: %s is not a struct", elem.Type())
+		}
+		f := elem.FieldByName(field)
+		if !f.IsValid() {
+			return 0, fmt.Errorf("colWidth: %s has no field %s", elem.Type(), field)
+		}
+		if n := utf8.RuneCountInString(fmt.Sprint(f.Interface())); n > width {
+			width = n
+		}
+	}
+	return width, nil
+}
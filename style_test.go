@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+func TestTextStyle(t *testing.T) {
+	tests := []struct {
+		style  textStyle
+		expect string
+	}{
+		{textStyle{}, "# Oplog " + strings.Repeat("#", defaultWidth-8)},
+		{textStyle{width: 60}, "# Oplog " + strings.Repeat("#", 60-8)},
+		{textStyle{width: 10}, "# Oplog " + strings.Repeat("#", minWidth-8)},
+	}
+	for _, test := range tests {
+		if got := test.style.title("Oplog"); got != test.expect {
+			t.Errorf("title with width %d: got %q, expect %q", test.style.width, got, test.expect)
+		}
+	}
+
+	if got := (textStyle{}).colorize(severityCritical, "down"); got != "down" {
+		t.Errorf("colors must not be used unless enabled: %q", got)
+	}
+	if got := (textStyle{color: true}).colorize(severityCritical, "down"); got != "\x1b[31mdown\x1b[0m" {
+		t.Errorf("invalid critical color: %q", got)
+	}
+	if got := (textStyle{color: true}).colorize(severityOK, "up"); got != "up" {
+		t.Errorf("ok must not be colored: %q", got)
+	}
+}
+
+func TestColWidth(t *testing.T) {
+	members := []proto.Members{{Name: "db1:27017"}, {Name: "a-much-longer-hostname.example.com:27017"}}
+	if n, err := colWidth("Host", members, "Name"); err != nil || n != 40 {
+		t.Errorf("invalid width %d: %v", n, err)
+	}
+	if n, err := colWidth("Hostname of the member", members[:1], "Name"); err != nil || n != 22 {
+		t.Errorf("the header must be taken into account: %d, %v", n, err)
+	}
+	if _, err := colWidth("Host", members, "Nope"); err == nil {
+		t.Errorf("expected error for a field that doesn't exist")
+	}
+	if _, err := colWidth("Host", "db1", "Name"); err == nil {
+		t.Errorf("expected error for a value that is not a list")
+	}
+}
+
+func TestTextOutputWidths(t *testing.T) {
+	td := testReport()
+	td.ReplicaMembers = []proto.Members{
+		{Id: 0, Name: "db1:27017", StateStr: "PRIMARY", Health: 1, Set: "rs0"},
+		{Id: 1, Name: "a-much-longer-hostname.example.com:27017", StateStr: "RECOVERING", Health: 1, Set: "rs0"},
+	}
+	r, err := newTextRenderer("", textStyle{color: true, width: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf, results{Reports: []templateData{td}}); err != nil {
+		t.Fatalf("cannot write the text report: %s", err)
+	}
+	for _, want := range []string{
+		"\n# Instances " + strings.Repeat("#", 50-12) + "\n",
+		"\n ID Host                                     Type       ReplSet\n",
+		"\n  0 db1:27017                                PRIMARY    rs0\n",
+		"\n  1 a-much-longer-hostname.example.com:27017 \x1b[33mRECOVERING\x1b[0m rs0\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, buf)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package templates
+
+const Collections = `
+{{title "Collections"}}
+{{- if .Databases}}
+Database             Collection                        Documents          Size   Indexes
+{{- range $db := .Databases}}{{range .Collections}}
+{{padRight 20 $db.Name}} {{padRight 30 .Name}} {{padLeft 12 .Count}} {{padLeft 13 (bytes .Size)}} {{padLeft 9 .Nindexes}}
+{{- end}}{{end}}
+{{else}}
+                                          No collections information
+{{end}}
+`
@@ -0,0 +1,15 @@
+package templates
+
+// Diff is the text output of the diff command. It is executed on the
+// changes between two snapshots.
+const Diff = `{{title (printf "Changes from %s to %s" .A .B)}}
+{{- if .Changes}}
+{{- $deployment := colWidth "Deployment" .Changes "Deployment"}}
+{{- $section := colWidth "Section" .Changes "Section"}}
+{{padRight $deployment "Deployment"}} {{padRight $section "Section"}} Change
+{{- range .Changes}}
+{{padRight $deployment .Deployment}} {{padRight $section .Section}} {{.Message}}
+{{- end}}
+{{else}}
+No changes
+{{end}}`
@@ -0,0 +1,9 @@
+package templates
+
+// Findings is written after the sections of each report when the checks found
+// something or some data could not be collected
+const Findings = `{{with .Findings}}
+{{title "Findings"}}
+{{- $host := colWidth "" . "Host"}}
+{{range .}}{{color .Severity (padRight 9 .Severity)}} {{padRight 12 (default "-" .Section)}} {{padRight $host .Host}} {{.Message}}
+{{end}}{{end}}`
@@ -0,0 +1,8 @@
+package templates
+
+const Header = `{{title "Summary"}}
+             Answered by | {{.AnsweredBy}}{{if .Direct}} (direct connection){{end}}
+{{- if .Seeds}}
+                   Seeds | {{join .Seeds ", "}}
+{{- end}}
+`
@@ -1,22 +1,24 @@
 package templates
 
 const HostInfo = `# This host
-# Mongo Executable #############################################################################
+{{title "Mongo Executable"}}
        Path to executable | {{.ProcInfo.Path}}
               Has symbols | No
-# Report On {{.ThisHostID}} ########################################
+{{title (printf "Report On %d" .ThisHostID)}}
                      User | {{.ProcInfo.UserName}}
                 PID Owner | {{.ServerStatus.Process}}
-                     Time | {{.ProcInfo.CreateTime}}
-                 Hostname | {{.HostInfo.System.Hostname}}
+                     Time | {{time .ServerStatus.LocalTime}}
+                 Hostname | {{with .HostInfo.System}}{{.Hostname}}{{end}}
                   Version | {{.ServerStatus.Version}}
-                 Built On | {{.HostInfo.Os.Type}} {{.HostInfo.System.CpuArch}}
-                  Started | {{.ProcInfo.CreateTime}}
+                 Built On | {{with .HostInfo.Os}}{{.Type}}{{end}} {{with .HostInfo.System}}{{.CpuArch}}{{end}}
+                  Started | {{time .ProcInfo.CreateTime}} ({{since .ProcInfo.CreateTime}})
+                   Uptime | {{duration .ServerStatus.Uptime}}
+                   Memory | {{with .HostInfo.System}}{{megabytes .MemSizeMB}}{{end}}
                 Databases | {{.HostInfo.DatabasesCount}}
               Collections | {{.HostInfo.CollectionsCount}}
                   Datadir | /data/db
                 Processes | {{.ProcessCount}}
              Process Type | {{.ServerStatus.Process}}
-                  ReplSet | {{.ServerStatus.Repl.SetName}}
-              Repl Status | {{.ReplicaSetStatus.MyState}}
+                  ReplSet | {{with .ServerStatus.Repl}}{{.SetName}}{{end}}
+              Repl Status | {{if .ReplicaSetStatus.Set}}{{replState .ReplicaSetStatus.MyState}}{{end}}
 `
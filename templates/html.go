@@ -0,0 +1,208 @@
+package templates
+
+// HTML is a self-contained page with the run, the reports of all the
+// deployments and their findings. It is executed with html/template on the
+// results. Tables with the
+// sortable class can be sorted by clicking on their headers. Rows and values
+// with the warn class are highlighted. Cells with a data-value attribute are
+// sorted by it instead of by their human readable text. Each report section is a template of
+// its own, written in the order of the report sections.
+const HTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>pt-mongodb-summary{{range $i, $r := .Reports}}{{if $i}},{{end}} {{$r.AnsweredBy}}{{end}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 14px; color: #222; margin: 2em; }
+h1 { font-size: 1.6em; border-bottom: 2px solid #888; padding-bottom: .2em; }
+details { margin: 1em 0; border: 1px solid #ccc; border-radius: 4px; padding: .5em 1em; }
+summary { font-weight: bold; font-size: 1.15em; cursor: pointer; }
+table { border-collapse: collapse; margin: .8em 0; }
+th, td { border: 1px solid #ddd; padding: .3em .7em; text-align: left; }
+th { background: #f0f0f0; }
+td.num { text-align: right; font-family: monospace; }
+table.sortable th { cursor: pointer; user-select: none; }
+table.sortable th:after { content: " \2195"; color: #aaa; }
+table.fields th { width: 14em; }
+tr.warn td, td.warn { background: #fde2e1; color: #8a1f11; font-weight: bold; }
+.muted { color: #888; }
+</style>
+</head>
+<body>
+{{with .Run}}
+<details open>
+<summary>Run</summary>
+<table class="fields">
+<tr><th>Tool version</th><td>{{.ToolVersion}}{{with .GitCommit}} ({{.}}){{end}}</td></tr>
+<tr><th>Started at</th><td>{{time .StartTime}}</td></tr>
+<tr><th>Command line</th><td>{{.CommandLine}}</td></tr>
+<tr><th>Seeds</th><td>{{join .Seeds ", "}}</td></tr>
+<tr><th>Duration</th><td>{{duration .Duration}}</td></tr>
+<tr><th>Hosts contacted</th><td class="num">{{.HostsContacted}}</td></tr>
+<tr><th>Failed commands</th><td class="num{{if .FailedCommands}} warn{{end}}">{{.FailedCommands}}</td></tr>
+{{with .Bundle}}<tr><th>From bundle</th><td>{{.}}</td></tr>{{end}}
+</table>
+</details>
+{{end}}
+{{range $r := .Reports}}
+<h1>Summary for {{.AnsweredBy}}{{if .Direct}} (direct connection){{end}}</h1>
+{{if .Seeds}}<p>Seeds: {{join .Seeds ", "}}</p>{{end}}
+
+{{range sections .}}
+{{- if eq . "instances"}}{{template "instances" $r}}
+{{- else if eq . "hostinfo"}}{{template "hostinfo" $r}}
+{{- else if eq . "collections"}}{{template "collections" $r}}
+{{- else if eq . "runningops"}}{{template "runningops" $r}}
+{{- else if eq . "security"}}{{template "security" $r}}
+{{- else if eq . "oplog"}}{{template "oplog" $r}}
+{{- end}}
+{{end}}
+{{template "findings" .Findings}}
+{{else}}
+<p class="muted">No reports</p>
+{{end}}
+{{with .Findings}}
+<h1>Findings</h1>
+{{template "findings" .}}
+{{end}}
+
+<script>
+function cellValue(cell) {
+  return cell.hasAttribute("data-value") ? cell.getAttribute("data-value") : cell.textContent;
+}
+document.querySelectorAll("table.sortable").forEach(function(table) {
+  table.querySelectorAll("th").forEach(function(th, col) {
+    var asc = true;
+    th.addEventListener("click", function() {
+      var tbody = table.tBodies[0];
+      var rows = Array.prototype.slice.call(tbody.rows);
+      rows.sort(function(a, b) {
+        var x = cellValue(a.cells[col]), y = cellValue(b.cells[col]);
+        var nx = parseFloat(x), ny = parseFloat(y);
+        var cmp = (!isNaN(nx) && !isNaN(ny)) ? nx - ny : x.localeCompare(y);
+        return asc ? cmp : -cmp;
+      });
+      asc = !asc;
+      rows.forEach(function(row) { tbody.appendChild(row); });
+    });
+  });
+});
+</script>
+</body>
+</html>
+{{- define "instances"}}
+<details open>
+<summary>Instances</summary>
+{{if .ReplicaMembers}}
+<table class="sortable">
+<thead><tr><th>ID</th><th>Host</th><th>Type</th><th>ReplSet</th><th>Health</th></tr></thead>
+<tbody>
+{{range .ReplicaMembers}}<tr{{if memberWarning .}} class="warn"{{end}}><td class="num">{{.Id}}</td><td>{{.Name}}</td><td>{{.StateStr}}</td><td>{{.Set}}</td><td class="num">{{.Health}}</td></tr>
+{{end}}</tbody>
+</table>
+{{else}}
+<p class="muted">No replica sets found</p>
+{{end}}
+</details>
+{{end}}
+{{- define "hostinfo"}}
+<details open>
+<summary>This host</summary>
+<table class="fields">
+<tr><th>Path to executable</th><td>{{.ProcInfo.Path}}</td></tr>
+<tr><th>User</th><td>{{.ProcInfo.UserName}}</td></tr>
+<tr><th>PID Owner</th><td>{{.ServerStatus.Process}}</td></tr>
+<tr><th>Time</th><td>{{time .ServerStatus.LocalTime}}</td></tr>
+<tr><th>Started</th><td>{{time .ProcInfo.CreateTime}} ({{since .ProcInfo.CreateTime}})</td></tr>
+<tr><th>Uptime</th><td>{{duration .ServerStatus.Uptime}}</td></tr>
+{{with .HostInfo.System}}<tr><th>Hostname</th><td>{{.Hostname}}</td></tr>
+<tr><th>Memory</th><td>{{megabytes .MemSizeMB}}</td></tr>{{end}}
+<tr><th>Version</th><td>{{.ServerStatus.Version}}</td></tr>
+<tr><th>Built On</th><td>{{with .HostInfo.Os}}{{.Type}}{{end}} {{with .HostInfo.System}}{{.CpuArch}}{{end}}</td></tr>
+<tr><th>Databases</th><td>{{.HostInfo.DatabasesCount}}</td></tr>
+<tr><th>Collections</th><td>{{.HostInfo.CollectionsCount}}</td></tr>
+<tr><th>Processes</th><td>{{.ProcessCount}}</td></tr>
+<tr><th>Process Type</th><td>{{.ServerStatus.Process}}</td></tr>
+{{with .ServerStatus.Repl}}<tr><th>ReplSet</th><td>{{.SetName}}</td></tr>{{end}}
+<tr><th>Repl Status</th><td>{{if .ReplicaSetStatus.Set}}{{replState .ReplicaSetStatus.MyState}}{{else}}-{{end}}</td></tr>
+</table>
+</details>
+{{end}}
+{{- define "collections"}}
+<details>
+<summary>Collections</summary>
+{{if .Databases}}
+<table class="sortable">
+<thead><tr><th>Database</th><th>Collection</th><th>Documents</th><th>Size</th><th>Storage size</th><th>Indexes</th><th>Index size</th></tr></thead>
+<tbody>
+{{range $db := .Databases}}{{range .Collections}}<tr><td>{{$db.Name}}</td><td>{{.Name}}</td><td class="num">{{.Count}}</td><td class="num" data-value="{{.Size}}">{{bytes .Size}}</td><td class="num" data-value="{{.StorageSize}}">{{bytes .StorageSize}}</td><td class="num">{{.Nindexes}}</td><td class="num" data-value="{{.TotalIndexSize}}">{{bytes .TotalIndexSize}}</td></tr>
+{{end}}{{end}}</tbody>
+</table>
+{{else}}
+<p class="muted">No collections information</p>
+{{end}}
+</details>
+{{end}}
+{{- define "runningops"}}
+<details open>
+<summary>Running Ops</summary>
+<table>
+<thead><tr><th>Type</th><th>Min</th><th>Max</th><th>Avg</th></tr></thead>
+<tbody>
+<tr><td>Insert</td><td class="num">{{.RunningOps.Insert.Min}}</td><td class="num">{{.RunningOps.Insert.Max}}</td><td class="num">{{.RunningOps.Insert.Avg}}/{{.SampleRate}}</td></tr>
+<tr><td>Query</td><td class="num">{{.RunningOps.Query.Min}}</td><td class="num">{{.RunningOps.Query.Max}}</td><td class="num">{{.RunningOps.Query.Avg}}/{{.SampleRate}}</td></tr>
+<tr><td>Update</td><td class="num">{{.RunningOps.Update.Min}}</td><td class="num">{{.RunningOps.Update.Max}}</td><td class="num">{{.RunningOps.Update.Avg}}/{{.SampleRate}}</td></tr>
+<tr><td>Delete</td><td class="num">{{.RunningOps.Delete.Min}}</td><td class="num">{{.RunningOps.Delete.Max}}</td><td class="num">{{.RunningOps.Delete.Avg}}/{{.SampleRate}}</td></tr>
+<tr><td>GetMore</td><td class="num">{{.RunningOps.GetMore.Min}}</td><td class="num">{{.RunningOps.GetMore.Max}}</td><td class="num">{{.RunningOps.GetMore.Avg}}/{{.SampleRate}}</td></tr>
+<tr><td>Command</td><td class="num">{{.RunningOps.Command.Min}}</td><td class="num">{{.RunningOps.Command.Max}}</td><td class="num">{{.RunningOps.Command.Avg}}/{{.SampleRate}}</td></tr>
+</tbody>
+</table>
+</details>
+{{end}}
+{{- define "security"}}
+<details open>
+<summary>Security</summary>
+{{with .Security}}
+<table class="fields">
+<tr><th>Users</th><td>{{.Users}}</td></tr>
+<tr><th>Roles</th><td>{{.Roles}}</td></tr>
+<tr><th>Auth</th><td{{if eq .Auth "disabled"}} class="warn"{{end}}>{{.Auth}}</td></tr>
+<tr><th>SSL</th><td{{if eq .SSL "disabled"}} class="warn"{{end}}>{{.SSL}}</td></tr>
+<tr><th>Connected as</th><td>{{default "-" .AuthUser}}</td></tr>
+<tr><th>Auth mechanism</th><td>{{.AuthMechanism}}</td></tr>
+<tr><th>User roles</th><td>{{default "-" (join .AuthUserRoles ", ")}}</td></tr>
+</table>
+{{else}}
+<p class="muted">No security information</p>
+{{end}}
+</details>
+{{end}}
+{{- define "oplog"}}
+<details open>
+<summary>Oplog</summary>
+{{with .Oplog}}
+<table class="fields">
+<tr><th>Oplog Size</th><td>{{megabytes .Size}}</td></tr>
+<tr><th>Oplog Used</th><td>{{megabytes .UsedMB}} ({{percent .UsedMB .Size}})</td></tr>
+<tr><th>Oplog Length</th><td>{{duration .TimeDiff}}</td></tr>
+<tr><th>Last Election</th><td>{{time .ElectionTime}}</td></tr>
+</table>
+{{else}}
+<p class="muted">No oplog information</p>
+{{end}}
+</details>
+{{end}}
+{{- define "findings"}}
+{{if .}}
+<details open>
+<summary>Findings</summary>
+<table class="sortable">
+<thead><tr><th>Severity</th><th>Section</th><th>Host</th><th>Message</th></tr></thead>
+<tbody>
+{{range .}}<tr{{if findingWarning .}} class="warn"{{end}}><td>{{.Severity}}</td><td>{{default "-" .Section}}</td><td>{{.Host}}</td><td>{{.Message}}</td></tr>
+{{end}}</tbody>
+</table>
+</details>
+{{end}}
+{{end}}
+`
@@ -0,0 +1,144 @@
+package templates
+
+// Markdown templates are executed with text/template. The cell function
+// escapes values so they don't break the tables. Functions that format
+// values return plain text so they can be used inside cell.
+
+// MarkdownRun is written once, before the reports, and it is executed on the
+// run metadata instead of on a report
+const MarkdownRun = `# Run
+
+| Field | Value |
+|-------|-------|
+| Tool version | {{cell .ToolVersion}}{{with .GitCommit}} ({{cell .}}){{end}} |
+| Started at | {{time .StartTime}} |
+| Command line | {{cell .CommandLine}} |
+| Seeds | {{cell (join .Seeds ", ")}} |
+| Duration | {{duration .Duration}} |
+| Hosts contacted | {{.HostsContacted}} |
+| Failed commands | {{.FailedCommands}} |
+{{with .Bundle}}| From bundle | {{cell .}} |
+{{end}}
+`
+
+const MarkdownHeader = `# Summary for {{cell .AnsweredBy}}{{if .Direct}} (direct connection){{end}}
+{{- if .Seeds}}
+
+Seeds: {{cell (join .Seeds ", ")}}
+{{- end}}
+`
+
+const MarkdownReplicas = `
+## Instances
+
+{{if .ReplicaMembers -}}
+| ID | Host | Type | ReplSet |
+|---:|------|------|---------|
+{{range .ReplicaMembers -}}
+| {{.Id}} | {{cell .Name}} | {{cell .StateStr}} | {{cell .Set}} |
+{{end -}}
+{{else -}}
+No replica sets found
+{{end}}`
+
+const MarkdownHostInfo = `
+## This host
+
+| Field | Value |
+|-------|-------|
+| Path to executable | {{cell .ProcInfo.Path}} |
+| User | {{cell .ProcInfo.UserName}} |
+| PID Owner | {{cell .ServerStatus.Process}} |
+| Time | {{time .ServerStatus.LocalTime}} |
+| Started | {{time .ProcInfo.CreateTime}} ({{since .ProcInfo.CreateTime}}) |
+| Uptime | {{duration .ServerStatus.Uptime}} |
+{{with .HostInfo.System -}}
+| Hostname | {{cell .Hostname}} |
+| Memory | {{megabytes .MemSizeMB}} |
+{{end -}}
+| Version | {{cell .ServerStatus.Version}} |
+| Built On | {{with .HostInfo.Os}}{{cell .Type}}{{end}} {{with .HostInfo.System}}{{cell .CpuArch}}{{end}} |
+| Databases | {{.HostInfo.DatabasesCount}} |
+| Collections | {{.HostInfo.CollectionsCount}} |
+| Processes | {{.ProcessCount}} |
+| Process Type | {{cell .ServerStatus.Process}} |
+{{with .ServerStatus.Repl -}}
+| ReplSet | {{cell .SetName}} |
+{{end -}}
+| Repl Status | {{if .ReplicaSetStatus.Set}}{{replState .ReplicaSetStatus.MyState}}{{else}}-{{end}} |
+`
+
+const MarkdownCollections = `
+## Collections
+
+{{if .Databases -}}
+| Database | Collection | Documents | Size | Storage size | Indexes | Index size |
+|----------|------------|----------:|-----:|-------------:|--------:|-----------:|
+{{range $db := .Databases}}{{range .Collections -}}
+| {{cell $db.Name}} | {{cell .Name}} | {{.Count}} | {{bytes .Size}} | {{bytes .StorageSize}} | {{.Nindexes}} | {{bytes .TotalIndexSize}} |
+{{end}}{{end -}}
+{{else -}}
+No collections information
+{{end}}`
+
+const MarkdownRunningOps = `
+## Running Ops
+
+| Type | Min | Max | Avg |
+|------|----:|----:|----:|
+| Insert | {{.RunningOps.Insert.Min}} | {{.RunningOps.Insert.Max}} | {{.RunningOps.Insert.Avg}}/{{.SampleRate}} |
+| Query | {{.RunningOps.Query.Min}} | {{.RunningOps.Query.Max}} | {{.RunningOps.Query.Avg}}/{{.SampleRate}} |
+| Update | {{.RunningOps.Update.Min}} | {{.RunningOps.Update.Max}} | {{.RunningOps.Update.Avg}}/{{.SampleRate}} |
+| Delete | {{.RunningOps.Delete.Min}} | {{.RunningOps.Delete.Max}} | {{.RunningOps.Delete.Avg}}/{{.SampleRate}} |
+| GetMore | {{.RunningOps.GetMore.Min}} | {{.RunningOps.GetMore.Max}} | {{.RunningOps.GetMore.Avg}}/{{.SampleRate}} |
+| Command | {{.RunningOps.Command.Min}} | {{.RunningOps.Command.Max}} | {{.RunningOps.Command.Avg}}/{{.SampleRate}} |
+`
+
+const MarkdownSecurity = `
+## Security
+
+{{with .Security -}}
+| Field | Value |
+|-------|-------|
+| Users | {{.Users}} |
+| Roles | {{.Roles}} |
+| Auth | {{cell .Auth}} |
+| SSL | {{cell .SSL}} |
+| Connected as | {{cell (default "-" .AuthUser)}} |
+| Auth mechanism | {{cell .AuthMechanism}} |
+| User roles | {{cell (default "-" (join .AuthUserRoles ", "))}} |
+{{else -}}
+No security information
+{{end}}`
+
+const MarkdownOplog = `
+## Oplog
+
+{{with .Oplog -}}
+| Field | Value |
+|-------|-------|
+| Oplog Size | {{megabytes .Size}} |
+| Oplog Used | {{megabytes .UsedMB}} ({{percent .UsedMB .Size}}) |
+| Oplog Length | {{duration .TimeDiff}} |
+| Last Election | {{time .ElectionTime}} |
+{{else -}}
+No oplog information
+{{end}}`
+
+// MarkdownFindings is written after the sections of each report
+const MarkdownFindings = `{{with .Findings}}
+## Findings
+
+` + markdownFindingsTable + `{{end}}`
+
+// MarkdownOtherFindings is written after all the reports, with the findings
+// that don't belong to any of them, like unreachable seeds
+const MarkdownOtherFindings = `{{with .Findings}}
+# Findings
+
+` + markdownFindingsTable + `{{end}}`
+
+const markdownFindingsTable = `| Severity | Section | Host | Message |
+|----------|---------|------|---------|
+{{range .}}| {{cell .Severity}} | {{cell (default "-" .Section)}} | {{cell .Host}} | {{cell .Message}} |
+{{end}}`
@@ -1,10 +1,10 @@
 package templates
 
-const Oplog = `
-# Oplog ########################################################################################
-Oplog Size     {{.Size}} Mb
-Oplog Used     {{.UsedMB}} Mb
-Oplog Length   {{.Running}}
-Last Election  {{.ElectionTime}}
+const Oplog = `{{with .Oplog}}
+{{title "Oplog"}}
+Oplog Size     {{megabytes .Size}}
+Oplog Used     {{megabytes .UsedMB}} ({{percent .UsedMB .Size}})
+Oplog Length   {{duration .TimeDiff}}
+Last Election  {{time .ElectionTime}}
 
-`
+{{end}}`
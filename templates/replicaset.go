@@ -1,13 +1,17 @@
 package templates
 
+// Replicas lists the replica set members. The Host and Type columns are as
+// wide as their longest value and the type of unhealthy members is colored.
 const Replicas = `
-# Instances ####################################################################################
-ID    Host                         Type                                 ReplSet  Engine Status
-{{- if .ReplicaMembers -}}
-{{- range .ReplicaMembers }} 
-{{printf "% 3d" .Id}} {{printf "%-30s" .Name}} {{printf "%-30s" .StateStr}} {{printf "%10s" .Set -}}
-{{end}}
-{{else}}																		  
+{{title "Instances"}}
+{{- if .ReplicaMembers}}
+{{- $host := colWidth "Host" .ReplicaMembers "Name"}}
+{{- $type := colWidth "Type" .ReplicaMembers "StateStr"}}
+ ID {{padRight $host "Host"}} {{padRight $type "Type"}} ReplSet
+{{- range .ReplicaMembers}}
+{{padLeft 3 .Id}} {{padRight $host .Name}} {{color (memberSeverity .) (padRight $type .StateStr)}} {{.Set}}
+{{- end}}
+{{else}}
                                           No replica sets found
 {{end}}
 
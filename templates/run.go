@@ -0,0 +1,17 @@
+package templates
+
+// Run is written once, before the reports, and it is executed on the run
+// metadata instead of on a report
+const Run = `{{title "Run"}}
+            Tool version | {{.ToolVersion}}{{with .GitCommit}} ({{.}}){{end}}
+              Started at | {{time .StartTime}}
+            Command line | {{.CommandLine}}
+                   Seeds | {{join .Seeds ", "}}
+                Duration | {{duration .Duration}}
+         Hosts contacted | {{.HostsContacted}}
+         Failed commands | {{.FailedCommands}}
+{{- with .Bundle}}
+             From bundle | {{.}}
+{{- end}}
+
+`
@@ -1,13 +1,13 @@
 package templates
 
 const RunningOps = `
-# Running Ops ##################################################################################
+{{title "Running Ops"}}
 
 Type         Min        Max        Avg
-Insert    {{printf "% 8d" .RunningOps.Insert.Min}}   {{printf "% 8d" .RunningOps.Insert.Max}}   {{printf "% 8d" .RunningOps.Insert.Avg}}/{{.SampleRate}}
-Query     {{printf "% 8d" .RunningOps.Query.Min}}   {{printf "% 8d" .RunningOps.Query.Max}}   {{printf "% 8d" .RunningOps.Query.Avg}}/{{.SampleRate}}
-Update    {{printf "% 8d" .RunningOps.Update.Min}}   {{printf "% 8d" .RunningOps.Update.Max}}   {{printf "% 8d" .RunningOps.Update.Avg}}/{{.SampleRate}}
-Delete    {{printf "% 8d" .RunningOps.Delete.Min}}   {{printf "% 8d" .RunningOps.Delete.Max}}   {{printf "% 8d" .RunningOps.Delete.Avg}}/{{.SampleRate}}
-GetMore   {{printf "% 8d" .RunningOps.GetMore.Min}}   {{printf "% 8d" .RunningOps.GetMore.Max}}   {{printf "% 8d" .RunningOps.GetMore.Avg}}/{{.SampleRate}}
-Command   {{printf "% 8d" .RunningOps.Command.Min}}   {{printf "% 8d" .RunningOps.Command.Max}}   {{printf "% 8d" .RunningOps.Command.Avg}}/{{.SampleRate}}
+Insert    {{padLeft 8 .RunningOps.Insert.Min}}   {{padLeft 8 .RunningOps.Insert.Max}}   {{padLeft 8 .RunningOps.Insert.Avg}}/{{.SampleRate}}
+Query     {{padLeft 8 .RunningOps.Query.Min}}   {{padLeft 8 .RunningOps.Query.Max}}   {{padLeft 8 .RunningOps.Query.Avg}}/{{.SampleRate}}
+Update    {{padLeft 8 .RunningOps.Update.Min}}   {{padLeft 8 .RunningOps.Update.Max}}   {{padLeft 8 .RunningOps.Update.Avg}}/{{.SampleRate}}
+Delete    {{padLeft 8 .RunningOps.Delete.Min}}   {{padLeft 8 .RunningOps.Delete.Max}}   {{padLeft 8 .RunningOps.Delete.Avg}}/{{.SampleRate}}
+GetMore   {{padLeft 8 .RunningOps.GetMore.Min}}   {{padLeft 8 .RunningOps.GetMore.Max}}   {{padLeft 8 .RunningOps.GetMore.Avg}}/{{.SampleRate}}
+Command   {{padLeft 8 .RunningOps.Command.Min}}   {{padLeft 8 .RunningOps.Command.Max}}   {{padLeft 8 .RunningOps.Command.Avg}}/{{.SampleRate}}
 `
@@ -1,10 +1,18 @@
 package templates
 
 const Security = `
-# Security #####################################################################################
-Users {{.Security.Users}}
-Roles {{.Security.Roles}}
-Auth  {{.Security.Auth}}
-SSL   {{.Security.SSL}}
+{{title "Security"}}
+{{- with .Security}}
+Users {{.Users}}
+Roles {{.Roles}}
+Auth  {{.Auth}}
+SSL   {{.SSL}}
+
+Connected as    {{default "-" .AuthUser}}
+Auth mechanism  {{.AuthMechanism}}
+User roles      {{default "-" (join .AuthUserRoles ", ")}}
+{{- else}}
+No security information
+{{- end}}
 
 `
@@ -0,0 +1,32 @@
+package templates
+
+// Trend is the text output of the trend command. It is executed on the
+// trends of the deployments in a history file.
+const Trend = `{{- range .Deployments}}
+{{title (printf "Trend of %s" .Deployment)}}
+                  Points | {{.Points}}
+                    From | {{time .From}}
+                      To | {{time .To}}
+{{- with .TextRows}}
+{{- $metric := colWidth "Metric" . "Metric"}}
+{{- $current := colWidth "Current" . "Current"}}
+{{- $perDay := colWidth "Per day" . "PerDay"}}
+
+{{padRight $metric "Metric"}} {{padLeft $current "Current"}} {{padLeft $perDay "Per day"}} Projection
+{{- range .}}
+{{padRight $metric .Metric}} {{padLeft $current .Current}} {{padLeft $perDay .PerDay}}{{with .Projection}} {{.}}{{end}}
+{{- end}}
+{{- end}}
+{{- with .Members}}
+{{- $name := colWidth "Member" . "Name"}}
+{{- $state := colWidth "State" . "State"}}
+
+{{padRight $name "Member"}} {{padRight $state "State"}} State changes
+{{- range .}}
+{{padRight $name .Name}} {{padRight $state .State}} {{.StateChanges}}
+{{- end}}
+{{- end}}
+
+{{else}}
+No points in {{.File}}
+{{end}}`
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/percona/pt-mongodb-summary/templates"
+	"github.com/pkg/errors"
+)
+
+// trendSchemaVersion is the version of the JSON output of trend. It follows
+// the same rules as reportSchemaVersion.
+const trendSchemaVersion = 1
+
+// trendOptions are the options of the trend command
+type trendOptions struct {
+	OutputFormat string
+	// DiskSize is the disk space for the data of a deployment, in GB. The
+	// days until the data fills DiskUsage percent of it are projected when
+	// it is set.
+	DiskSize  float64
+	DiskUsage float64
+	// OplogWindow is the oplog window whose projection is reported
+	OplogWindow time.Duration
+	NoColor     bool
+	Width       int
+	File        string
+}
+
+// trendMetric is the evolution of a metric of a deployment
+type trendMetric struct {
+	Name string `json:"name"`
+	// Unit is bytes, seconds, connections, percent or ops/s
+	Unit    string  `json:"unit"`
+	Current float64 `json:"current"`
+	// PerDay is the growth per day, the slope of the line that best fits
+	// all the points
+	PerDay float64 `json:"perDay"`
+	// Threshold is the value projected, if the metric has one. DaysLeft is
+	// 0 if it is already reached and nil if it is not reached at this rate.
+	Threshold float64  `json:"threshold,omitempty"`
+	DaysLeft  *float64 `json:"daysLeft,omitempty"`
+	// thresholdText describes the threshold for people
+	thresholdText string
+	// samples is the number of points of the metric
+	samples int
+}
+
+// memberTrend is the last state of a replica set member and the number of
+// times it changed
+type memberTrend struct {
+	Name         string `json:"name"`
+	State        string `json:"state"`
+	StateChanges int    `json:"stateChanges"`
+}
+
+// deploymentTrend is the evolution of a deployment in a history file
+type deploymentTrend struct {
+	Deployment string        `json:"deployment"`
+	Points     int           `json:"points"`
+	From       time.Time     `json:"from"`
+	To         time.Time     `json:"to"`
+	Metrics    []trendMetric `json:"metrics"`
+	Members    []memberTrend `json:"members"`
+}
+
+// trendReport is the trend of every deployment in a history file
+type trendReport struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	File          string            `json:"file"`
+	Deployments   []deploymentTrend `json:"deployments"`
+}
+
+func parseTrendArgs(args []string) (trendOptions, error) {
+	var opts trendOptions
+	fs := flag.NewFlagSet(toolName+" trend", flag.ContinueOnError)
+	fs.StringVar(&opts.OutputFormat, "output-format", "text", "Format of the trends: text, json")
+	fs.Float64Var(&opts.DiskSize, "disk-size", 0, "Disk space for the data of each deployment, in GB, to project when it fills up. 0 means unknown")
+	fs.Float64Var(&opts.DiskUsage, "disk-usage", 80, "Disk usage, in percent of --disk-size, to project")
+	fs.DurationVar(&opts.OplogWindow, "oplog-window", minOplogWindow, "Oplog window to project")
+	fs.BoolVar(&opts.NoColor, "no-color", false, "Don't color the output, even when writing to a terminal")
+	fs.IntVar(&opts.Width, "width", 0, fmt.Sprintf("Width of the text output. 0 means the terminal width, or %d if the output is not a terminal", defaultWidth))
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s trend: %s trend [flags] FILE\n\n", toolName, toolName)
+		fmt.Fprintf(os.Stderr, "FILE is a history file written with --history.\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return trendOptions{}, err
+	}
+	if opts.OutputFormat != "text" && opts.OutputFormat != "json" {
+		return trendOptions{}, fmt.Errorf("invalid output format %q. Valid formats are: text, json", opts.OutputFormat)
+	}
+	if opts.DiskSize < 0 {
+		return trendOptions{}, fmt.Errorf("invalid disk size %v", opts.DiskSize)
+	}
+	if opts.DiskUsage <= 0 || opts.DiskUsage > 100 {
+		return trendOptions{}, fmt.Errorf("invalid disk usage %v", opts.DiskUsage)
+	}
+	if fs.NArg() != 1 {
+		return trendOptions{}, fmt.Errorf("trend needs a history file. Usage: %s trend [flags] FILE", toolName)
+	}
+	opts.File = fs.Arg(0)
+	return opts, nil
+}
+
+// trendMain runs the trend command
+func trendMain(args []string) {
+	opts, err := parseTrendArgs(args)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+	points, err := readHistory(opts.File)
+	if err != nil {
+		exitWithError(errors.Wrapf(err, "cannot read %s", opts.File))
+	}
+
+	t := trendReport{SchemaVersion: trendSchemaVersion, File: opts.File, Deployments: trends(points, opts)}
+	if opts.OutputFormat == "json" {
+		err = writeTrendJSON(os.Stdout, t)
+	} else {
+		err = writeTrendText(os.Stdout, t, newTextStyle(options{NoColor: opts.NoColor, Width: opts.Width}, os.Stdout))
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+}
+
+// trends returns the trend of each deployment in points, in the order they
+// first appear
+func trends(points []historyPoint, opts trendOptions) []deploymentTrend {
+	var order []string
+	byDeployment := make(map[string][]historyPoint)
+	for _, p := range points {
+		if _, ok := byDeployment[p.Deployment]; !ok {
+			order = append(order, p.Deployment)
+		}
+		byDeployment[p.Deployment] = append(byDeployment[p.Deployment], p)
+	}
+
+	var dts []deploymentTrend
+	for _, deployment := range order {
+		dts = append(dts, deploymentTrends(deployment, byDeployment[deployment], opts))
+	}
+	return dts
+}
+
+// series are the values of a metric by time
+type series struct {
+	times  []time.Time
+	values []float64
+}
+
+func (s *series) add(t time.Time, v float64) {
+	s.times = append(s.times, t)
+	s.values = append(s.values, v)
+}
+
+// perDay returns the slope, per day, of the least squares line of s. It is
+// 0 with less than two points or if all of them are at the same time.
+func (s series) perDay() float64 {
+	n := float64(len(s.values))
+	if n < 2 {
+		return 0
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i, v := range s.values {
+		x := s.times[i].Sub(s.times[0]).Hours() / 24
+		sumX += x
+		sumY += v
+		sumXY += x * v
+		sumXX += x * x
+	}
+	d := n*sumXX - sumX*sumX
+	if d == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / d
+}
+
+func (s series) metric(name, unit string) trendMetric {
+	return trendMetric{Name: name, Unit: unit, Current: s.values[len(s.values)-1], PerDay: s.perDay(), samples: len(s.values)}
+}
+
+// project sets the days until m reaches threshold, going up, or going down
+// if down is true
+func (m *trendMetric) project(threshold float64, down bool, text string) {
+	m.Threshold, m.thresholdText = threshold, text
+	left, rate := threshold-m.Current, m.PerDay
+	if down {
+		left, rate = -left, -rate
+	}
+	switch {
+	case left <= 0:
+		days := 0.0
+		m.DaysLeft = &days
+	case rate > 0:
+		days := left / rate
+		m.DaysLeft = &days
+	}
+}
+
+func deploymentTrends(deployment string, points []historyPoint, opts trendOptions) deploymentTrend {
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	dt := deploymentTrend{
+		Deployment: deployment,
+		Points:     len(points),
+		From:       points[0].Time,
+		To:         points[len(points)-1].Time,
+	}
+
+	var dataSize, oplog, connections, cache series
+	dbSizes := make(map[string]*series)
+	// lastSizes are the sizes of the last point with them, so the databases
+	// dropped are not reported
+	var lastSizes map[string]int64
+	rates := make(map[string]*series)
+	opNames := []string{"insert", "query", "update", "delete", "getMore", "command"}
+	// lastCounters are the last points with opcounters of each host
+	lastCounters := make(map[string]historyPoint)
+	var maxConnections float64
+	for _, p := range points {
+		if p.DatabaseSizes != nil {
+			var total int64
+			for name, size := range p.DatabaseSizes {
+				total += size
+				if dbSizes[name] == nil {
+					dbSizes[name] = &series{}
+				}
+				dbSizes[name].add(p.Time, float64(size))
+			}
+			dataSize.add(p.Time, float64(total))
+			lastSizes = p.DatabaseSizes
+		}
+		if p.OplogWindow > 0 {
+			oplog.add(p.Time, float64(p.OplogWindow))
+		}
+		if c := p.Connections; c != nil {
+			connections.add(p.Time, float64(c.Current))
+			maxConnections = float64(c.Current + c.Available)
+		}
+		if p.CacheFill != nil {
+			cache.add(p.Time, *p.CacheFill)
+		}
+		// The rates need the previous point of the same server process, as
+		// the hosts answering for a deployment may alternate
+		if p.Opcounters == nil {
+			continue
+		}
+		prev, ok := lastCounters[p.AnsweredBy]
+		lastCounters[p.AnsweredBy] = p
+		if !ok || p.Uptime <= prev.Uptime {
+			continue
+		}
+		seconds := float64(p.Uptime - prev.Uptime)
+		counts := []int64{p.Opcounters.Insert, p.Opcounters.Query, p.Opcounters.Update, p.Opcounters.Delete, p.Opcounters.GetMore, p.Opcounters.Command}
+		prevCounts := []int64{prev.Opcounters.Insert, prev.Opcounters.Query, prev.Opcounters.Update, prev.Opcounters.Delete, prev.Opcounters.GetMore, prev.Opcounters.Command}
+		for j, name := range opNames {
+			if rates[name] == nil {
+				rates[name] = &series{}
+			}
+			rates[name].add(p.Time, float64(counts[j]-prevCounts[j])/seconds)
+		}
+	}
+
+	if len(dataSize.values) > 0 {
+		m := dataSize.metric("data size", "bytes")
+		if opts.DiskSize > 0 {
+			diskSize := opts.DiskSize * (1 << 30)
+			size, _ := humanBytes(diskSize)
+			m.project(diskSize*opts.DiskUsage/100, false, fmt.Sprintf("%.0f%% of %s", opts.DiskUsage, size))
+		}
+		dt.Metrics = append(dt.Metrics, m)
+		var dbNames []string
+		for name := range lastSizes {
+			dbNames = append(dbNames, name)
+		}
+		sort.Strings(dbNames)
+		for _, name := range dbNames {
+			dt.Metrics = append(dt.Metrics, dbSizes[name].metric("database "+name, "bytes"))
+		}
+	}
+	if len(oplog.values) > 0 {
+		m := oplog.metric("oplog window", "seconds")
+		m.project(opts.OplogWindow.Seconds(), true, "below "+formatMetric("seconds", opts.OplogWindow.Seconds()))
+		dt.Metrics = append(dt.Metrics, m)
+	}
+	if len(connections.values) > 0 {
+		m := connections.metric("connections", "connections")
+		if maxConnections > 0 {
+			m.project(maxConnections*maxConnectionsUsage, false, fmt.Sprintf("%.0f%% of %.0f", maxConnectionsUsage*100, maxConnections))
+		}
+		dt.Metrics = append(dt.Metrics, m)
+	}
+	if len(cache.values) > 0 {
+		dt.Metrics = append(dt.Metrics, cache.metric("cache fill", "percent"))
+	}
+	// Operations never run are left out
+	for _, name := range opNames {
+		if r := rates[name]; r != nil {
+			for _, v := range r.values {
+				if v != 0 {
+					dt.Metrics = append(dt.Metrics, r.metric(name+" rate", "ops/s"))
+					break
+				}
+			}
+		}
+	}
+
+	members := make(map[string]*memberTrend)
+	for _, p := range points {
+		for name, state := range p.MemberStates {
+			m := members[name]
+			if m == nil {
+				m = &memberTrend{Name: name, State: state}
+				members[name] = m
+			}
+			if m.State != state {
+				m.State = state
+				m.StateChanges++
+			}
+		}
+	}
+	for _, m := range members {
+		dt.Members = append(dt.Members, *m)
+	}
+	sort.Slice(dt.Members, func(i, j int) bool { return dt.Members[i].Name < dt.Members[j].Name })
+	return dt
+}
+
+func writeTrendJSON(w io.Writer, t trendReport) error {
+	buf, err := json.MarshalIndent(t, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "cannot write the trends")
+	}
+	_, err = w.Write(append(buf, '\n'))
+	return err
+}
+
+// trendRow is a metric formatted for the text output
+type trendRow struct {
+	Metric, Current, PerDay, Projection string
+}
+
+// TextRows returns the metrics of dt formatted for people
+func (dt deploymentTrend) TextRows() []trendRow {
+	var rows []trendRow
+	for _, m := range dt.Metrics {
+		row := trendRow{
+			Metric:  m.Name,
+			Current: formatMetric(m.Unit, m.Current),
+			PerDay:  formatMetric(m.Unit, math.Abs(m.PerDay)),
+		}
+		if m.PerDay < 0 {
+			row.PerDay = "-" + row.PerDay
+		} else {
+			row.PerDay = "+" + row.PerDay
+		}
+		if m.samples < 2 {
+			row.PerDay = "-"
+		}
+		if m.thresholdText != "" {
+			switch {
+			case m.DaysLeft == nil:
+				row.Projection = m.thresholdText + " not at this rate"
+			case *m.DaysLeft == 0:
+				row.Projection = m.thresholdText + " reached"
+			default:
+				row.Projection = fmt.Sprintf("%s in %s", m.thresholdText, plural(int64(math.Ceil(*m.DaysLeft)), "day"))
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// formatMetric formats the value of a metric in unit
+func formatMetric(unit string, value float64) string {
+	switch unit {
+	case "bytes":
+		s, _ := humanBytes(value)
+		return s
+	case "seconds":
+		if value >= 48*3600 {
+			return fmt.Sprintf("%.1f days", value/86400)
+		}
+		return fmt.Sprintf("%.1f hours", value/3600)
+	case "percent":
+		return fmt.Sprintf("%.2f%%", value)
+	case "ops/s":
+		return fmt.Sprintf("%.1f/s", value)
+	}
+	return fmt.Sprintf("%.0f", value)
+}
+
+func writeTrendText(w io.Writer, t trendReport, style textStyle) error {
+	tmpl, err := template.New("trend").Funcs(withTemplateFuncs(style.funcs())).Parse(templates.Trend)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, t)
+}
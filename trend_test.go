@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/percona/pt-mongodb-summary/proto"
+)
+
+// testHistory returns daily points of a deployment growing 1 GB a day, with
+// an oplog window going down 1 hour a day
+func testHistory() []historyPoint {
+	start := time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+	var points []historyPoint
+	for day := 0; day < 5; day++ {
+		cacheFill := 50.0
+		points = append(points, historyPoint{
+			FormatVersion: historyFormatVersion,
+			Time:          start.Add(time.Duration(day) * 24 * time.Hour),
+			Deployment:    "db1:27017",
+			AnsweredBy:    "db1:27017",
+			DatabaseSizes: map[string]int64{"shop": int64(10+day) << 30, "logs": 1 << 30},
+			OplogWindow:   int64(30-day) * 3600,
+			Connections:   &proto.ConnectionStats{Current: int64(100 + 10*day), Available: int64(900 - 10*day)},
+			Uptime:        int64(day) * 86400,
+			Opcounters:    &proto.OpcountStats{Insert: int64(day) * 86400 * 2},
+			CacheFill:     &cacheFill,
+			MemberStates:  map[string]string{"db1:27017": []string{"PRIMARY", "SECONDARY"}[day%2], "db2:27017": "SECONDARY"},
+		})
+	}
+	return points
+}
+
+func TestTrends(t *testing.T) {
+	// The points are sorted by time
+	points := testHistory()
+	points[0], points[4] = points[4], points[0]
+	dts := trends(points, trendOptions{DiskSize: 100, DiskUsage: 80, OplogWindow: 24 * time.Hour})
+	if len(dts) != 1 {
+		t.Fatalf("expected one deployment, got %d", len(dts))
+	}
+	dt := dts[0]
+	if dt.Points != 5 || dt.From.Day() != 1 || dt.To.Day() != 5 {
+		t.Errorf("invalid period %+v", dt)
+	}
+
+	metrics := make(map[string]trendMetric)
+	for _, m := range dt.Metrics {
+		metrics[m.Name] = m
+	}
+	days := func(m trendMetric) float64 {
+		if m.DaysLeft == nil {
+			return -1
+		}
+		return math.Floor(*m.DaysLeft*10+0.5) / 10
+	}
+	size := metrics["data size"]
+	if size.Current != 15<<30 || size.PerDay != 1<<30 || size.Threshold != 80<<30 || days(size) != 65 {
+		t.Errorf("invalid data size %+v, %v days", size, days(size))
+	}
+	if shop := metrics["database shop"]; shop.PerDay != 1<<30 {
+		t.Errorf("invalid database size %+v", shop)
+	}
+	oplog := metrics["oplog window"]
+	if oplog.Current != 26*3600 || oplog.PerDay != -3600 || days(oplog) != 2 {
+		t.Errorf("invalid oplog window %+v, %v days", oplog, days(oplog))
+	}
+	// 80% of 1000 connections are reached in 66 days
+	if c := metrics["connections"]; c.Current != 140 || c.PerDay != 10 || days(c) != 66 {
+		t.Errorf("invalid connections %+v, %v days", c, days(c))
+	}
+	if c := metrics["cache fill"]; c.Current != 50 || c.PerDay != 0 || c.DaysLeft != nil {
+		t.Errorf("invalid cache fill %+v", c)
+	}
+	if r := metrics["insert rate"]; r.Current != 2 || r.PerDay != 0 {
+		t.Errorf("invalid insert rate %+v", r)
+	}
+	if len(dt.Members) != 2 || dt.Members[0].State != "PRIMARY" || dt.Members[0].StateChanges != 4 || dt.Members[1].StateChanges != 0 {
+		t.Errorf("invalid members %+v", dt.Members)
+	}
+
+	// A restart between two points gives no rate
+	points = testHistory()[:2]
+	points[0].Uptime = 10 * 86400
+	if dt := trends(points, trendOptions{DiskUsage: 80}); len(dt[0].Metrics) != 6 {
+		t.Errorf("expected no rates after a restart: %+v", dt[0].Metrics)
+	}
+
+	// The rates are computed from the previous point of the same host
+	points = testHistory()
+	for i := range points {
+		if i%2 == 1 {
+			points[i].AnsweredBy = "db2:27017"
+			points[i].Uptime += 1000
+			points[i].Opcounters = &proto.OpcountStats{Insert: points[i].Opcounters.Insert * 3}
+		}
+	}
+	dt = trends(points, trendOptions{DiskUsage: 80})[0]
+	for _, m := range dt.Metrics {
+		metrics[m.Name] = m
+	}
+	if r := metrics["insert rate"]; r.Current != 2 || r.samples != 3 {
+		t.Errorf("invalid insert rate with alternating hosts %+v", r)
+	}
+}
+
+func TestTrendOutput(t *testing.T) {
+	tr := trendReport{
+		SchemaVersion: trendSchemaVersion,
+		File:          "history.jsonl",
+		Deployments:   trends(testHistory(), trendOptions{DiskSize: 100, DiskUsage: 80, OplogWindow: 24 * time.Hour}),
+	}
+	buf := &bytes.Buffer{}
+	if err := writeTrendText(buf, tr, textStyle{}); err != nil {
+		t.Fatalf("cannot write the text trends: %s", err)
+	}
+	for _, want := range []string{
+		"# Trend of db1:27017 #",
+		"\n                  Points | 5\n",
+		"\nMetric           Current    Per day Projection\n",
+		"\ndata size       15.00 GB   +1.00 GB 80% of 100.00 GB in 65 days\n",
+		"\ndatabase logs    1.00 GB       +0 B\n",
+		"\noplog window  26.0 hours -1.0 hours below 24.0 hours in 2 days\n",
+		"\nconnections          140        +10 80% of 1000 in 66 days\n",
+		"\ninsert rate        2.0/s     +0.0/s\n",
+		"\nMember    State     State changes\ndb1:27017 PRIMARY   4\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, buf)
+		}
+	}
+
+	buf.Reset()
+	if err := writeTrendJSON(buf, tr); err != nil {
+		t.Fatalf("cannot write the JSON trends: %s", err)
+	}
+	var doc struct {
+		SchemaVersion int `json:"schemaVersion"`
+		Deployments   []struct {
+			Metrics []map[string]interface{} `json:"metrics"`
+		} `json:"deployments"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+	size := doc.Deployments[0].Metrics[0]
+	if doc.SchemaVersion != trendSchemaVersion || size["name"] != "data size" || size["unit"] != "bytes" || size["daysLeft"] != 65.0 {
+		t.Errorf("invalid JSON trends: %+v", doc)
+	}
+
+	buf.Reset()
+	writeTrendText(buf, trendReport{File: "empty.jsonl"}, textStyle{})
+	if !strings.Contains(buf.String(), "No points in empty.jsonl") {
+		t.Errorf("invalid text trends without points:\n%s", buf)
+	}
+}
+
+func TestParseTrendArgs(t *testing.T) {
+	opts, err := parseTrendArgs([]string{"--disk-size", "500", "--oplog-window", "48h", "history.jsonl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.DiskSize != 500 || opts.DiskUsage != 80 || opts.OplogWindow != 48*time.Hour || opts.File != "history.jsonl" || opts.OutputFormat != "text" {
+		t.Errorf("invalid options: %+v", opts)
+	}
+	for _, args := range [][]string{
+		{},
+		{"a.jsonl", "b.jsonl"},
+		{"--output-format", "yaml", "history.jsonl"},
+		{"--disk-size", "-1", "history.jsonl"},
+		{"--disk-usage", "120", "history.jsonl"},
+	} {
+		if _, err := parseTrendArgs(args); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}